@@ -0,0 +1,161 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// userConfigAccounts keeps the `accounts:` entries in file order, since the
+// first entry matching an account wins.
+type userConfigAccounts []userConfigAccountEntry
+
+type userConfigAccountEntry struct {
+	Match string
+	userConfigAccount
+}
+
+type userConfigAccount struct {
+	Regions        []string `yaml:"regions"`
+	PreferredRole  string   `yaml:"preferred_role"`
+	IncludeStopped *bool    `yaml:"include_stopped"`
+	TTLInstances   string   `yaml:"ttl_instances"`
+	Document       string   `yaml:"document"`
+}
+
+func (a *userConfigAccounts) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: accounts must map an account ID or name glob to overrides", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var entry userConfigAccount
+		if err := node.Content[i+1].Decode(&entry); err != nil {
+			return err
+		}
+		*a = append(*a, userConfigAccountEntry{Match: node.Content[i].Value, userConfigAccount: entry})
+	}
+	return nil
+}
+
+// accountOverride is a parsed `accounts:` entry.
+type accountOverride struct {
+	match          string
+	regions        []string
+	preferredRole  string
+	includeStopped *bool
+	ttlInstances   time.Duration
+	document       string
+}
+
+func parseAccountOverrides(entries userConfigAccounts) ([]accountOverride, error) {
+	out := make([]accountOverride, 0, len(entries))
+	for _, e := range entries {
+		match := strings.TrimSpace(e.Match)
+		if _, err := path.Match(strings.ToLower(match), ""); err != nil || match == "" {
+			return nil, fmt.Errorf("invalid config key accounts.%s: not an account ID or name glob", e.Match)
+		}
+		o := accountOverride{
+			match:          match,
+			regions:        e.Regions,
+			preferredRole:  strings.TrimSpace(e.PreferredRole),
+			includeStopped: e.IncludeStopped,
+			document:       strings.TrimSpace(e.Document),
+		}
+		if strings.TrimSpace(e.TTLInstances) != "" {
+			ttl, err := parseConfigDuration("accounts."+match+".ttl_instances", e.TTLInstances)
+			if err != nil {
+				return nil, err
+			}
+			o.ttlInstances = ttl
+		}
+		out = append(out, o)
+	}
+	return out, nil
+}
+
+func (o accountOverride) matches(accountID, accountName string) bool {
+	return accountMatches(o.match, accountID, accountName)
+}
+
+// accountMatches reports whether match is the account ID or a
+// case-insensitive glob on the account name.
+func accountMatches(match, accountID, accountName string) bool {
+	if match == accountID {
+		return true
+	}
+	ok, _ := path.Match(strings.ToLower(match), strings.ToLower(accountName))
+	return ok
+}
+
+// forAccount applies the first `accounts:` entry matching the account. An
+// override only replaces values that came from the config file or built-in
+// defaults, so flags, presets and saved scopes still win.
+func (o Options) forAccount(accountID, accountName string) Options {
+	var match *accountOverride
+	for i := range o.accountOverrides {
+		if o.accountOverrides[i].matches(accountID, accountName) {
+			match = &o.accountOverrides[i]
+			break
+		}
+	}
+	if match == nil {
+		return o
+	}
+
+	sources := make(map[string]string, len(o.ValueSource))
+	for k, v := range o.ValueSource {
+		sources[k] = v
+	}
+	source := "config(accounts." + match.match + ")"
+	fromConfig := func(key string) bool {
+		src := sources[key]
+		return src == "" || src == "built-in" || strings.HasPrefix(src, "config")
+	}
+
+	if len(match.regions) > 0 && fromConfig("regions") {
+		o.RegionsArg = strings.Join(match.regions, ",")
+		sources["regions"] = source
+	}
+	if match.preferredRole != "" && fromConfig("role") {
+		o.RoleFilter = match.preferredRole
+		o.RoleFromPreferred = true
+		sources["role"] = source
+	}
+	if match.includeStopped != nil && fromConfig("include-stopped") {
+		o.IncludeStopped = *match.includeStopped
+		sources["include-stopped"] = source
+	}
+	if match.ttlInstances > 0 && fromConfig("cache-ttl-instances") {
+		o.CacheTTLInstances = match.ttlInstances
+		sources["cache-ttl-instances"] = source
+	}
+	if match.document != "" && fromConfig("document") {
+		o.Document = match.document
+		sources["document"] = source
+	}
+	o.ValueSource = sources
+	return o
+}
+
+func (o Options) forTarget(target roleTarget) Options {
+	return o.forAccount(target.AccountID, target.AccountName)
+}
+
+// setScopeSource records that account, role and regions were narrowed to a
+// saved scope (favorite, history entry, resumed scope), so account overrides
+// leave them alone.
+func (o *Options) setScopeSource(source string) {
+	sources := make(map[string]string, len(o.ValueSource)+3)
+	for k, v := range o.ValueSource {
+		sources[k] = v
+	}
+	for key, value := range map[string]string{"account": o.AccountFilter, "role": o.RoleFilter, "regions": o.RegionsArg} {
+		if value != "" {
+			sources[key] = source
+		}
+	}
+	o.ValueSource = sources
+}
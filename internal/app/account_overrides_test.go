@@ -0,0 +1,106 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAccountOverridesKeepFileOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `accounts:
+  "012345678901":
+    regions: [eu-west-1]
+  prod-*:
+    preferred_role: ReadOnly
+    ttl_instances: 5m
+  "*":
+    include_stopped: true
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	isolateConfigLayers(t)
+	cfg, err := loadLayeredConfig(path)
+	if err != nil {
+		t.Fatalf("loadLayeredConfig: %v", err)
+	}
+	opts, err := mergeOptions(Options{Workers: 1}, cfg)
+	if err != nil {
+		t.Fatalf("mergeOptions: %v", err)
+	}
+
+	byID := opts.forAccount("012345678901", "prod-payments")
+	if byID.RegionsArg != "eu-west-1" || byID.RoleFilter != "" || byID.IncludeStopped {
+		t.Fatalf("expected only the account ID entry to apply, got %+v", byID)
+	}
+	byName := opts.forAccount("111", "Prod-Search")
+	if byName.RoleFilter != "ReadOnly" || !byName.RoleFromPreferred || byName.CacheTTLInstances != 5*time.Minute {
+		t.Fatalf("expected the name glob entry to apply, got %+v", byName)
+	}
+	if src := sourceOf(byName, "role"); src != "config(accounts.prod-*)" {
+		t.Fatalf("expected account override source, got %q", src)
+	}
+	if other := opts.forAccount("222", "sandbox"); !other.IncludeStopped {
+		t.Fatalf("expected catch-all entry to apply, got %+v", other)
+	}
+	if sourceOf(opts, "role") != "built-in" {
+		t.Fatalf("forAccount must not change the base options, got %q", sourceOf(opts, "role"))
+	}
+}
+
+func TestAccountOverridesYieldToFlagsAndSavedScopes(t *testing.T) {
+	cfg := UserConfig{Accounts: userConfigAccounts{{Match: "prod", userConfigAccount: userConfigAccount{Regions: []string{"eu-west-1"}, PreferredRole: "ReadOnly"}}}}
+	opts, err := mergeOptions(Options{Workers: 1, RoleFilter: "Admin", FlagSet: map[string]bool{"role": true}}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := opts.forAccount("111", "prod")
+	if got.RoleFilter != "Admin" || got.RegionsArg != "eu-west-1" {
+		t.Fatalf("expected flag role and override regions, got role=%q regions=%q", got.RoleFilter, got.RegionsArg)
+	}
+
+	opts.RegionsArg = "us-east-1"
+	opts.setScopeSource("favorite")
+	if got := opts.forAccount("111", "prod"); got.RegionsArg != "us-east-1" {
+		t.Fatalf("expected favorite region to win, got %q", got.RegionsArg)
+	}
+
+	if _, err := mergeOptions(Options{}, UserConfig{Accounts: userConfigAccounts{{Match: "prod-["}}}); err == nil {
+		t.Fatal("expected invalid glob to be rejected")
+	}
+}
+
+func TestDiscoverRoleTargetsUsesAccountPreferredRole(t *testing.T) {
+	origFetch := fetchRolesForAcctFetcher
+	defer func() { fetchRolesForAcctFetcher = origFetch }()
+	fetchRolesForAcctFetcher = func(ctx context.Context, profile, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
+		return []roleTarget{
+			{AccountID: accountID, AccountName: accountName, RoleName: "Admin"},
+			{AccountID: accountID, AccountName: accountName, RoleName: "ReadOnly"},
+		}, nil
+	}
+
+	cfg := UserConfig{
+		PreferredRole: "Admin",
+		Accounts:      userConfigAccounts{{Match: "prod-*", userConfigAccount: userConfigAccount{PreferredRole: "ReadOnly"}}},
+	}
+	opts, err := mergeOptions(Options{Profile: "p", Workers: 1}, cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	accounts := []ssoAccountsResponse{testAccount("111", "prod-payments"), testAccount("222", "sandbox")}
+	targets, err := discoverRoleTargets(context.Background(), opts, accounts, "us-east-1", "token")
+	if err != nil {
+		t.Fatalf("discoverRoleTargets: %v", err)
+	}
+	roles := map[string]string{}
+	for _, tgt := range targets {
+		roles[tgt.AccountID] += tgt.RoleName
+	}
+	if len(targets) != 2 || roles["111"] != "ReadOnly" || roles["222"] != "Admin" {
+		t.Fatalf("expected per-account preferred roles, got %+v", targets)
+	}
+}
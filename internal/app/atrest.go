@@ -0,0 +1,237 @@
+package app
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+const (
+	cacheKeyEnv      = "SWAMP_CACHE_KEY"
+	cacheKeyFileName = "cache.key"
+)
+
+// atRestMagic starts every encrypted cache or state file; files without it
+// are read as plain JSON so turning encryption on keeps existing history.
+var atRestMagic = []byte("swamp-enc-v1\n")
+
+// atRestSecret is the key material cache and state files are encrypted
+// with; empty means they are written as plain JSON.
+var atRestSecret string
+
+var runKeyCommandFn = func(command string) ([]byte, error) {
+	cmd := exec.Command("sh", "-c", command)
+	cmd.Stderr = os.Stderr
+	return cmd.Output()
+}
+
+// configureAtRest picks the key for cache and state files. Encryption is on
+// when cache.encrypt is set or SWAMP_CACHE_KEY is present; the key comes
+// from SWAMP_CACHE_KEY, then cache.key_command, then a key file next to the
+// config file that is created on first use.
+func configureAtRest(opts Options) error {
+	atRestSecret = ""
+	if env := strings.TrimSpace(os.Getenv(cacheKeyEnv)); env != "" {
+		atRestSecret = env
+		return nil
+	}
+	if !opts.CacheEncrypt {
+		return nil
+	}
+	if command := strings.TrimSpace(opts.CacheKeyCommand); command != "" {
+		out, err := runKeyCommandFn(command)
+		if err != nil {
+			return fmt.Errorf("cache key command failed: %w", err)
+		}
+		secret := strings.TrimSpace(string(out))
+		if secret == "" {
+			return errors.New("cache key command printed no key")
+		}
+		atRestSecret = secret
+		return nil
+	}
+	secret, err := loadOrCreateKeyFile(cacheKeyFilePath(opts))
+	if err != nil {
+		return err
+	}
+	atRestSecret = secret
+	return nil
+}
+
+// configureCompletionAtRest picks the key for shell completion, which runs
+// on every TAB: it never runs cache.key_command or creates a key file, so
+// without SWAMP_CACHE_KEY or an existing key file only plain files complete.
+func configureCompletionAtRest(opts Options) {
+	atRestSecret = ""
+	if env := strings.TrimSpace(os.Getenv(cacheKeyEnv)); env != "" {
+		atRestSecret = env
+		return
+	}
+	if !opts.CacheEncrypt || strings.TrimSpace(opts.CacheKeyCommand) != "" {
+		return
+	}
+	path := cacheKeyFilePath(opts)
+	if _, err := os.Stat(path); err == nil {
+		atRestSecret, _ = loadOrCreateKeyFile(path)
+	}
+}
+
+func cacheKeyFilePath(opts Options) string {
+	return filepath.Join(filepath.Dir(resolveConfigPath(opts.ConfigPath)), cacheKeyFileName)
+}
+
+func loadOrCreateKeyFile(path string) (string, error) {
+	info, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		if err := mkdirPrivate(filepath.Dir(path)); err != nil {
+			return "", err
+		}
+		raw := make([]byte, 32)
+		if _, err := rand.Read(raw); err != nil {
+			return "", err
+		}
+		secret := hex.EncodeToString(raw)
+		// The key is written to a temp file and linked into place, so other
+		// processes never see a partly written key file and a concurrent
+		// creator is never overwritten.
+		tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*.tmp")
+		if err != nil {
+			return "", err
+		}
+		tmpName := tmp.Name()
+		defer func() { _ = os.Remove(tmpName) }()
+		if _, err := tmp.WriteString(secret + "\n"); err != nil {
+			tmp.Close()
+			return "", err
+		}
+		if err := tmp.Sync(); err != nil {
+			tmp.Close()
+			return "", err
+		}
+		if err := tmp.Close(); err != nil {
+			return "", err
+		}
+		if err := os.Link(tmpName, path); err != nil {
+			if os.IsExist(err) {
+				// Another swamp process created it first.
+				return loadOrCreateKeyFile(path)
+			}
+			return "", err
+		}
+		return secret, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		return "", fmt.Errorf("cache key file %s is accessible by other users (mode %04o); run chmod 600 %s", path, info.Mode().Perm(), path)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	secret := strings.TrimSpace(string(data))
+	if secret == "" {
+		return "", fmt.Errorf("cache key file %s is empty", path)
+	}
+	return secret, nil
+}
+
+// atRestEnv is the environment for helper processes fzf starts (previews,
+// rescans), so they can read encrypted files without rerunning the key
+// command.
+func atRestEnv() []string {
+	env := os.Environ()
+	if atRestSecret != "" && os.Getenv(cacheKeyEnv) == "" {
+		env = append(env, cacheKeyEnv+"="+atRestSecret)
+	}
+	return env
+}
+
+func atRestAEAD() (cipher.AEAD, error) {
+	key := sha256.Sum256([]byte(atRestSecret))
+	block, err := aes.NewCipher(key[:])
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func isSealed(data []byte) bool {
+	return bytes.HasPrefix(data, atRestMagic)
+}
+
+// sealAtRest encrypts a file body when encryption is configured.
+func sealAtRest(plain []byte) ([]byte, error) {
+	if atRestSecret == "" {
+		return plain, nil
+	}
+	aead, err := atRestAEAD()
+	if err != nil {
+		return nil, err
+	}
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	out := append([]byte(nil), atRestMagic...)
+	out = append(out, nonce...)
+	return aead.Seal(out, nonce, plain, atRestMagic), nil
+}
+
+// openAtRest returns the plain body of a file written by sealAtRest, or the
+// data itself when it was never encrypted.
+func openAtRest(data []byte) ([]byte, error) {
+	if !isSealed(data) {
+		return data, nil
+	}
+	if atRestSecret == "" {
+		return nil, errors.New("file is encrypted and no cache key is configured")
+	}
+	aead, err := atRestAEAD()
+	if err != nil {
+		return nil, err
+	}
+	body := data[len(atRestMagic):]
+	if len(body) < aead.NonceSize() {
+		return nil, errors.New("encrypted file is truncated")
+	}
+	plain, err := aead.Open(nil, body[:aead.NonceSize()], body[aead.NonceSize():], atRestMagic)
+	if err != nil {
+		return nil, errors.New("cannot decrypt file with the configured cache key")
+	}
+	return plain, nil
+}
+
+// warnedLooseDirs keeps mkdirPrivate from repeating its warning for a
+// directory every time it is used in one run.
+var warnedLooseDirs sync.Map
+
+// mkdirPrivate creates dir with 0700. A directory that already exists may
+// be one the user picked (cache.dir, --cache-dir, the config dir), so its
+// mode is left alone; swamp only warns when others can read it.
+func mkdirPrivate(dir string) error {
+	info, err := os.Stat(dir)
+	if os.IsNotExist(err) {
+		return os.MkdirAll(dir, 0o700)
+	}
+	if err != nil {
+		return err
+	}
+	if info.Mode().Perm()&0o077 != 0 {
+		if _, warned := warnedLooseDirs.LoadOrStore(dir, true); !warned {
+			fmt.Fprintf(os.Stderr, "warning: %s is accessible to other users (mode %04o); swamp leaves its mode as it is\n", dir, info.Mode().Perm())
+		}
+	}
+	return nil
+}
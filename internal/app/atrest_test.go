@@ -0,0 +1,219 @@
+package app
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func withAtRestSecret(t *testing.T, secret string) {
+	t.Helper()
+	orig := atRestSecret
+	atRestSecret = secret
+	t.Cleanup(func() { atRestSecret = orig })
+}
+
+func TestSealAndOpenAtRest(t *testing.T) {
+	withAtRestSecret(t, "s3cret")
+	sealed, err := sealAtRest([]byte(`{"account":"prod"}`))
+	if err != nil {
+		t.Fatalf("sealAtRest: %v", err)
+	}
+	if !isSealed(sealed) || bytes.Contains(sealed, []byte("prod")) {
+		t.Fatalf("expected ciphertext, got %q", sealed)
+	}
+	plain, err := openAtRest(sealed)
+	if err != nil || string(plain) != `{"account":"prod"}` {
+		t.Fatalf("openAtRest = %q, %v", plain, err)
+	}
+
+	atRestSecret = "other"
+	if _, err := openAtRest(sealed); err == nil {
+		t.Fatal("expected wrong key to fail")
+	}
+	if plain, err := openAtRest([]byte(`{"plain":true}`)); err != nil || string(plain) != `{"plain":true}` {
+		t.Fatalf("expected plain files to pass through, got %q, %v", plain, err)
+	}
+}
+
+func TestConfigureAtRestKeySources(t *testing.T) {
+	withAtRestSecret(t, "")
+	origCmd := runKeyCommandFn
+	defer func() { runKeyCommandFn = origCmd }()
+	configPath := filepath.Join(t.TempDir(), "swamp", "config.yaml")
+
+	if err := configureAtRest(Options{ConfigPath: configPath}); err != nil || atRestSecret != "" {
+		t.Fatalf("expected encryption off by default, got %q, %v", atRestSecret, err)
+	}
+
+	runKeyCommandFn = func(command string) ([]byte, error) {
+		if command != "pass show swamp" {
+			t.Fatalf("unexpected key command %q", command)
+		}
+		return []byte("from-command\n"), nil
+	}
+	if err := configureAtRest(Options{ConfigPath: configPath, CacheEncrypt: true, CacheKeyCommand: "pass show swamp"}); err != nil || atRestSecret != "from-command" {
+		t.Fatalf("expected key from command, got %q, %v", atRestSecret, err)
+	}
+
+	t.Setenv(cacheKeyEnv, "from-env")
+	if err := configureAtRest(Options{ConfigPath: configPath, CacheKeyCommand: "pass show swamp"}); err != nil || atRestSecret != "from-env" {
+		t.Fatalf("expected env key to win, got %q, %v", atRestSecret, err)
+	}
+}
+
+func TestConfigureAtRestCreatesPrivateKeyFile(t *testing.T) {
+	withAtRestSecret(t, "")
+	configPath := filepath.Join(t.TempDir(), "swamp", "config.yaml")
+	if err := configureAtRest(Options{ConfigPath: configPath, CacheEncrypt: true}); err != nil {
+		t.Fatalf("configureAtRest: %v", err)
+	}
+	first := atRestSecret
+	keyPath := filepath.Join(filepath.Dir(configPath), cacheKeyFileName)
+	info, err := os.Stat(keyPath)
+	if err != nil || info.Mode().Perm() != 0o600 || first == "" {
+		t.Fatalf("expected 0600 key file, got %v (err=%v)", info, err)
+	}
+	if err := configureAtRest(Options{ConfigPath: configPath, CacheEncrypt: true}); err != nil || atRestSecret != first {
+		t.Fatalf("expected the same key on the next run, got %q, %v", atRestSecret, err)
+	}
+
+	if err := os.Chmod(keyPath, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := configureAtRest(Options{ConfigPath: configPath, CacheEncrypt: true}); err == nil {
+		t.Fatal("expected a world-readable key file to be rejected")
+	}
+}
+
+func TestEncryptedCacheAndRecentTargets(t *testing.T) {
+	withAtRestSecret(t, "s3cret")
+	opts := newTestCacheOptions(t, "balanced")
+	opts.CacheDir = filepath.Join(opts.CacheDir, "swamp")
+	opts.cacheStore = newCacheStore(opts)
+	key := cacheKeyAccounts(opts.Profile, "us-east-1")
+	if err := opts.cacheStore.writeJSON(opts.Profile, key, time.Minute, []ssoAccountsResponse{testAccount("111", "payments-prod")}); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveRecentTargets(opts.CacheDir, opts.Profile, recentScope{AccountID: "111", AccountName: "payments-prod", RoleName: "Admin"}, recentInstance{InstanceID: "i-1", Region: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{opts.cacheStore.filePath(opts.Profile, key), filepath.Join(opts.CacheDir, recentTargetsFileName)} {
+		raw, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if bytes.Contains(raw, []byte("payments-prod")) {
+			t.Fatalf("%s holds plain text", path)
+		}
+		info, _ := os.Stat(path)
+		if info.Mode().Perm() != 0o600 {
+			t.Fatalf("expected 0600 on %s, got %04o", path, info.Mode().Perm())
+		}
+	}
+	if info, _ := os.Stat(opts.CacheDir); info.Mode().Perm() != 0o700 {
+		t.Fatalf("expected 0700 cache dir, got %04o", info.Mode().Perm())
+	}
+
+	var cached []ssoAccountsResponse
+	status, _, err := opts.cacheStore.readJSON(opts.Profile, key, &cached)
+	if err != nil || status != cacheHitFresh || cached[0].AccountList[0].AccountName != "payments-prod" {
+		t.Fatalf("expected encrypted entry to read back, got %v %+v (err=%v)", status, cached, err)
+	}
+	recent, err := loadRecentTargets(opts.CacheDir)
+	if err != nil || len(recent.history(opts.Profile)) != 1 {
+		t.Fatalf("expected encrypted history to load, got %+v (err=%v)", recent, err)
+	}
+}
+
+func TestUndecryptableCacheFilesAreKeptUntilExplicitPrune(t *testing.T) {
+	withAtRestSecret(t, "first")
+	dir := t.TempDir()
+	store := newCacheStore(Options{CacheEnabled: true, CacheDir: dir, CacheMode: "balanced"})
+	key := cacheKeyRoles("prof", "us-east-1", "123456789012")
+	if err := store.writeJSON("prof", key, time.Hour, []string{"Admin"}); err != nil {
+		t.Fatalf("writeJSON: %v", err)
+	}
+	path := store.filePath("prof", key)
+
+	atRestSecret = "second"
+	var got []string
+	if status, _, err := store.readJSON("prof", key, &got); err != nil || status != cacheMiss {
+		t.Fatalf("expected a miss, got %v, %v", status, err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected the undecryptable file to be kept: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := pruneCache(&buf, store, false, false, time.Now()); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected prune without --undecryptable to keep the file: %v", err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("Kept 1 files that cannot be decrypted")) {
+		t.Fatalf("expected prune to report the kept file, got:\n%s", buf.String())
+	}
+	if err := pruneCache(&buf, store, false, true, time.Now()); err != nil {
+		t.Fatalf("prune --undecryptable: %v", err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected prune --undecryptable to remove the file")
+	}
+}
+
+func TestLoadOrCreateKeyFileConcurrentCreatorsShareOneKey(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, cacheKeyFileName)
+	const n = 8
+	keys := make([]string, n)
+	errs := make([]error, n)
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			keys[i], errs[i] = loadOrCreateKeyFile(path)
+		}(i)
+	}
+	wg.Wait()
+	for i := range keys {
+		if errs[i] != nil || keys[i] == "" || keys[i] != keys[0] {
+			t.Fatalf("expected every caller to get the same key, got %q, %v (first %q)", keys[i], errs[i], keys[0])
+		}
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the key file to be left, got %v, %v", entries, err)
+	}
+}
+
+func TestMkdirPrivateOnlyRestrictsDirectoriesItCreates(t *testing.T) {
+	shared := filepath.Join(t.TempDir(), "shared")
+	if err := os.Mkdir(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(shared, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	created := filepath.Join(shared, "swamp", "locks")
+	if err := mkdirPrivate(created); err != nil {
+		t.Fatal(err)
+	}
+	if err := mkdirPrivate(shared); err != nil {
+		t.Fatal(err)
+	}
+	if info, _ := os.Stat(shared); info.Mode().Perm() != 0o755 {
+		t.Fatalf("expected the existing directory to keep 0755, got %04o", info.Mode().Perm())
+	}
+	for _, dir := range []string{filepath.Dir(created), created} {
+		if info, _ := os.Stat(dir); info.Mode().Perm() != 0o700 {
+			t.Fatalf("expected 0700 on %s, got %04o", dir, info.Mode().Perm())
+		}
+	}
+}
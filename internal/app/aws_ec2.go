@@ -1,13 +1,17 @@
 package app
 
 import (
+	"bufio"
 	"bytes"
+	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"os/signal"
 	"sort"
+	"strconv"
 	"strings"
 	"sync"
 	"syscall"
@@ -17,9 +21,10 @@ import (
 var (
 	resolveRegionsFetcher = resolveRegions
 	queryInstancesFetcher = queryInstances
+	querySSMPingStatusFn  = querySSMPingStatus
 )
 
-func resolveRegions(tmpConfigPath, profile, discoveryRegion, regionsArg string, includeAllRegions bool) ([]string, error) {
+func resolveRegions(ctx context.Context, tmpConfigPath, profile, discoveryRegion, regionsArg string, includeAllRegions bool) ([]string, error) {
 	if strings.TrimSpace(regionsArg) != "" {
 		parts := strings.Split(regionsArg, ",")
 		var regions []string
@@ -44,7 +49,7 @@ func resolveRegions(tmpConfigPath, profile, discoveryRegion, regionsArg string,
 	if includeAllRegions {
 		args = append(args, "--all-regions")
 	}
-	out, err := runAWSJSON(tmpConfigPath, profile, args)
+	out, err := runAWSJSON(ctx, tmpConfigPath, profile, args)
 	if err != nil {
 		return nil, err
 	}
@@ -62,20 +67,22 @@ func resolveRegions(tmpConfigPath, profile, discoveryRegion, regionsArg string,
 	return regions, nil
 }
 
-func resolveRegionsCached(opts Options, tmpConfigPath, discoveryProfile, discoveryRegion, regionsArg string, includeAllRegions bool) ([]string, error) {
+func resolveRegionsCached(ctx context.Context, opts Options, tmpConfigPath, discoveryProfile, discoveryRegion, regionsArg string, includeAllRegions bool) ([]string, error) {
 	key := cacheKeyRegions(opts.Profile, discoveryProfile, discoveryRegion, includeAllRegions)
 	var cached []string
 	if opts.cacheStore != nil && strings.TrimSpace(regionsArg) == "" {
 		status, age, err := opts.cacheStore.readJSON(opts.Profile, key, &cached)
 		if err == nil {
 			if status == cacheHitFresh {
-				fmt.Printf("Using cached regions (age=%s)\n", age.Round(time.Second))
+				fmt.Fprintf(opts.progressOut(), "Using cached regions (age=%s)\n", age.Round(time.Second))
 				return cached, nil
 			}
 			if status == cacheHitStale && opts.cacheStore.shouldUseStale() {
-				fmt.Printf("Using cached regions (stale, age=%s), refreshing...\n", age.Round(time.Second))
-				opts.cacheStore.refreshAsync(func() error {
-					fresh, fetchErr := resolveRegionsFetcher(tmpConfigPath, discoveryProfile, discoveryRegion, regionsArg, includeAllRegions)
+				fmt.Fprintf(opts.progressOut(), "Using cached regions (stale, age=%s), refreshing...\n", age.Round(time.Second))
+				opts.cacheStore.refreshAsync(opts.Profile, key, func(refreshCtx context.Context) error {
+					callCtx, cancel := opts.callContext(refreshCtx)
+					defer cancel()
+					fresh, fetchErr := resolveRegionsFetcher(callCtx, tmpConfigPath, discoveryProfile, discoveryRegion, regionsArg, includeAllRegions)
 					if fetchErr != nil {
 						return fetchErr
 					}
@@ -86,7 +93,9 @@ func resolveRegionsCached(opts Options, tmpConfigPath, discoveryProfile, discove
 		}
 	}
 
-	fresh, err := resolveRegionsFetcher(tmpConfigPath, discoveryProfile, discoveryRegion, regionsArg, includeAllRegions)
+	callCtx, cancel := opts.callContext(ctx)
+	defer cancel()
+	fresh, err := resolveRegionsFetcher(callCtx, tmpConfigPath, discoveryProfile, discoveryRegion, regionsArg, includeAllRegions)
 	if err != nil {
 		return nil, err
 	}
@@ -113,25 +122,8 @@ func buildTemporaryAWSConfig(base profileConfig, targets []roleTarget) (string,
 		profileName := fmt.Sprintf("swamp-%d", i+1)
 		profileNames[targetKey(t)] = profileName
 
-		buf.WriteString(fmt.Sprintf("\n[profile %s]\n", profileName))
-		if base.SSOSession != "" {
-			buf.WriteString(fmt.Sprintf("sso_session = %s\n", base.SSOSession))
-		} else {
-			buf.WriteString(fmt.Sprintf("sso_start_url = %s\n", base.SSOStartURL))
-			buf.WriteString(fmt.Sprintf("sso_region = %s\n", base.SSORegion))
-		}
-		buf.WriteString(fmt.Sprintf("sso_account_id = %s\n", t.AccountID))
-		buf.WriteString(fmt.Sprintf("sso_role_name = %s\n", t.RoleName))
-		if base.Region != "" {
-			buf.WriteString(fmt.Sprintf("region = %s\n", base.Region))
-		} else {
-			buf.WriteString("region = us-east-1\n")
-		}
-		if base.Output != "" {
-			buf.WriteString(fmt.Sprintf("output = %s\n", base.Output))
-		} else {
-			buf.WriteString("output = json\n")
-		}
+		buf.WriteString("\n")
+		writeProfileSection(&buf, profileName, base, t)
 	}
 
 	f, err := os.CreateTemp("", "aws-config-swamp-*.ini")
@@ -146,15 +138,61 @@ func buildTemporaryAWSConfig(base profileConfig, targets []roleTarget) (string,
 	return f.Name(), profileNames, nil
 }
 
-func scanAllInstances(opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) []instanceCandidate {
+// writeProfileSection writes a `[profile NAME]` section that signs in
+// through the same SSO session or start URL as base.
+func writeProfileSection(buf *bytes.Buffer, name string, base profileConfig, t roleTarget) {
+	buf.WriteString(fmt.Sprintf("[profile %s]\n", name))
+	if base.SSOSession != "" {
+		buf.WriteString(fmt.Sprintf("sso_session = %s\n", base.SSOSession))
+	} else {
+		buf.WriteString(fmt.Sprintf("sso_start_url = %s\n", base.SSOStartURL))
+		buf.WriteString(fmt.Sprintf("sso_region = %s\n", base.SSORegion))
+	}
+	buf.WriteString(fmt.Sprintf("sso_account_id = %s\n", t.AccountID))
+	buf.WriteString(fmt.Sprintf("sso_role_name = %s\n", t.RoleName))
+	if base.Region != "" {
+		buf.WriteString(fmt.Sprintf("region = %s\n", base.Region))
+	} else {
+		buf.WriteString("region = us-east-1\n")
+	}
+	if base.Output != "" {
+		buf.WriteString(fmt.Sprintf("output = %s\n", base.Output))
+	} else {
+		buf.WriteString("output = json\n")
+	}
+}
+
+func scanAllInstances(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) ([]instanceCandidate, error) {
+	var all []instanceCandidate
+	var scanErr error
+	for r := range streamAllInstances(ctx, opts, tmpConfigPath, targets, profileNames, regions, workers, runningOnly) {
+		all = append(all, r.Candidates...)
+		if r.Err != nil {
+			scanErr = r.Err
+		}
+	}
+	if scanErr == nil && ctx.Err() != nil {
+		scanErr = context.Cause(ctx)
+	}
+	return all, scanErr
+}
+
+func streamAllInstances(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) <-chan scanResult {
 	type job struct {
 		target  roleTarget
 		profile string
 		region  string
 	}
 
+	scanCtx, cancel := opts.discoveryContext(ctx)
+	total := len(targets) * len(regions)
+	type scanned struct {
+		cands []instanceCandidate
+		stale bool
+	}
 	jobs := make(chan job, workers*2)
-	results := make(chan scanResult, workers*2)
+	found := make(chan scanned, workers*2)
+	out := make(chan scanResult)
 
 	var wg sync.WaitGroup
 	for i := 0; i < workers; i++ {
@@ -162,46 +200,74 @@ func scanAllInstances(opts Options, tmpConfigPath string, targets []roleTarget,
 		go func() {
 			defer wg.Done()
 			for j := range jobs {
-				cands, _ := queryInstancesCached(opts, tmpConfigPath, j.target, j.profile, j.region, runningOnly)
-				if len(cands) > 0 {
-					results <- scanResult{Candidates: cands}
+				if scanCtx.Err() != nil {
+					continue
 				}
+				cands, stale, _ := queryInstancesCachedStatus(scanCtx, opts, tmpConfigPath, j.target, j.profile, j.region, runningOnly)
+				found <- scanned{cands: opts.filter.filterInstances(cands), stale: stale}
 			}
 		}()
 	}
 
 	go func() {
 		wg.Wait()
-		close(results)
+		close(found)
 	}()
 
 	go func() {
+		defer close(jobs)
 		for _, t := range targets {
 			profile := profileNames[targetKey(t)]
 			for _, region := range regions {
-				jobs <- job{
+				select {
+				case jobs <- job{
 					target:  t,
 					profile: profile,
 					region:  region,
+				}:
+				case <-scanCtx.Done():
+					return
 				}
 			}
 		}
-		close(jobs)
 	}()
 
-	var all []instanceCandidate
-	for r := range results {
-		all = append(all, r.Candidates...)
-	}
-	return all
+	go func() {
+		defer close(out)
+		done, stale := 0, 0
+		for f := range found {
+			done++
+			if f.stale {
+				stale++
+			}
+			cands := f.cands
+			sort.Slice(cands, func(i, j int) bool {
+				return cands[i].DisplayLine < cands[j].DisplayLine
+			})
+			select {
+			case out <- scanResult{Candidates: cands, Done: done, Total: total, Stale: stale}:
+			case <-ctx.Done():
+			}
+		}
+		scanErr := context.Cause(scanCtx)
+		cancel()
+		if scanErr == nil {
+			return
+		}
+		select {
+		case out <- scanResult{Done: done, Total: total, Stale: stale, Err: scanErr}:
+		case <-ctx.Done():
+		}
+	}()
+	return out
 }
 
-func queryInstances(tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+func queryInstances(ctx context.Context, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
 	args := []string{"ec2", "describe-instances", "--region", region}
 	if runningOnly {
 		args = append(args, "--filters", "Name=instance-state-name,Values=running")
 	}
-	out, err := runAWSJSON(tmpConfigPath, profileName, args)
+	out, err := runAWSJSON(ctx, tmpConfigPath, profileName, args)
 	if err != nil {
 		// Silently ignore combinations that are not viable in this account/role/region.
 		return nil, err
@@ -238,57 +304,170 @@ func queryInstances(tmpConfigPath string, target roleTarget, profileName, region
 			if !runningOnly {
 				line = fmt.Sprintf("%s | state=%s | platform=%s", line, state, platform)
 			}
+			var groups []string
+			for _, sg := range inst.SecurityGroups {
+				groups = append(groups, fmt.Sprintf("%s (%s)", sg.GroupID, sg.GroupName))
+			}
+			tags := make(map[string]string, len(inst.Tags))
+			for _, t := range inst.Tags {
+				tags[t.Key] = t.Value
+			}
 			candidates = append(candidates, instanceCandidate{
-				DisplayLine: line,
-				ProfileName: profileName,
-				Region:      region,
-				InstanceID:  inst.InstanceID,
+				DisplayLine:        line,
+				ProfileName:        profileName,
+				Region:             region,
+				InstanceID:         inst.InstanceID,
+				AccountID:          target.AccountID,
+				AccountName:        target.AccountName,
+				RoleName:           target.RoleName,
+				Name:               name,
+				PrivateIP:          ip,
+				State:              state,
+				Platform:           platform,
+				InstanceType:       inst.InstanceType,
+				AvailabilityZone:   inst.Placement.AvailabilityZone,
+				LaunchTime:         inst.LaunchTime,
+				SecurityGroups:     groups,
+				IAMInstanceProfile: inst.IAMInstanceProfile.Arn,
+				Tags:               tags,
 			})
 		}
 	}
 	return candidates, nil
 }
 
-func queryInstancesCached(opts Options, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+// fetchInstances queries one account/role/region and, with --ssm-status,
+// the SSM agent ping status of what it found.
+func fetchInstances(ctx context.Context, opts Options, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+	candidates, err := queryInstancesFetcher(ctx, tmpConfigPath, target, profileName, region, runningOnly)
+	if err != nil || !opts.SSMStatus || len(candidates) == 0 {
+		return candidates, err
+	}
+	pings := querySSMPingStatusFn(ctx, tmpConfigPath, profileName, region)
+	for i := range candidates {
+		candidates[i].PingStatus = pings[candidates[i].InstanceID]
+	}
+	return candidates, nil
+}
+
+func querySSMPingStatus(ctx context.Context, tmpConfigPath, profileName, region string) map[string]string {
+	out, err := runAWSJSON(ctx, tmpConfigPath, profileName, []string{"ssm", "describe-instance-information", "--region", region})
+	if err != nil {
+		// The role may lack SSM read access; previews then show the status as unknown.
+		return nil
+	}
+	var resp ssmInstanceInformationResponse
+	if err := json.Unmarshal(out, &resp); err != nil {
+		return nil
+	}
+	pings := make(map[string]string, len(resp.InstanceInformationList))
+	for _, info := range resp.InstanceInformationList {
+		pings[info.InstanceID] = info.PingStatus
+	}
+	return pings
+}
+
+func queryInstancesCached(ctx context.Context, opts Options, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+	cands, _, err := queryInstancesCachedStatus(ctx, opts, tmpConfigPath, target, profileName, region, runningOnly)
+	return cands, err
+}
+
+// queryInstancesCachedStatus also reports whether the list came from a stale
+// cache entry that is being refreshed in the background. It prints nothing,
+// since it runs while the picker owns the terminal; the scan header shows
+// the stale count instead.
+func queryInstancesCachedStatus(ctx context.Context, opts Options, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, bool, error) {
+	opts = opts.forTarget(target)
 	key := cacheKeyInstances(opts.Profile, target.AccountID, target.RoleName, region, runningOnly)
 	var cached []instanceCandidate
 	if opts.cacheStore != nil {
-		status, age, err := opts.cacheStore.readJSON(opts.Profile, key, &cached)
+		status, _, err := opts.cacheStore.readJSON(opts.Profile, key, &cached)
 		if err == nil {
 			if status == cacheHitFresh {
-				return cached, nil
+				return cached, false, nil
 			}
 			if status == cacheHitStale && opts.cacheStore.shouldUseStale() {
-				fmt.Printf("Using cached instances for %s/%s/%s (stale, age=%s), refreshing...\n", target.AccountID, target.RoleName, region, age.Round(time.Second))
-				opts.cacheStore.refreshAsync(func() error {
-					fresh, fetchErr := queryInstancesFetcher(tmpConfigPath, target, profileName, region, runningOnly)
+				opts.cacheStore.refreshAsync(opts.Profile, key, func(refreshCtx context.Context) error {
+					callCtx, cancel := opts.callContext(refreshCtx)
+					defer cancel()
+					fresh, fetchErr := fetchInstances(callCtx, opts, tmpConfigPath, target, profileName, region, runningOnly)
 					if fetchErr != nil {
 						return fetchErr
 					}
 					return opts.cacheStore.writeJSON(opts.Profile, key, opts.CacheTTLInstances, fresh)
 				})
-				return cached, nil
+				return cached, true, nil
 			}
 		}
 	}
 
-	fresh, err := queryInstancesFetcher(tmpConfigPath, target, profileName, region, runningOnly)
+	callCtx, cancel := opts.callContext(ctx)
+	defer cancel()
+	fresh, err := fetchInstances(callCtx, opts, tmpConfigPath, target, profileName, region, runningOnly)
 	if err != nil {
-		return nil, err
+		return nil, false, err
 	}
 	if opts.cacheStore != nil {
 		_ = opts.cacheStore.writeJSON(opts.Profile, key, opts.CacheTTLInstances, fresh)
 	}
-	return fresh, nil
+	return fresh, false, nil
+}
+
+// startSSMSession opens an interactive session, using document instead of
+// the Session Manager default shell when one is set.
+func startSSMSession(tmpConfigPath, profile, region, instanceID, document string) error {
+	if document == "" {
+		return runSSMSession(tmpConfigPath, profile, region, instanceID)
+	}
+	return runSSMSession(tmpConfigPath, profile, region, instanceID, "--document-name", document)
 }
 
-func startSSMSession(tmpConfigPath, profile, region, instanceID string) error {
-	cmd := exec.Command("aws",
+func startPortForwardSession(tmpConfigPath, profile, region, instanceID string, remotePort, localPort int) error {
+	return runSSMSession(tmpConfigPath, profile, region, instanceID,
+		"--document-name", "AWS-StartPortForwardingSession",
+		"--parameters", fmt.Sprintf("portNumber=%d,localPortNumber=%d", remotePort, localPort),
+	)
+}
+
+// promptPortForward asks for the remote port and an optional local port,
+// which defaults to the remote one.
+func promptPortForward(in io.Reader, out io.Writer) (int, int, error) {
+	reader := bufio.NewReader(in)
+	readPort := func(prompt string, fallback int) (int, error) {
+		fmt.Fprint(out, prompt)
+		line, err := reader.ReadString('\n')
+		if err != nil && line == "" {
+			return 0, err
+		}
+		line = strings.TrimSpace(line)
+		if line == "" && fallback > 0 {
+			return fallback, nil
+		}
+		port, convErr := strconv.Atoi(line)
+		if convErr != nil || port < 1 || port > 65535 {
+			return 0, fmt.Errorf("invalid port %q", line)
+		}
+		return port, nil
+	}
+	remote, err := readPort("Remote port: ", 0)
+	if err != nil {
+		return 0, 0, err
+	}
+	local, err := readPort(fmt.Sprintf("Local port [%d]: ", remote), remote)
+	if err != nil {
+		return 0, 0, err
+	}
+	return remote, local, nil
+}
+
+func runSSMSession(tmpConfigPath, profile, region, instanceID string, extraArgs ...string) error {
+	args := []string{
 		"--profile", profile,
 		"--region", region,
 		"ssm", "start-session",
 		"--target", instanceID,
-	)
+	}
+	cmd := exec.Command("aws", append(args, extraArgs...)...)
 	cmd.Env = append(os.Environ(),
 		"AWS_SDK_LOAD_CONFIG=1",
 		"AWS_CONFIG_FILE="+tmpConfigPath,
@@ -321,7 +500,7 @@ func startSSMSession(tmpConfigPath, profile, region, instanceID string) error {
 	}
 }
 
-func runAWSJSON(tmpConfigPath, profile string, args []string) ([]byte, error) {
+func runAWSJSON(ctx context.Context, tmpConfigPath, profile string, args []string) ([]byte, error) {
 	fullArgs := []string{}
 	if strings.TrimSpace(profile) != "" {
 		fullArgs = append(fullArgs, "--profile", profile)
@@ -329,7 +508,7 @@ func runAWSJSON(tmpConfigPath, profile string, args []string) ([]byte, error) {
 	fullArgs = append(fullArgs, args...)
 	fullArgs = append(fullArgs, "--output", "json")
 
-	cmd := exec.Command("aws", fullArgs...)
+	cmd := exec.CommandContext(ctx, "aws", fullArgs...)
 	if strings.TrimSpace(tmpConfigPath) != "" {
 		cmd.Env = append(os.Environ(),
 			"AWS_SDK_LOAD_CONFIG=1",
@@ -341,6 +520,9 @@ func runAWSJSON(tmpConfigPath, profile string, args []string) ([]byte, error) {
 	cmd.Stderr = &stderr
 	out, err := cmd.Output()
 	if err != nil {
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("%w (aws %s)", context.Cause(ctx), strings.Join(redactSensitiveArgs(fullArgs), " "))
+		}
 		msg := strings.TrimSpace(stderr.String())
 		if msg == "" {
 			msg = err.Error()
@@ -1,9 +1,11 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,14 +19,14 @@ var (
 	fetchRolesForAcctFetcher = fetchRolesForAccount
 )
 
-func ensureSSOLoginAndGetToken(profile, preferredStartURL string) (string, error) {
+func ensureSSOLoginAndGetToken(ctx context.Context, out io.Writer, profile, preferredStartURL string) (string, error) {
 	// Fast path: if an unexpired token already exists, skip login.
 	if tok, err := loadSSOAccessToken(preferredStartURL); err == nil {
 		return tok, nil
 	}
 
-	login := exec.Command("aws", "sso", "login", "--profile", profile)
-	login.Stdout = os.Stdout
+	login := exec.CommandContext(ctx, "aws", "sso", "login", "--profile", profile)
+	login.Stdout = out
 	login.Stderr = os.Stderr
 	if err := login.Run(); err != nil {
 		return "", err
@@ -32,8 +34,8 @@ func ensureSSOLoginAndGetToken(profile, preferredStartURL string) (string, error
 	return loadSSOAccessToken(preferredStartURL)
 }
 
-func listSSOAccounts(profile, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
-	out, err := runAWSJSON("", profile, []string{
+func listSSOAccounts(ctx context.Context, profile, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
+	out, err := runAWSJSON(ctx, "", profile, []string{
 		"sso", "list-accounts",
 		"--region", ssoRegion,
 		"--access-token", accessToken,
@@ -65,20 +67,22 @@ func listSSOAccounts(profile, ssoRegion, accessToken string) ([]ssoAccountsRespo
 	return outAccounts, nil
 }
 
-func listSSOAccountsCached(opts Options, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
+func listSSOAccountsCached(ctx context.Context, opts Options, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
 	key := cacheKeyAccounts(opts.Profile, ssoRegion)
 	var cached []ssoAccountsResponse
 	if opts.cacheStore != nil {
 		status, age, err := opts.cacheStore.readJSON(opts.Profile, key, &cached)
 		if err == nil {
 			if status == cacheHitFresh {
-				fmt.Printf("Using cached accounts (age=%s)\n", age.Round(time.Second))
+				fmt.Fprintf(opts.progressOut(), "Using cached accounts (age=%s)\n", age.Round(time.Second))
 				return cached, nil
 			}
 			if status == cacheHitStale && opts.cacheStore.shouldUseStale() {
-				fmt.Printf("Using cached accounts (stale, age=%s), refreshing...\n", age.Round(time.Second))
-				opts.cacheStore.refreshAsync(func() error {
-					fresh, fetchErr := listSSOAccountsFetcher(opts.Profile, ssoRegion, accessToken)
+				fmt.Fprintf(opts.progressOut(), "Using cached accounts (stale, age=%s), refreshing...\n", age.Round(time.Second))
+				opts.cacheStore.refreshAsync(opts.Profile, key, func(refreshCtx context.Context) error {
+					callCtx, cancel := opts.callContext(refreshCtx)
+					defer cancel()
+					fresh, fetchErr := listSSOAccountsFetcher(callCtx, opts.Profile, ssoRegion, accessToken)
 					if fetchErr != nil {
 						return fetchErr
 					}
@@ -89,7 +93,9 @@ func listSSOAccountsCached(opts Options, ssoRegion, accessToken string) ([]ssoAc
 		}
 	}
 
-	fresh, err := listSSOAccountsFetcher(opts.Profile, ssoRegion, accessToken)
+	callCtx, cancel := opts.callContext(ctx)
+	defer cancel()
+	fresh, err := listSSOAccountsFetcher(callCtx, opts.Profile, ssoRegion, accessToken)
 	if err != nil {
 		return nil, err
 	}
@@ -99,7 +105,7 @@ func listSSOAccountsCached(opts Options, ssoRegion, accessToken string) ([]ssoAc
 	return fresh, nil
 }
 
-func buildRoleTargets(opts Options, ssoRegion, accessToken string, accounts []ssoAccountsResponse, workers int) ([]roleTarget, error) {
+func buildRoleTargets(ctx context.Context, opts Options, ssoRegion, accessToken string, accounts []ssoAccountsResponse, workers int) ([]roleTarget, error) {
 	type acctJob struct {
 		AccountID   string
 		AccountName string
@@ -118,7 +124,10 @@ func buildRoleTargets(opts Options, ssoRegion, accessToken string, accounts []ss
 		go func() {
 			defer wg.Done()
 			for j := range jobs {
-				out, err := listRolesForAccountCached(opts, ssoRegion, accessToken, j.AccountID, j.AccountName)
+				if ctx.Err() != nil {
+					continue
+				}
+				out, err := listRolesForAccountCached(ctx, opts, ssoRegion, accessToken, j.AccountID, j.AccountName)
 				if err != nil {
 					results <- acctResult{Err: err}
 					continue
@@ -129,17 +138,21 @@ func buildRoleTargets(opts Options, ssoRegion, accessToken string, accounts []ss
 	}
 
 	go func() {
+		defer close(jobs)
 		for _, acctWrap := range accounts {
 			if len(acctWrap.AccountList) == 0 {
 				continue
 			}
 			acct := acctWrap.AccountList[0]
-			jobs <- acctJob{
+			select {
+			case jobs <- acctJob{
 				AccountID:   acct.AccountID,
 				AccountName: acct.AccountName,
+			}:
+			case <-ctx.Done():
+				return
 			}
 		}
-		close(jobs)
 	}()
 
 	go func() {
@@ -155,13 +168,16 @@ func buildRoleTargets(opts Options, ssoRegion, accessToken string, accounts []ss
 		}
 		targets = append(targets, r.Targets...)
 	}
+	if ctx.Err() != nil {
+		return nil, context.Cause(ctx)
+	}
 	if firstErr != nil {
 		return nil, firstErr
 	}
 	return targets, nil
 }
 
-func listRolesForAccountCached(opts Options, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
+func listRolesForAccountCached(ctx context.Context, opts Options, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
 	key := cacheKeyRoles(opts.Profile, ssoRegion, accountID)
 	var cached []roleTarget
 	if opts.cacheStore != nil {
@@ -171,9 +187,11 @@ func listRolesForAccountCached(opts Options, ssoRegion, accessToken, accountID,
 				return cached, nil
 			}
 			if status == cacheHitStale && opts.cacheStore.shouldUseStale() {
-				fmt.Printf("Using cached roles for account %s (stale, age=%s), refreshing...\n", accountID, age.Round(time.Second))
-				opts.cacheStore.refreshAsync(func() error {
-					fresh, fetchErr := fetchRolesForAcctFetcher(opts.Profile, ssoRegion, accessToken, accountID, accountName)
+				fmt.Fprintf(opts.progressOut(), "Using cached roles for account %s (stale, age=%s), refreshing...\n", accountID, age.Round(time.Second))
+				opts.cacheStore.refreshAsync(opts.Profile, key, func(refreshCtx context.Context) error {
+					callCtx, cancel := opts.callContext(refreshCtx)
+					defer cancel()
+					fresh, fetchErr := fetchRolesForAcctFetcher(callCtx, opts.Profile, ssoRegion, accessToken, accountID, accountName)
 					if fetchErr != nil {
 						return fetchErr
 					}
@@ -184,7 +202,9 @@ func listRolesForAccountCached(opts Options, ssoRegion, accessToken, accountID,
 		}
 	}
 
-	fresh, err := fetchRolesForAcctFetcher(opts.Profile, ssoRegion, accessToken, accountID, accountName)
+	callCtx, cancel := opts.callContext(ctx)
+	defer cancel()
+	fresh, err := fetchRolesForAcctFetcher(callCtx, opts.Profile, ssoRegion, accessToken, accountID, accountName)
 	if err != nil {
 		return nil, err
 	}
@@ -194,8 +214,8 @@ func listRolesForAccountCached(opts Options, ssoRegion, accessToken, accountID,
 	return fresh, nil
 }
 
-func fetchRolesForAccount(profile, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
-	rolesOut, err := runAWSJSON("", profile, []string{
+func fetchRolesForAccount(ctx context.Context, profile, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
+	rolesOut, err := runAWSJSON(ctx, "", profile, []string{
 		"sso", "list-account-roles",
 		"--region", ssoRegion,
 		"--access-token", accessToken,
@@ -1,21 +1,39 @@
 package app
 
 import (
+	"context"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
 	"path/filepath"
 	"strings"
 	"sync"
+	"syscall"
 	"time"
 )
 
+// refreshGracePeriod is how long swamp waits on exit for background cache
+// refreshes that are still queued or running.
+const refreshGracePeriod = 15 * time.Second
+
 type cacheStore struct {
-	cfg cacheConfig
-	mu  sync.Mutex
-	sem chan struct{}
+	cfg   cacheConfig
+	mu    sync.Mutex
+	sem   chan struct{}
+	ctx   context.Context
+	stats map[string]cacheKindStats
+	// refreshing holds keys with a background refresh queued or running;
+	// pending counts them.
+	refreshing     map[string]bool
+	pending        int
+	idle           chan struct{}
+	removeWhenIdle []string
+	refreshCtx     context.Context
+	stopRefreshes  context.CancelFunc
 }
 
 func defaultCacheDir() string {
@@ -71,19 +89,24 @@ func (c *cacheStore) readJSON(profile, key string, out any) (cacheReadStatus, ti
 	}
 	env, err := c.readEnvelope(profile, key)
 	if err != nil {
+		c.recordRead(key, cacheMiss)
 		return cacheMiss, 0, err
 	}
 	if env == nil {
+		c.recordRead(key, cacheMiss)
 		return cacheMiss, 0, nil
 	}
 	if err := json.Unmarshal(env.Payload, out); err != nil {
+		c.recordRead(key, cacheMiss)
 		return cacheMiss, 0, nil
 	}
 
 	age := time.Since(env.CreatedAt)
 	if time.Now().After(env.ExpiresAt) {
+		c.recordRead(key, cacheHitStale)
 		return cacheHitStale, age, nil
 	}
+	c.recordRead(key, cacheHitFresh)
 	return cacheHitFresh, age, nil
 }
 
@@ -94,7 +117,7 @@ func (c *cacheStore) writeJSON(profile, key string, ttl time.Duration, payload a
 	if ttl <= 0 {
 		return nil
 	}
-	if err := os.MkdirAll(c.cfg.Dir, 0o755); err != nil {
+	if err := mkdirPrivate(c.cfg.Dir); err != nil {
 		return err
 	}
 
@@ -115,6 +138,9 @@ func (c *cacheStore) writeJSON(profile, key string, ttl time.Duration, payload a
 	if err != nil {
 		return err
 	}
+	if content, err = sealAtRest(content); err != nil {
+		return err
+	}
 
 	path := c.filePath(profile, key)
 	tmp, err := os.CreateTemp(c.cfg.Dir, "swamp-cache-*.tmp")
@@ -150,8 +176,14 @@ func (c *cacheStore) readEnvelope(profile, key string) (*cacheEnvelope, error) {
 		}
 		return nil, err
 	}
+	plain, err := openAtRest(data)
+	if err != nil {
+		// Sealed with another key: a miss, but the file is left for
+		// `swamp cache prune --undecryptable`.
+		return nil, nil
+	}
 	var env cacheEnvelope
-	if err := json.Unmarshal(data, &env); err != nil {
+	if json.Unmarshal(plain, &env) != nil {
 		_ = os.Remove(path)
 		return nil, nil
 	}
@@ -168,6 +200,43 @@ func (c *cacheStore) filePath(profile, key string) string {
 	return filepath.Join(c.cfg.Dir, filename)
 }
 
+func (c *cacheStore) listEntries(profile string) ([]cacheEntry, error) {
+	if strings.TrimSpace(c.cfg.Dir) == "" {
+		return nil, nil
+	}
+	files, err := os.ReadDir(c.cfg.Dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	var entries []cacheEntry
+	for _, f := range files {
+		if f.IsDir() || !strings.HasSuffix(f.Name(), ".json") || isCacheStateFile(f.Name()) {
+			continue
+		}
+		path := filepath.Join(c.cfg.Dir, f.Name())
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		plain, err := openAtRest(data)
+		if err != nil {
+			continue
+		}
+		var env cacheEnvelope
+		if err := json.Unmarshal(plain, &env); err != nil || env.Version != cacheVersion {
+			continue
+		}
+		if profile != "" && env.Profile != profile {
+			continue
+		}
+		entries = append(entries, cacheEntry{Path: path, Size: int64(len(data)), Envelope: env})
+	}
+	return entries, nil
+}
+
 func (c *cacheStore) clear() error {
 	if !c.isEnabled() {
 		return nil
@@ -181,21 +250,143 @@ func (c *cacheStore) clear() error {
 	return os.RemoveAll(c.cfg.Dir)
 }
 
-func (c *cacheStore) refreshAsync(fn func() error) {
+// refreshAsync refreshes a stale key in the background. At most one
+// refresh per key runs at a time, both within this process and across
+// concurrent swamp processes sharing the cache directory. Refreshes beyond
+// the concurrency limit wait their turn rather than being dropped, and they
+// are not tied to the interactive context: Ctrl-C inside an SSM session
+// must not abort them (see waitForRefreshes).
+func (c *cacheStore) refreshAsync(profile, key string, fn func(ctx context.Context) error) {
 	if !c.isEnabled() {
 		return
 	}
-	select {
-	case c.sem <- struct{}{}:
-	default:
+	c.mu.Lock()
+	if c.refreshing[key] {
+		c.mu.Unlock()
 		return
 	}
+	if c.refreshing == nil {
+		c.refreshing = map[string]bool{}
+	}
+	c.refreshing[key] = true
+	c.pending++
+	if c.refreshCtx == nil {
+		base := c.ctx
+		if base == nil {
+			base = context.Background()
+		}
+		c.refreshCtx, c.stopRefreshes = context.WithCancel(context.WithoutCancel(base))
+	}
+	ctx := c.refreshCtx
+	c.mu.Unlock()
+
 	go func() {
+		defer c.finishRefresh(key)
+		select {
+		case c.sem <- struct{}{}:
+		case <-ctx.Done():
+			return
+		}
 		defer func() { <-c.sem }()
-		_ = fn()
+		lock, ok, err := c.acquireRefreshLock(profile, key)
+		if err == nil {
+			if !ok {
+				// Another process is already refreshing this key.
+				return
+			}
+			defer lock.release()
+			// Another process may have finished a refresh since the stale read.
+			if env, _ := c.readEnvelope(profile, key); env != nil && time.Now().Before(env.ExpiresAt) {
+				return
+			}
+		}
+		_ = fn(ctx)
 	}()
 }
 
+func (c *cacheStore) finishRefresh(key string) {
+	c.mu.Lock()
+	delete(c.refreshing, key)
+	c.pending--
+	var files []string
+	if c.pending == 0 {
+		files = c.removeWhenIdle
+		c.removeWhenIdle = nil
+		if c.idle != nil {
+			close(c.idle)
+			c.idle = nil
+		}
+	}
+	c.mu.Unlock()
+	for _, path := range files {
+		_ = removeFileFn(path)
+	}
+}
+
+// removeAfterRefreshes deletes a temporary file (such as the temp AWS
+// config) once no queued refresh can still need it.
+func (c *cacheStore) removeAfterRefreshes(path string) {
+	if c != nil {
+		c.mu.Lock()
+		if c.pending > 0 {
+			c.removeWhenIdle = append(c.removeWhenIdle, path)
+			c.mu.Unlock()
+			return
+		}
+		c.mu.Unlock()
+	}
+	_ = removeFileFn(path)
+}
+
+// waitForRefreshes gives queued background refreshes up to grace to finish
+// before the process exits. An interrupt skips the wait.
+func (c *cacheStore) waitForRefreshes(w io.Writer, grace time.Duration) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	pending := c.pending
+	if pending > 0 && c.idle == nil {
+		c.idle = make(chan struct{})
+	}
+	idle := c.idle
+	c.mu.Unlock()
+
+	if pending > 0 {
+		fmt.Fprintf(w, "Finishing %d background cache refresh(es) (Ctrl-C to skip)...\n", pending)
+		sigCtx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		timer := time.NewTimer(grace)
+		select {
+		case <-idle:
+		case <-timer.C:
+		case <-sigCtx.Done():
+		}
+		timer.Stop()
+		stop()
+	}
+
+	c.mu.Lock()
+	if c.stopRefreshes != nil {
+		c.stopRefreshes()
+		c.refreshCtx, c.stopRefreshes = nil, nil
+	}
+	files := c.removeWhenIdle
+	c.removeWhenIdle = nil
+	c.mu.Unlock()
+	for _, path := range files {
+		_ = removeFileFn(path)
+	}
+}
+
+func (c *cacheStore) acquireRefreshLock(profile, key string) (*fileLock, bool, error) {
+	dir := filepath.Join(c.cfg.Dir, lockDirName)
+	if err := mkdirPrivate(dir); err != nil {
+		return nil, false, err
+	}
+	name := strings.TrimSuffix(filepath.Base(c.filePath(profile, key)), ".json") + ".lock"
+	return acquireFileLock(filepath.Join(dir, name), false)
+}
+
 func cacheKeyAccounts(profile, ssoRegion string) string {
 	return fmt.Sprintf("accounts:%s:%s", profile, ssoRegion)
 }
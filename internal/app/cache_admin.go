@@ -0,0 +1,466 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const cacheStatsFileName = "cache_stats.json"
+
+// orphanTempAge is how old a leftover temp file must be before prune
+// removes it, so writes that are still in flight are left alone.
+const orphanTempAge = time.Hour
+
+// cacheStatsFile accumulates read outcomes across runs, per cache kind.
+type cacheStatsFile struct {
+	Version int                       `json:"version"`
+	Since   time.Time                 `json:"since"`
+	Kinds   map[string]cacheKindStats `json:"kinds"`
+}
+
+type cacheKindStats struct {
+	FreshHits int64 `json:"fresh_hits"`
+	StaleHits int64 `json:"stale_hits"`
+	Misses    int64 `json:"misses"`
+}
+
+// cacheKeyInfo is a cache key split back into its parts; fields a kind
+// does not carry are left empty.
+type cacheKeyInfo struct {
+	Kind      string
+	Profile   string
+	AccountID string
+	Role      string
+	Region    string
+}
+
+func isCacheStateFile(name string) bool {
+	return name == recentTargetsFileName || name == cacheStatsFileName
+}
+
+func parseCacheKey(key string) cacheKeyInfo {
+	parts := strings.Split(key, ":")
+	info := cacheKeyInfo{Kind: parts[0]}
+	if len(parts) > 1 {
+		info.Profile = parts[1]
+	}
+	switch {
+	case info.Kind == "roles" && len(parts) == 4:
+		info.AccountID = parts[3]
+	case info.Kind == "instances" && len(parts) == 6:
+		info.AccountID = parts[2]
+		info.Role = parts[3]
+		info.Region = parts[4]
+	}
+	return info
+}
+
+func (c *cacheStore) recordRead(key string, status cacheReadStatus) {
+	kind := parseCacheKey(key).Kind
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stats == nil {
+		c.stats = map[string]cacheKindStats{}
+	}
+	s := c.stats[kind]
+	switch status {
+	case cacheHitFresh:
+		s.FreshHits++
+	case cacheHitStale:
+		s.StaleHits++
+	default:
+		s.Misses++
+	}
+	c.stats[kind] = s
+}
+
+// flushStats adds this run's read counts to the stats file.
+func (c *cacheStore) flushStats() error {
+	if !c.isEnabled() || strings.TrimSpace(c.cfg.Dir) == "" {
+		return nil
+	}
+	c.mu.Lock()
+	pending := c.stats
+	c.stats = nil
+	c.mu.Unlock()
+	if len(pending) == 0 {
+		return nil
+	}
+
+	return withFileLock(filepath.Join(c.cfg.Dir, cacheStatsFileName), func() error {
+		return addCacheStats(c.cfg.Dir, pending)
+	})
+}
+
+func addCacheStats(dir string, pending map[string]cacheKindStats) error {
+	all, err := loadCacheStats(dir)
+	if err != nil {
+		return err
+	}
+	for kind, s := range pending {
+		total := all.Kinds[kind]
+		total.FreshHits += s.FreshHits
+		total.StaleHits += s.StaleHits
+		total.Misses += s.Misses
+		all.Kinds[kind] = total
+	}
+	content, err := json.MarshalIndent(all, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "swamp-cache-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, filepath.Join(dir, cacheStatsFileName)); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+func loadCacheStats(dir string) (cacheStatsFile, error) {
+	fresh := cacheStatsFile{Version: 1, Since: time.Now().UTC(), Kinds: map[string]cacheKindStats{}}
+	data, err := os.ReadFile(filepath.Join(dir, cacheStatsFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return fresh, nil
+		}
+		return cacheStatsFile{}, err
+	}
+	var out cacheStatsFile
+	if err := json.Unmarshal(data, &out); err != nil {
+		return fresh, nil
+	}
+	if out.Kinds == nil {
+		out.Kinds = map[string]cacheKindStats{}
+	}
+	return out, nil
+}
+
+// adminCacheStore opens the cache for maintenance commands, regardless of
+// whether caching is enabled for normal runs.
+func adminCacheStore(opts Options) (*cacheStore, Options, error) {
+	resolved, err := resolveLocalOptions(opts)
+	if err != nil {
+		return nil, Options{}, err
+	}
+	resolved.CacheEnabled = true
+	return newCacheStore(resolved), resolved, nil
+}
+
+func sortCacheEntries(entries []cacheEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].Envelope.Profile != entries[j].Envelope.Profile {
+			return entries[i].Envelope.Profile < entries[j].Envelope.Profile
+		}
+		return entries[i].Envelope.Key < entries[j].Envelope.Key
+	})
+}
+
+func CacheList(opts Options, profile, kind string) error {
+	store, _, err := adminCacheStore(opts)
+	if err != nil {
+		return err
+	}
+	return writeCacheList(os.Stdout, store, profile, kind, time.Now())
+}
+
+func writeCacheList(w io.Writer, store *cacheStore, profile, kind string, now time.Time) error {
+	entries, err := store.listEntries(profile)
+	if err != nil {
+		return fmt.Errorf("read cache: %w", err)
+	}
+	sortCacheEntries(entries)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tPROFILE\tKEY\tAGE\tEXPIRES\tSIZE")
+	shown := 0
+	for _, e := range entries {
+		info := parseCacheKey(e.Envelope.Key)
+		if kind != "" && info.Kind != kind {
+			continue
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n",
+			info.Kind, e.Envelope.Profile, e.Envelope.Key,
+			formatAge(now.Sub(e.Envelope.CreatedAt)),
+			formatExpiry(e.Envelope.ExpiresAt, now),
+			formatBytes(e.Size))
+		shown++
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "%d entries in %s\n", shown, store.cfg.Dir)
+	return nil
+}
+
+func CacheStats(opts Options, profile string) error {
+	store, _, err := adminCacheStore(opts)
+	if err != nil {
+		return err
+	}
+	return writeCacheStats(os.Stdout, store, profile, time.Now())
+}
+
+func writeCacheStats(w io.Writer, store *cacheStore, profile string, now time.Time) error {
+	entries, err := store.listEntries(profile)
+	if err != nil {
+		return fmt.Errorf("read cache: %w", err)
+	}
+	stats, err := loadCacheStats(store.cfg.Dir)
+	if err != nil {
+		return fmt.Errorf("read cache stats: %w", err)
+	}
+
+	type kindSummary struct {
+		entries, expired int
+		size             int64
+	}
+	summaries := map[string]*kindSummary{}
+	kinds := []string{"accounts", "roles", "regions", "instances"}
+	for _, e := range entries {
+		kind := parseCacheKey(e.Envelope.Key).Kind
+		if summaries[kind] == nil {
+			summaries[kind] = &kindSummary{}
+			if !containsString(kinds, kind) {
+				kinds = append(kinds, kind)
+			}
+		}
+		s := summaries[kind]
+		s.entries++
+		s.size += e.Size
+		if now.After(e.Envelope.ExpiresAt) {
+			s.expired++
+		}
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "KIND\tENTRIES\tEXPIRED\tSIZE\tFRESH HITS\tSTALE HITS\tMISSES\tHIT RATE")
+	var totalEntries, totalExpired int
+	var totalSize int64
+	var total cacheKindStats
+	for _, kind := range kinds {
+		s := summaries[kind]
+		if s == nil {
+			s = &kindSummary{}
+		}
+		reads := stats.Kinds[kind]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%s\t%d\t%d\t%d\t%s\n", kind, s.entries, s.expired, formatBytes(s.size), reads.FreshHits, reads.StaleHits, reads.Misses, hitRate(reads))
+		totalEntries += s.entries
+		totalExpired += s.expired
+		totalSize += s.size
+		total.FreshHits += reads.FreshHits
+		total.StaleHits += reads.StaleHits
+		total.Misses += reads.Misses
+	}
+	fmt.Fprintf(tw, "total\t%d\t%d\t%s\t%d\t%d\t%d\t%s\n", totalEntries, totalExpired, formatBytes(totalSize), total.FreshHits, total.StaleHits, total.Misses, hitRate(total))
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Hit/miss counts are for all profiles since %s.\n", stats.Since.Local().Format("2006-01-02 15:04"))
+	return nil
+}
+
+func CachePrune(opts Options, dryRun, undecryptable bool) error {
+	store, _, err := adminCacheStore(opts)
+	if err != nil {
+		return err
+	}
+	return pruneCache(os.Stdout, store, dryRun, undecryptable, time.Now())
+}
+
+// pruneCache removes expired entries and orphaned files: leftover temp
+// files, files that no longer decode or use an old cache version, entries
+// stored under a name that does not match their key, and plain entries
+// left over from before encryption was turned on. Files sealed with a
+// different key are only removed when undecryptable is set.
+func pruneCache(w io.Writer, store *cacheStore, dryRun, undecryptable bool, now time.Time) error {
+	dir := store.cfg.Dir
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			fmt.Fprintln(w, "Cache directory does not exist; nothing to prune.")
+			return nil
+		}
+		return err
+	}
+
+	var expired, orphaned, locked int
+	var freed int64
+	remove := func(path string, size int64, reason string) {
+		fmt.Fprintf(w, "%s %s (%s)\n", pruneVerb(dryRun), filepath.Base(path), reason)
+		if !dryRun {
+			if err := os.Remove(path); err != nil {
+				fmt.Fprintf(w, "warning: %v\n", err)
+				return
+			}
+		}
+		freed += size
+	}
+	for _, f := range files {
+		if f.IsDir() || isCacheStateFile(f.Name()) {
+			continue
+		}
+		path := filepath.Join(dir, f.Name())
+		info, err := f.Info()
+		if err != nil {
+			continue
+		}
+		if strings.HasSuffix(f.Name(), ".tmp") {
+			if now.Sub(info.ModTime()) > orphanTempAge {
+				remove(path, info.Size(), "leftover temp file")
+				orphaned++
+			}
+			continue
+		}
+		if !strings.HasSuffix(f.Name(), ".json") {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		plain, openErr := openAtRest(data)
+		var env cacheEnvelope
+		switch {
+		case openErr != nil && !undecryptable:
+			locked++
+		case openErr != nil:
+			remove(path, info.Size(), "cannot decrypt")
+			orphaned++
+		case atRestSecret != "" && !isSealed(data):
+			remove(path, info.Size(), "not encrypted")
+			orphaned++
+		case json.Unmarshal(plain, &env) != nil:
+			remove(path, info.Size(), "unreadable")
+			orphaned++
+		case env.Version != cacheVersion:
+			remove(path, info.Size(), fmt.Sprintf("cache version %d", env.Version))
+			orphaned++
+		case store.filePath(env.Profile, env.Key) != path:
+			remove(path, info.Size(), "name does not match key")
+			orphaned++
+		case now.After(env.ExpiresAt):
+			remove(path, info.Size(), "expired "+formatAge(now.Sub(env.ExpiresAt))+" ago")
+			expired++
+		}
+	}
+	fmt.Fprintf(w, "%s %d expired and %d orphaned files (%s).\n", pruneVerb(dryRun), expired, orphaned, formatBytes(freed))
+	if locked > 0 {
+		fmt.Fprintf(w, "Kept %d files that cannot be decrypted with the current key; use --undecryptable to remove them.\n", locked)
+	}
+	return nil
+}
+
+// CacheInvalidateFilter selects cache entries to drop. Empty fields match
+// anything, but at least one must be set.
+type CacheInvalidateFilter struct {
+	Profile   string
+	AccountID string
+	Role      string
+	Region    string
+}
+
+func CacheInvalidate(opts Options, filter CacheInvalidateFilter, dryRun bool) error {
+	store, _, err := adminCacheStore(opts)
+	if err != nil {
+		return err
+	}
+	return invalidateCache(os.Stdout, store, filter, dryRun)
+}
+
+func invalidateCache(w io.Writer, store *cacheStore, filter CacheInvalidateFilter, dryRun bool) error {
+	if filter.AccountID == "" && filter.Role == "" && filter.Region == "" {
+		return fmt.Errorf("pass at least one of --account, --role or --region")
+	}
+	entries, err := store.listEntries(filter.Profile)
+	if err != nil {
+		return fmt.Errorf("read cache: %w", err)
+	}
+	sortCacheEntries(entries)
+
+	dropped := 0
+	for _, e := range entries {
+		info := parseCacheKey(e.Envelope.Key)
+		if filter.AccountID != "" && info.AccountID != filter.AccountID {
+			continue
+		}
+		if filter.Role != "" && info.Role != filter.Role {
+			continue
+		}
+		if filter.Region != "" && info.Region != filter.Region {
+			continue
+		}
+		fmt.Fprintf(w, "%s %s\n", pruneVerb(dryRun), e.Envelope.Key)
+		if !dryRun {
+			if err := os.Remove(e.Path); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+		}
+		dropped++
+	}
+	fmt.Fprintf(w, "%s %d entries.\n", pruneVerb(dryRun), dropped)
+	return nil
+}
+
+func pruneVerb(dryRun bool) string {
+	if dryRun {
+		return "Would remove"
+	}
+	return "Removed"
+}
+
+func hitRate(s cacheKindStats) string {
+	reads := s.FreshHits + s.StaleHits + s.Misses
+	if reads == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%.0f%%", float64(s.FreshHits+s.StaleHits)*100/float64(reads))
+}
+
+func formatAge(d time.Duration) string {
+	switch {
+	case d < time.Minute:
+		return d.Round(time.Second).String()
+	case d < time.Hour:
+		return d.Round(time.Minute).String()
+	default:
+		return d.Round(time.Hour).String()
+	}
+}
+
+func formatExpiry(expiresAt, now time.Time) string {
+	if now.After(expiresAt) {
+		return "expired " + formatAge(now.Sub(expiresAt)) + " ago"
+	}
+	return "in " + formatAge(expiresAt.Sub(now))
+}
+
+func formatBytes(n int64) string {
+	switch {
+	case n >= 1<<20:
+		return fmt.Sprintf("%.1f MiB", float64(n)/(1<<20))
+	case n >= 1<<10:
+		return fmt.Sprintf("%.1f KiB", float64(n)/(1<<10))
+	default:
+		return fmt.Sprintf("%d B", n)
+	}
+}
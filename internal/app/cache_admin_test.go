@@ -0,0 +1,187 @@
+package app
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseCacheKey(t *testing.T) {
+	got := parseCacheKey(cacheKeyInstances("p", "123456789012", "Admin", "eu-west-1", true))
+	if got.Kind != "instances" || got.Profile != "p" || got.AccountID != "123456789012" || got.Role != "Admin" || got.Region != "eu-west-1" {
+		t.Fatalf("unexpected instances key info %+v", got)
+	}
+	got = parseCacheKey(cacheKeyRoles("p", "us-east-1", "123456789012"))
+	if got.Kind != "roles" || got.AccountID != "123456789012" || got.Region != "" {
+		t.Fatalf("unexpected roles key info %+v", got)
+	}
+}
+
+func TestCacheStatsAreRecordedAndFlushed(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	key := cacheKeyAccounts(opts.Profile, "us-east-1")
+	var out []ssoAccountsResponse
+	_, _, _ = opts.cacheStore.readJSON(opts.Profile, key, &out)
+	if err := opts.cacheStore.writeJSON(opts.Profile, key, time.Minute, []ssoAccountsResponse{testAccount("111", "a")}); err != nil {
+		t.Fatal(err)
+	}
+	_, _, _ = opts.cacheStore.readJSON(opts.Profile, key, &out)
+	if err := opts.cacheStore.flushStats(); err != nil {
+		t.Fatalf("flushStats: %v", err)
+	}
+	// A second run adds to the persisted counts.
+	_, _, _ = opts.cacheStore.readJSON(opts.Profile, key, &out)
+	if err := opts.cacheStore.flushStats(); err != nil {
+		t.Fatalf("flushStats: %v", err)
+	}
+
+	stats, err := loadCacheStats(opts.CacheDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := stats.Kinds["accounts"]; got.FreshHits != 2 || got.Misses != 1 {
+		t.Fatalf("unexpected accounts stats %+v", got)
+	}
+
+	var buf bytes.Buffer
+	if err := writeCacheStats(&buf, opts.cacheStore, "", time.Now()); err != nil {
+		t.Fatalf("writeCacheStats: %v", err)
+	}
+	if !strings.Contains(buf.String(), "67%") {
+		t.Fatalf("expected hit rate in stats output, got:\n%s", buf.String())
+	}
+}
+
+func TestWriteCacheListShowsEntries(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	if err := opts.cacheStore.writeJSON(opts.Profile, cacheKeyRegions(opts.Profile, "swamp-1", "us-east-1", false), time.Hour, []string{"us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := opts.cacheStore.writeJSON("other", cacheKeyAccounts("other", "us-east-1"), time.Hour, []string{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := writeCacheList(&buf, opts.cacheStore, opts.Profile, "", time.Now()); err != nil {
+		t.Fatalf("writeCacheList: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "regions:test-profile:swamp-1") || strings.Contains(out, "accounts:other") {
+		t.Fatalf("unexpected list output:\n%s", out)
+	}
+	if !strings.Contains(out, "in 1h0m0s") || !strings.Contains(out, "1 entries") {
+		t.Fatalf("expected expiry and count in output:\n%s", out)
+	}
+}
+
+func TestPruneCacheRemovesExpiredAndOrphanedFiles(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	store := opts.cacheStore
+	dir := opts.CacheDir
+	liveKey := cacheKeyAccounts(opts.Profile, "us-east-1")
+	expiredKey := cacheKeyAccounts(opts.Profile, "eu-west-1")
+	if err := store.writeJSON(opts.Profile, liveKey, time.Hour, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := store.writeJSON(opts.Profile, expiredKey, time.Hour, []string{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "garbage.json"), []byte("{"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	tmp := filepath.Join(dir, "swamp-cache-1.tmp")
+	if err := os.WriteFile(tmp, []byte("x"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(tmp, old, old); err != nil {
+		t.Fatal(err)
+	}
+	if err := saveRecentTargets(dir, opts.Profile, recentScope{AccountID: "1", RoleName: "r"}, recentInstance{InstanceID: "i-1", Region: "us-east-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	expiredPath := store.filePath(opts.Profile, expiredKey)
+	var env cacheEnvelope
+	raw, _ := os.ReadFile(expiredPath)
+	if err := json.Unmarshal(raw, &env); err != nil {
+		t.Fatal(err)
+	}
+	env.ExpiresAt = time.Now().Add(-time.Minute)
+	raw, _ = json.Marshal(env)
+	if err := os.WriteFile(expiredPath, raw, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := pruneCache(&buf, store, true, false, time.Now()); err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if _, err := os.Stat(expiredPath); err != nil {
+		t.Fatalf("dry run removed a file: %v", err)
+	}
+
+	buf.Reset()
+	if err := pruneCache(&buf, store, false, false, time.Now()); err != nil {
+		t.Fatalf("prune: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Removed 1 expired and 2 orphaned files") {
+		t.Fatalf("unexpected prune summary:\n%s", buf.String())
+	}
+	for _, gone := range []string{expiredPath, tmp, filepath.Join(dir, "garbage.json")} {
+		if _, err := os.Stat(gone); !os.IsNotExist(err) {
+			t.Fatalf("expected %s to be removed", gone)
+		}
+	}
+	for _, kept := range []string{store.filePath(opts.Profile, liveKey), filepath.Join(dir, recentTargetsFileName)} {
+		if _, err := os.Stat(kept); err != nil {
+			t.Fatalf("expected %s to be kept: %v", kept, err)
+		}
+	}
+}
+
+func TestInvalidateCacheMatchesAllFilters(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	store := opts.cacheStore
+	keys := []string{
+		cacheKeyInstances(opts.Profile, "111111111111", "Admin", "us-east-1", true),
+		cacheKeyInstances(opts.Profile, "111111111111", "Admin", "eu-west-1", true),
+		cacheKeyInstances(opts.Profile, "222222222222", "Admin", "us-east-1", true),
+		cacheKeyRoles(opts.Profile, "us-east-1", "111111111111"),
+	}
+	for _, k := range keys {
+		if err := store.writeJSON(opts.Profile, k, time.Hour, []string{}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := invalidateCache(&buf, store, CacheInvalidateFilter{}, false); err == nil {
+		t.Fatal("expected an error without filters")
+	}
+	if err := invalidateCache(&buf, store, CacheInvalidateFilter{AccountID: "111111111111", Region: "us-east-1"}, false); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	entries, err := store.listEntries("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 3 {
+		t.Fatalf("expected 3 entries left, got %d", len(entries))
+	}
+	if _, err := os.Stat(store.filePath(opts.Profile, keys[0])); !os.IsNotExist(err) {
+		t.Fatal("expected matching instances entry to be dropped")
+	}
+
+	buf.Reset()
+	if err := invalidateCache(&buf, store, CacheInvalidateFilter{AccountID: "111111111111"}, false); err != nil {
+		t.Fatalf("invalidate: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Removed 2 entries") {
+		t.Fatalf("expected account filter to drop instances and roles, got:\n%s", buf.String())
+	}
+}
@@ -1,6 +1,12 @@
 package app
 
 import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 )
@@ -42,12 +48,12 @@ func TestListSSOAccountsCachedFreshHitSkipsFetcher(t *testing.T) {
 	orig := listSSOAccountsFetcher
 	defer func() { listSSOAccountsFetcher = orig }()
 	calls := 0
-	listSSOAccountsFetcher = func(profile, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
+	listSSOAccountsFetcher = func(ctx context.Context, profile, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
 		calls++
 		return []ssoAccountsResponse{testAccount("222", "fresh")}, nil
 	}
 
-	got, err := listSSOAccountsCached(opts, "us-east-1", "token")
+	got, err := listSSOAccountsCached(context.Background(), opts, "us-east-1", "token")
 	if err != nil {
 		t.Fatalf("listSSOAccountsCached failed: %v", err)
 	}
@@ -68,11 +74,11 @@ func TestListSSOAccountsCachedFreshModeBypassesCache(t *testing.T) {
 
 	orig := listSSOAccountsFetcher
 	defer func() { listSSOAccountsFetcher = orig }()
-	listSSOAccountsFetcher = func(profile, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
+	listSSOAccountsFetcher = func(ctx context.Context, profile, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
 		return []ssoAccountsResponse{testAccount("222", "fresh")}, nil
 	}
 
-	got, err := listSSOAccountsCached(opts, "us-east-1", "token")
+	got, err := listSSOAccountsCached(context.Background(), opts, "us-east-1", "token")
 	if err != nil {
 		t.Fatalf("listSSOAccountsCached failed: %v", err)
 	}
@@ -93,12 +99,12 @@ func TestListSSOAccountsCachedStaleReturnsImmediatelyAndRefreshes(t *testing.T)
 	orig := listSSOAccountsFetcher
 	defer func() { listSSOAccountsFetcher = orig }()
 	called := make(chan struct{}, 1)
-	listSSOAccountsFetcher = func(profile, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
+	listSSOAccountsFetcher = func(ctx context.Context, profile, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
 		called <- struct{}{}
 		return []ssoAccountsResponse{testAccount("222", "fresh")}, nil
 	}
 
-	got, err := listSSOAccountsCached(opts, "us-east-1", "token")
+	got, err := listSSOAccountsCached(context.Background(), opts, "us-east-1", "token")
 	if err != nil {
 		t.Fatalf("listSSOAccountsCached failed: %v", err)
 	}
@@ -138,12 +144,12 @@ func TestQueryInstancesCachedFreshHitSkipsFetcher(t *testing.T) {
 	orig := queryInstancesFetcher
 	defer func() { queryInstancesFetcher = orig }()
 	calls := 0
-	queryInstancesFetcher = func(tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+	queryInstancesFetcher = func(ctx context.Context, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
 		calls++
 		return []instanceCandidate{{InstanceID: "i-fresh"}}, nil
 	}
 
-	got, err := queryInstancesCached(opts, "", target, "p", "us-east-1", true)
+	got, err := queryInstancesCached(context.Background(), opts, "", target, "p", "us-east-1", true)
 	if err != nil {
 		t.Fatalf("queryInstancesCached failed: %v", err)
 	}
@@ -154,3 +160,142 @@ func TestQueryInstancesCachedFreshHitSkipsFetcher(t *testing.T) {
 		t.Fatalf("expected cached instances, got %+v", got)
 	}
 }
+
+func TestStreamAllInstancesReportsStaleScopesInProgress(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	target := roleTarget{AccountID: "123", RoleName: "Admin", AccountName: "acct"}
+	key := cacheKeyInstances(opts.Profile, target.AccountID, target.RoleName, "us-east-1", true)
+	if err := opts.cacheStore.writeJSON(opts.Profile, key, 10*time.Millisecond, []instanceCandidate{{InstanceID: "i-stale"}}); err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(20 * time.Millisecond)
+
+	orig := queryInstancesFetcher
+	defer func() { queryInstancesFetcher = orig }()
+	queryInstancesFetcher = func(ctx context.Context, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+		return []instanceCandidate{{InstanceID: "i-" + region}}, nil
+	}
+	defer opts.cacheStore.waitForRefreshes(io.Discard, time.Second)
+
+	stale := 0
+	for r := range streamAllInstances(context.Background(), opts, "", []roleTarget{target}, map[string]string{}, []string{"us-east-1", "eu-west-1"}, 1, true) {
+		stale = r.Stale
+	}
+	if stale != 1 {
+		t.Fatalf("expected one stale scope in the final progress, got %d", stale)
+	}
+	if got := scanProgressHeader(scanResult{Done: 2, Total: 2, Stale: 1}, 2); got != "Scan complete 2/2; 2 instances (1 from stale cache, refreshing)" {
+		t.Fatalf("unexpected header %q", got)
+	}
+}
+
+func TestSSMPingStatusOnlyWithOption(t *testing.T) {
+	origFetch, origPing := queryInstancesFetcher, querySSMPingStatusFn
+	defer func() { queryInstancesFetcher, querySSMPingStatusFn = origFetch, origPing }()
+	queryInstancesFetcher = func(ctx context.Context, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+		return []instanceCandidate{{InstanceID: "i-1"}}, nil
+	}
+	pings := 0
+	querySSMPingStatusFn = func(ctx context.Context, tmpConfigPath, profileName, region string) map[string]string {
+		pings++
+		return map[string]string{"i-1": "Online"}
+	}
+
+	opts := newTestCacheOptions(t, "fresh")
+	target := roleTarget{AccountID: "123", RoleName: "Admin"}
+	got, err := queryInstancesCached(context.Background(), opts, "", target, "p", "us-east-1", true)
+	if err != nil || pings != 0 || got[0].PingStatus != "" {
+		t.Fatalf("expected no SSM lookup by default, pings=%d got=%+v err=%v", pings, got, err)
+	}
+	opts.SSMStatus = true
+	got, err = queryInstancesCached(context.Background(), opts, "", target, "p", "us-east-1", true)
+	if err != nil || pings != 1 || got[0].PingStatus != "Online" {
+		t.Fatalf("expected the ping status with --ssm-status, pings=%d got=%+v err=%v", pings, got, err)
+	}
+}
+
+func TestRefreshAsyncQueuesBeyondConcurrencyLimit(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	release := make(chan struct{})
+	var ran atomic.Int32
+	for i := 0; i < cap(opts.cacheStore.sem)+4; i++ {
+		opts.cacheStore.refreshAsync(opts.Profile, fmt.Sprintf("instances:%s:%d", opts.Profile, i), func(ctx context.Context) error {
+			<-release
+			ran.Add(1)
+			return nil
+		})
+	}
+	close(release)
+	opts.cacheStore.waitForRefreshes(io.Discard, 2*time.Second)
+	if got := ran.Load(); got != int32(cap(opts.cacheStore.sem)+4) {
+		t.Fatalf("expected every refresh to run, got %d", got)
+	}
+}
+
+func TestRefreshAsyncOutlivesCanceledRunContext(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	ctx, cancel := context.WithCancel(context.Background())
+	opts.cacheStore.ctx = ctx
+	cancel()
+
+	var sawCanceled atomic.Bool
+	opts.cacheStore.refreshAsync(opts.Profile, "accounts:test-profile:us-east-1", func(ctx context.Context) error {
+		sawCanceled.Store(ctx.Err() != nil)
+		return nil
+	})
+	opts.cacheStore.waitForRefreshes(io.Discard, 2*time.Second)
+	if sawCanceled.Load() {
+		t.Fatal("expected refresh context to survive the run context")
+	}
+}
+
+func TestRemoveAfterRefreshesWaitsForPendingRefresh(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	orig := removeFileFn
+	defer func() { removeFileFn = orig }()
+	var removed atomic.Int32
+	removeFileFn = func(path string) error {
+		removed.Add(1)
+		return nil
+	}
+
+	release := make(chan struct{})
+	opts.cacheStore.refreshAsync(opts.Profile, "instances:test-profile:1", func(ctx context.Context) error {
+		<-release
+		return nil
+	})
+	opts.cacheStore.removeAfterRefreshes("/tmp/aws-config-swamp-test.ini")
+	if removed.Load() != 0 {
+		t.Fatal("temp config removed while a refresh still needs it")
+	}
+	close(release)
+	opts.cacheStore.waitForRefreshes(io.Discard, 2*time.Second)
+	if removed.Load() != 1 {
+		t.Fatalf("expected temp config removed once refreshes finished, got %d removals", removed.Load())
+	}
+}
+
+func TestWaitForRefreshesGivesUpAfterGracePeriod(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	stopped := make(chan struct{})
+	opts.cacheStore.refreshAsync(opts.Profile, "regions:test-profile", func(ctx context.Context) error {
+		<-ctx.Done()
+		close(stopped)
+		return ctx.Err()
+	})
+
+	var out bytes.Buffer
+	started := time.Now()
+	opts.cacheStore.waitForRefreshes(&out, 50*time.Millisecond)
+	if time.Since(started) > time.Second {
+		t.Fatal("waitForRefreshes did not honor the grace period")
+	}
+	if !strings.Contains(out.String(), "Finishing 1 background cache refresh") {
+		t.Fatalf("unexpected output %q", out.String())
+	}
+	select {
+	case <-stopped:
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected leftover refresh to be canceled")
+	}
+}
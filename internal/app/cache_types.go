@@ -10,7 +10,7 @@ const (
 	cacheModeSpeed    cacheMode = "speed"
 )
 
-const cacheVersion = 1
+const cacheVersion = 2
 
 type cacheConfig struct {
 	Enabled bool
@@ -32,6 +32,12 @@ type cacheEnvelope struct {
 	Payload   jsonRawPayload `json:"payload"`
 }
 
+type cacheEntry struct {
+	Path     string
+	Size     int64
+	Envelope cacheEnvelope
+}
+
 type jsonRawPayload []byte
 
 func (p jsonRawPayload) MarshalJSON() ([]byte, error) {
@@ -13,6 +13,20 @@ func validateOptions(opts Options) error {
 	if opts.Workers < 1 {
 		return errors.New("--workers must be at least 1")
 	}
+	switch strings.ToLower(strings.TrimSpace(opts.Picker)) {
+	case "", "auto", "fzf", "builtin":
+	default:
+		return errors.New("--picker must be one of: auto, fzf, builtin")
+	}
+	if opts.LastN < 0 {
+		return errors.New("--last must be at least 1")
+	}
+	if opts.Timeout < 0 {
+		return errors.New("--timeout must be non-negative")
+	}
+	if opts.CallTimeout < 0 {
+		return errors.New("--call-timeout must be non-negative")
+	}
 	if opts.CacheEnabled {
 		if strings.TrimSpace(opts.CacheDir) == "" {
 			return errors.New("--cache-dir must not be empty when --cache=true")
@@ -29,12 +43,27 @@ func validateOptions(opts Options) error {
 	return nil
 }
 
-func validateDependencies() error {
+func validateDependencies(picker string) error {
 	if _, err := exec.LookPath("aws"); err != nil {
 		return errors.New("aws CLI not found in PATH")
 	}
-	if _, err := exec.LookPath("fzf"); err != nil {
-		return errors.New("fzf not found in PATH")
+	if picker == "fzf" {
+		if _, err := exec.LookPath("fzf"); err != nil {
+			return errors.New("fzf not found in PATH (set --picker builtin to use the built-in picker)")
+		}
 	}
 	return nil
 }
+
+func resolvePicker(opts Options) string {
+	switch strings.ToLower(strings.TrimSpace(opts.Picker)) {
+	case "fzf":
+		return "fzf"
+	case "builtin":
+		return "builtin"
+	}
+	if _, err := exec.LookPath("fzf"); err != nil {
+		return "builtin"
+	}
+	return "fzf"
+}
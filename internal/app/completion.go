@@ -0,0 +1,208 @@
+package app
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// Shell completion reads only local state: ~/.aws/config, the config files,
+// favorites and whatever discovery already cached. It never calls AWS, so
+// accounts, roles and regions only complete after a run has filled the cache.
+// Candidates may carry a description after a tab, which cobra passes on to
+// shells that show one.
+
+// completionOptions resolves config like a local command, but never runs
+// cache.key_command (see configureCompletionAtRest). A half-typed --preset
+// must not hide every other candidate, so it is dropped when it does not
+// resolve.
+func completionOptions(opts Options) (Options, bool) {
+	resolved, err := resolveLocalConfig(opts)
+	if err != nil && opts.Preset != "" {
+		opts.Preset = ""
+		resolved, err = resolveLocalConfig(opts)
+	}
+	if err != nil {
+		return Options{}, false
+	}
+	configureCompletionAtRest(resolved)
+	return resolved, true
+}
+
+func CompleteProfiles(toComplete string) []string {
+	names, err := listSSOProfiles()
+	if err != nil {
+		return nil
+	}
+	return completionMatches(names, toComplete)
+}
+
+func CompletePresets(opts Options, toComplete string) []string {
+	cfg, err := loadLayeredConfig(resolveConfigPath(opts.ConfigPath))
+	if err != nil {
+		return nil
+	}
+	return completionMatches(presetNames(cfg), toComplete)
+}
+
+// CompleteFavorites returns favorite aliases, each prefixed with prefix.
+func CompleteFavorites(opts Options, prefix, toComplete string) []string {
+	resolved, ok := completionOptions(opts)
+	if !ok {
+		return nil
+	}
+	favs, err := loadFavorites(resolved.ConfigPath)
+	if err != nil {
+		return nil
+	}
+	var out []string
+	for alias, fav := range favs.Favorites {
+		out = append(out, fmt.Sprintf("%s%s\t%s %s %s", prefix, alias, favoriteAccount(fav.Scope), fav.Scope.RoleName, fav.target()))
+	}
+	sort.Strings(out)
+	return completionMatches(out, toComplete)
+}
+
+// CompleteAccounts offers cached account IDs and names; --account accepts
+// either.
+func CompleteAccounts(opts Options, toComplete string) []string {
+	resolved, ok := completionOptions(opts)
+	if !ok {
+		return nil
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, entry := range cachedCompletionEntries(resolved, "accounts") {
+		var accounts []ssoAccountsResponse
+		if json.Unmarshal(entry.Envelope.Payload, &accounts) != nil {
+			continue
+		}
+		for _, a := range resolved.policy.filterAccounts(accounts) {
+			acct := a.AccountList[0]
+			if seen[acct.AccountID] {
+				continue
+			}
+			seen[acct.AccountID] = true
+			out = append(out, acct.AccountID+"\t"+acct.AccountName)
+			if acct.AccountName != "" {
+				out = append(out, acct.AccountName+"\t"+acct.AccountID)
+			}
+		}
+	}
+	sort.Strings(out)
+	return completionMatches(out, toComplete)
+}
+
+// CompleteRoles offers cached role names, limited to the accounts matching
+// account when it is set.
+func CompleteRoles(opts Options, account, toComplete string) []string {
+	resolved, ok := completionOptions(opts)
+	if !ok {
+		return nil
+	}
+	seen := map[string]bool{}
+	var out []string
+	for _, entry := range cachedCompletionEntries(resolved, "roles") {
+		var targets []roleTarget
+		if json.Unmarshal(entry.Envelope.Payload, &targets) != nil {
+			continue
+		}
+		for _, t := range resolved.policy.filterTargets(targets) {
+			if seen[t.RoleName] || !roleInAccount(t, account) {
+				continue
+			}
+			seen[t.RoleName] = true
+			out = append(out, t.RoleName)
+		}
+	}
+	sort.Strings(out)
+	return completionMatches(out, toComplete)
+}
+
+func roleInAccount(t roleTarget, account string) bool {
+	needle := strings.ToLower(strings.TrimSpace(account))
+	return needle == "" || t.AccountID == needle || strings.Contains(strings.ToLower(t.AccountName), needle)
+}
+
+// CompleteRegions offers cached regions. With multi set the value is a
+// comma-separated list: earlier entries are kept and not offered again.
+func CompleteRegions(opts Options, multi bool, toComplete string) []string {
+	resolved, ok := completionOptions(opts)
+	if !ok {
+		return nil
+	}
+	seen := map[string]bool{}
+	var regions []string
+	add := func(region string) {
+		if region != "" && !seen[region] {
+			seen[region] = true
+			regions = append(regions, region)
+		}
+	}
+	for _, entry := range cachedCompletionEntries(resolved, "regions", "instances") {
+		// Instance scans record their region in the key even when region
+		// discovery was skipped with --regions.
+		if key := parseCacheKey(entry.Envelope.Key); key.Kind == "instances" {
+			add(key.Region)
+			continue
+		}
+		var cached []string
+		if json.Unmarshal(entry.Envelope.Payload, &cached) != nil {
+			continue
+		}
+		for _, r := range cached {
+			add(r)
+		}
+	}
+	sort.Strings(regions)
+
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); multi && i >= 0 {
+		prefix, toComplete = toComplete[:i+1], toComplete[i+1:]
+	}
+	chosen := map[string]bool{}
+	for _, r := range splitCSV(prefix) {
+		chosen[r] = true
+	}
+	var out []string
+	for _, r := range completionMatches(regions, toComplete) {
+		if !chosen[r] {
+			out = append(out, prefix+r)
+		}
+	}
+	return out
+}
+
+// cachedCompletionEntries returns the cache entries of the given kinds for
+// the selected profile, or for every profile when none is set, in one pass
+// over the cache directory. Expired entries are still good enough to
+// complete from.
+func cachedCompletionEntries(opts Options, kinds ...string) []cacheEntry {
+	opts.CacheEnabled = true
+	entries, err := newCacheStore(opts).listEntries(opts.Profile)
+	if err != nil {
+		return nil
+	}
+	var out []cacheEntry
+	for _, e := range entries {
+		if containsString(kinds, parseCacheKey(e.Envelope.Key).Kind) {
+			out = append(out, e)
+		}
+	}
+	return out
+}
+
+// completionMatches keeps candidates starting with toComplete, ignoring
+// case and any description.
+func completionMatches(candidates []string, toComplete string) []string {
+	needle := strings.ToLower(toComplete)
+	var out []string
+	for _, c := range candidates {
+		value, _, _ := strings.Cut(c, "\t")
+		if strings.HasPrefix(strings.ToLower(value), needle) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
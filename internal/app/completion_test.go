@@ -0,0 +1,118 @@
+package app
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newCompletionTestOptions(t *testing.T) Options {
+	t.Helper()
+	dir := isolateConfigLayers(t)
+	writeConfigLayerFile(t, filepath.Join(dir, "config.yaml"), `policy:
+  deny_accounts: ["*-sandbox"]
+presets:
+  prod-eu: {account: prod}
+  dev: {account: dev}
+`)
+	opts := newTestCacheOptions(t, "balanced")
+	store := opts.cacheStore
+	writes := map[string]any{
+		cacheKeyAccounts("test-profile", "us-east-1"):                       []ssoAccountsResponse{testAccount("111111111111", "prod"), testAccount("222222222222", "dev"), testAccount("333333333333", "team-sandbox")},
+		cacheKeyRoles("test-profile", "us-east-1", "111111111111"):          []roleTarget{{AccountID: "111111111111", AccountName: "prod", RoleName: "Admin"}},
+		cacheKeyRoles("test-profile", "us-east-1", "222222222222"):          []roleTarget{{AccountID: "222222222222", AccountName: "dev", RoleName: "Developer"}},
+		cacheKeyRegions("test-profile", "swamp-1", "us-east-1", false):      []string{"eu-west-1", "us-east-1"},
+		cacheKeyInstances("test-profile", "1", "Admin", "ap-south-1", true): []instanceCandidate{},
+		cacheKeyAccounts("other-profile", "us-east-1"):                      []ssoAccountsResponse{testAccount("444444444444", "other")},
+	}
+	for key, payload := range writes {
+		profile := parseCacheKey(key).Profile
+		if err := store.writeJSON(profile, key, opts.CacheTTLAccounts, payload); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return Options{
+		Profile:    "test-profile",
+		ConfigPath: filepath.Join(dir, "config.yaml"),
+		CacheDir:   opts.CacheDir,
+		FlagSet:    map[string]bool{"profile": true, "config": true, "cache-dir": true},
+	}
+}
+
+func TestCompletionReadsCachedScope(t *testing.T) {
+	opts := newCompletionTestOptions(t)
+
+	if got := strings.Join(CompleteAccounts(opts, ""), ","); got != "111111111111\tprod,222222222222\tdev,dev\t222222222222,prod\t111111111111" {
+		t.Fatalf("expected the profile's allowed accounts by ID and name, got %q", got)
+	}
+	if got := CompleteAccounts(opts, "PR"); len(got) != 1 || got[0] != "prod\t111111111111" {
+		t.Fatalf("expected a case-insensitive prefix match, got %q", got)
+	}
+	if got := strings.Join(CompleteRoles(opts, "", ""), ","); got != "Admin,Developer" {
+		t.Fatalf("unexpected roles %q", got)
+	}
+	if got := strings.Join(CompleteRoles(opts, "dev", ""), ","); got != "Developer" {
+		t.Fatalf("expected roles limited to --account, got %q", got)
+	}
+	if got := strings.Join(CompleteRegions(opts, true, "eu-west-1,"), " "); got != "eu-west-1,ap-south-1 eu-west-1,us-east-1" {
+		t.Fatalf("expected the remaining regions after the comma, got %q", got)
+	}
+	if got := strings.Join(CompleteRegions(opts, false, "us"), " "); got != "us-east-1" {
+		t.Fatalf("unexpected single region completion %q", got)
+	}
+	if got := strings.Join(CompletePresets(opts, "p"), ","); got != "prod-eu" {
+		t.Fatalf("unexpected presets %q", got)
+	}
+
+	opts.Profile, opts.FlagSet["profile"] = "", false
+	if got := CompleteAccounts(opts, "4"); len(got) != 1 {
+		t.Fatalf("expected every profile's accounts without --profile, got %q", got)
+	}
+}
+
+func TestCompleteProfilesListsSSOProfiles(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	writeConfigLayerFile(t, filepath.Join(home, ".aws", "config"), `[default]
+region = us-east-1
+
+[profile dev-sso]
+sso_session = corp
+
+[profile legacy]
+sso_start_url = https://example.awsapps.com/start
+sso_region = us-east-1
+
+[profile static]
+aws_access_key_id = AKIA
+
+[sso-session corp]
+sso_start_url = https://example.awsapps.com/start
+`)
+	if got := strings.Join(CompleteProfiles(""), ","); got != "dev-sso,legacy" {
+		t.Fatalf("expected only SSO profiles, got %q", got)
+	}
+}
+
+func TestCompletionNeverRunsTheKeyCommand(t *testing.T) {
+	withAtRestSecret(t, "")
+	t.Setenv(cacheKeyEnv, "")
+	opts := newCompletionTestOptions(t)
+	writeConfigLayerFile(t, opts.ConfigPath, `cache:
+  encrypt: true
+  key_command: pass show swamp
+`)
+	origCmd := runKeyCommandFn
+	defer func() { runKeyCommandFn = origCmd }()
+	runKeyCommandFn = func(command string) ([]byte, error) {
+		t.Fatalf("completion ran the key command %q", command)
+		return nil, nil
+	}
+
+	if got := CompleteAccounts(opts, "pr"); len(got) != 1 || got[0] != "prod\t111111111111" {
+		t.Fatalf("expected plain cache entries to still complete, got %q", got)
+	}
+	if atRestSecret != "" {
+		t.Fatalf("expected no cache key during completion, got %q", atRestSecret)
+	}
+}
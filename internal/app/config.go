@@ -114,3 +114,43 @@ func awsSSOCacheDir() string {
 	}
 	return filepath.Join(home, ".aws", "sso", "cache")
 }
+
+// listSSOProfiles returns the profiles in ~/.aws/config that are set up for
+// SSO, either directly or through an sso-session section.
+func listSSOProfiles() ([]string, error) {
+	f, err := os.Open(awsConfigPath())
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var names []string
+	seen := map[string]bool{}
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"))
+			current = ""
+			if name, ok := strings.CutPrefix(section, "profile "); ok {
+				current = strings.TrimSpace(name)
+			} else if section == "default" {
+				current = section
+			}
+			continue
+		}
+		key, _, ok := splitKeyValue(line)
+		if !ok || current == "" || seen[current] {
+			continue
+		}
+		if key = strings.ToLower(key); key == "sso_session" || key == "sso_start_url" {
+			seen[current] = true
+			names = append(names, current)
+		}
+	}
+	return names, scanner.Err()
+}
@@ -0,0 +1,447 @@
+package app
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var runEditorFn = func(path string) error {
+	editor := strings.TrimSpace(os.Getenv("VISUAL"))
+	if editor == "" {
+		editor = strings.TrimSpace(os.Getenv("EDITOR"))
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+	// Run through the shell so EDITOR="code --wait" works.
+	cmd := exec.Command("sh", "-c", editor+` "$1"`, "sh", path)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	return cmd.Run()
+}
+
+// ConfigGet prints the value of one dotted key as written in the config file.
+func ConfigGet(w io.Writer, opts Options, key string) error {
+	path := resolveConfigPath(opts.ConfigPath)
+	if _, err := configKeySchema(key); err != nil {
+		return err
+	}
+	doc, err := readConfigDocument(path)
+	if err != nil {
+		return err
+	}
+	node := lookupConfigNode(doc, strings.Split(key, "."))
+	if node == nil || (node.Kind == yaml.ScalarNode && node.Tag == "!!null") {
+		return fmt.Errorf("%s is not set in %s", key, path)
+	}
+	if node.Kind == yaml.ScalarNode {
+		fmt.Fprintln(w, node.Value)
+		return nil
+	}
+	out, err := encodeConfigNode(node)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(out)
+	return err
+}
+
+// ConfigSet writes one dotted key, keeping the rest of the file, including
+// comments and key order, as it was.
+func ConfigSet(w io.Writer, opts Options, key, value string) error {
+	path := resolveConfigPath(opts.ConfigPath)
+	schema, err := configKeySchema(key)
+	if err != nil {
+		return err
+	}
+	valueNode, err := configValueNode(schema, key, value)
+	if err != nil {
+		return err
+	}
+	if err := ensureDefaultConfigFile(w, path); err != nil {
+		return err
+	}
+	return withCacheDirLock(opts.CacheDir, path, func() error {
+		original, err := os.ReadFile(path)
+		if err != nil {
+			return fmt.Errorf("read config file %q: %w", path, err)
+		}
+		doc, err := readConfigDocument(path)
+		if err != nil {
+			return err
+		}
+		setConfigNode(doc, strings.Split(key, "."), valueNode)
+		out, err := encodeConfigNode(doc)
+		if err != nil {
+			return err
+		}
+		out = keepSectionSpacing(original, out)
+		problems, err := validateConfigData(out)
+		if err != nil {
+			return err
+		}
+		for _, p := range problems {
+			if p.Key == key || strings.HasPrefix(p.Key, key+".") {
+				return fmt.Errorf("invalid value for %s: %s", key, p.Message)
+			}
+		}
+		if err := writeConfigFile(path, out); err != nil {
+			return err
+		}
+		fmt.Fprintf(w, "Set %s in %s\n", key, path)
+		return nil
+	})
+}
+
+// ConfigEdit opens the config file in $VISUAL or $EDITOR and only saves it
+// once it validates, offering to reopen the editor otherwise.
+func ConfigEdit(in io.Reader, w io.Writer, opts Options) error {
+	path := resolveConfigPath(opts.ConfigPath)
+	if err := ensureDefaultConfigFile(w, path); err != nil {
+		return err
+	}
+	original, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("read config file %q: %w", path, err)
+	}
+	tmp, err := os.CreateTemp(filepath.Dir(path), "config-*.yaml")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	defer func() { _ = os.Remove(tmpName) }()
+	if _, err := tmp.Write(original); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	reader := bufio.NewReader(in)
+	for {
+		if err := runEditorFn(tmpName); err != nil {
+			return fmt.Errorf("editor failed: %w", err)
+		}
+		edited, err := os.ReadFile(tmpName)
+		if err != nil {
+			return err
+		}
+		if bytes.Equal(edited, original) {
+			fmt.Fprintln(w, "No changes.")
+			return nil
+		}
+		problems, err := validateConfigData(edited)
+		if err != nil {
+			return err
+		}
+		if len(problems) == 0 {
+			if err := withCacheDirLock(opts.CacheDir, path, func() error { return writeConfigFile(path, edited) }); err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "Saved %s\n", path)
+			return nil
+		}
+		for _, p := range problems {
+			fmt.Fprintf(w, "%s:%s\n", path, p)
+		}
+		fmt.Fprint(w, "Edit again? [Y/n] ")
+		answer, _ := reader.ReadString('\n')
+		if a := strings.ToLower(strings.TrimSpace(answer)); a == "n" || a == "no" {
+			return errors.New("config not saved")
+		}
+	}
+}
+
+// configKeySchema finds the schema for a dotted key such as
+// discovery.workers or presets.prod-eu.regions.
+func configKeySchema(key string) (*configSchema, error) {
+	root, err := loadConfigSchema()
+	if err != nil {
+		return nil, err
+	}
+	v := configValidator{root: root}
+	s := root
+	for _, part := range strings.Split(key, ".") {
+		s = v.resolve(s)
+		if part == "" || s == nil || s.Type != "object" {
+			return nil, fmt.Errorf("unknown config key %q", key)
+		}
+		if prop, ok := s.Properties[part]; ok {
+			s = prop
+			continue
+		}
+		additional, _ := s.additional()
+		if additional == nil {
+			return nil, fmt.Errorf("unknown config key %q", key)
+		}
+		s = additional
+	}
+	s = v.resolve(s)
+	if s.Type == "object" {
+		return nil, fmt.Errorf("%s is a section; set one of its keys instead", key)
+	}
+	return s, nil
+}
+
+// configValueNode turns a command-line value into a YAML node of the type
+// the schema expects. Lists are given comma-separated.
+func configValueNode(s *configSchema, key, value string) (*yaml.Node, error) {
+	value = strings.TrimSpace(value)
+	switch s.Type {
+	case "boolean":
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q is not true or false", key, value)
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!bool", Value: strconv.FormatBool(b)}, nil
+	case "integer":
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid value for %s: %q is not a whole number", key, value)
+		}
+		return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!int", Value: strconv.Itoa(n)}, nil
+	case "array":
+		seq := &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq", Style: yaml.FlowStyle}
+		for _, item := range splitCSV(value) {
+			seq.Content = append(seq.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: item})
+		}
+		return seq, nil
+	}
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}, nil
+}
+
+func readConfigDocument(path string) (*yaml.Node, error) {
+	data, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("read config file %q: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse config file %q: %w", path, err)
+	}
+	if doc.Kind == 0 {
+		doc = yaml.Node{Kind: yaml.DocumentNode}
+	}
+	if len(doc.Content) == 0 {
+		doc.Content = []*yaml.Node{{Kind: yaml.MappingNode, Tag: "!!map"}}
+	}
+	if doc.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("config file %q is not a YAML map", path)
+	}
+	return &doc, nil
+}
+
+func lookupConfigNode(doc *yaml.Node, parts []string) *yaml.Node {
+	node := doc.Content[0]
+	for _, part := range parts {
+		if node.Kind != yaml.MappingNode {
+			return nil
+		}
+		var next *yaml.Node
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			if node.Content[i].Value == part {
+				next = node.Content[i+1]
+				break
+			}
+		}
+		if next == nil {
+			return nil
+		}
+		node = next
+	}
+	return node
+}
+
+// setConfigNode replaces the value at parts, creating missing sections. An
+// existing value node is updated in place so its comments stay attached.
+func setConfigNode(doc *yaml.Node, parts []string, value *yaml.Node) {
+	node := doc.Content[0]
+	for i, part := range parts {
+		var next *yaml.Node
+		for j := 0; j+1 < len(node.Content); j += 2 {
+			if node.Content[j].Value == part {
+				next = node.Content[j+1]
+				break
+			}
+		}
+		last := i == len(parts)-1
+		if next == nil {
+			next = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			if last {
+				next = value
+			}
+			node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: part}, next)
+		} else if last {
+			keepQuotes := next.Tag == "!!str" && value.Tag == "!!str" && next.Style&(yaml.DoubleQuotedStyle|yaml.SingleQuotedStyle) != 0
+			next.Kind, next.Tag, next.Value, next.Content = value.Kind, value.Tag, value.Value, value.Content
+			if !keepQuotes {
+				next.Style = value.Style
+			}
+		} else if next.Kind != yaml.MappingNode {
+			next.Kind, next.Tag, next.Value, next.Content, next.Style = yaml.MappingNode, "!!map", "", nil, 0
+		}
+		node = next
+	}
+}
+
+func encodeConfigNode(node *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(node); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+var configKeyLine = regexp.MustCompile(`^(\s*)([^\s#-][^:#]*):`)
+
+// configKeyPaths returns the dotted path of the key each line starts, by
+// indentation, or "" for lines that start no key. A path seen before (keys
+// inside list items) gets its occurrence appended, so every key is unique.
+func configKeyPaths(lines []string) []string {
+	type level struct {
+		indent int
+		key    string
+	}
+	var stack []level
+	seen := map[string]int{}
+	paths := make([]string, len(lines))
+	for i, line := range lines {
+		m := configKeyLine.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		indent := len(m[1])
+		for len(stack) > 0 && stack[len(stack)-1].indent >= indent {
+			stack = stack[:len(stack)-1]
+		}
+		stack = append(stack, level{indent: indent, key: strings.TrimSpace(m[2])})
+		keys := make([]string, len(stack))
+		for k, l := range stack {
+			keys[k] = l.key
+		}
+		path := strings.Join(keys, ".")
+		if n := seen[path]; n > 0 {
+			seen[path]++
+			path = fmt.Sprintf("%s#%d", path, n)
+		} else {
+			seen[path] = 1
+		}
+		paths[i] = path
+	}
+	return paths
+}
+
+func isCommentLine(line string) bool {
+	return strings.HasPrefix(strings.TrimSpace(line), "#")
+}
+
+// keepSectionSpacing puts back the blank lines the YAML encoder drops before
+// keys (and the comments leading into them), at any depth. New top-level
+// sections get one too when the file already separates its sections.
+func keepSectionSpacing(original, encoded []byte) []byte {
+	spaced := map[string]bool{}
+	existing := map[string]bool{}
+	spacedSections := false
+	lines := strings.Split(string(original), "\n")
+	for i, path := range configKeyPaths(lines) {
+		if path == "" {
+			continue
+		}
+		existing[path] = true
+		j := i
+		for j > 0 && isCommentLine(lines[j-1]) {
+			j--
+		}
+		if j > 0 && strings.TrimSpace(lines[j-1]) == "" {
+			spaced[path] = true
+			if !strings.Contains(path, ".") {
+				spacedSections = true
+			}
+		}
+	}
+
+	var out []string
+	encodedLines := strings.Split(string(encoded), "\n")
+	for i, path := range configKeyPaths(encodedLines) {
+		newSection := path != "" && !existing[path] && !strings.Contains(path, ".") && spacedSections
+		if spaced[path] || newSection {
+			j := len(out)
+			for j > 0 && isCommentLine(out[j-1]) {
+				j--
+			}
+			if j > 0 && strings.TrimSpace(out[j-1]) != "" {
+				out = append(out[:j], append([]string{""}, out[j:]...)...)
+			}
+		}
+		out = append(out, encodedLines[i])
+	}
+	return []byte(strings.Join(out, "\n"))
+}
+
+// writeConfigFile replaces path atomically. A symlinked file (dotfile
+// repos commonly link ~/.aws/config and the swamp config) is written
+// through to its target so the link survives. The file keeps its mode, and
+// only a missing directory is created; existing ones are left as they are.
+func writeConfigFile(path string, content []byte) error {
+	if resolved, err := filepath.EvalSymlinks(path); err == nil {
+		path = resolved
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "config-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if info, err := os.Stat(path); err == nil {
+		if err := tmp.Chmod(info.Mode().Perm()); err != nil {
+			tmp.Close()
+			_ = os.Remove(tmpName)
+			return err
+		}
+	}
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+func splitCSV(value string) []string {
+	var out []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			out = append(out, part)
+		}
+	}
+	return out
+}
@@ -0,0 +1,205 @@
+package app
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestConfigSetKeepsCommentsAndOrder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `# team defaults
+profile: corp-sso # shared SSO profile
+
+discovery:
+  # keep this low on laptops
+  workers: 12
+  regions: []
+
+ux:
+  picker: auto
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{ConfigPath: path, CacheDir: t.TempDir()}
+	if err := ConfigSet(io.Discard, opts, "discovery.workers", "24"); err != nil {
+		t.Fatalf("set workers: %v", err)
+	}
+	if err := ConfigSet(io.Discard, opts, "ux.skip_region_select", "true"); err != nil {
+		t.Fatalf("set skip_region_select: %v", err)
+	}
+	if err := ConfigSet(io.Discard, opts, "discovery.regions", "eu-west-1, eu-central-1"); err != nil {
+		t.Fatalf("set regions: %v", err)
+	}
+
+	got, _ := os.ReadFile(path)
+	want := `# team defaults
+profile: corp-sso # shared SSO profile
+
+discovery:
+  # keep this low on laptops
+  workers: 24
+  regions: [eu-west-1, eu-central-1]
+
+ux:
+  picker: auto
+  skip_region_select: true
+`
+	if string(got) != want {
+		t.Fatalf("unexpected file:\n%s", got)
+	}
+
+	var out bytes.Buffer
+	if err := ConfigGet(&out, opts, "discovery.workers"); err != nil || out.String() != "24\n" {
+		t.Fatalf("get workers = %q, %v", out.String(), err)
+	}
+}
+
+func TestConfigSetRejectsBadKeysAndValues(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("profile: corp\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{ConfigPath: path, CacheDir: t.TempDir()}
+	for _, tc := range []struct{ key, value, want string }{
+		{"discovery.wrokers", "4", "unknown config key"},
+		{"discovery", "4", "section"},
+		{"discovery.workers", "many", "whole number"},
+		{"discovery.workers", "0", "at least 1"},
+		{"cache.mode", "slow", "must be one of"},
+		{"discovery.regions", "eu-west-9", "unknown AWS region"},
+	} {
+		err := ConfigSet(io.Discard, opts, tc.key, tc.value)
+		if err == nil || !strings.Contains(err.Error(), tc.want) {
+			t.Fatalf("set %s=%s: expected %q error, got %v", tc.key, tc.value, tc.want, err)
+		}
+	}
+	if got, _ := os.ReadFile(path); string(got) != "profile: corp\n" {
+		t.Fatalf("rejected values must not be written, got %q", got)
+	}
+}
+
+func TestConfigEditValidatesBeforeSaving(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(path, []byte("profile: corp\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	orig := runEditorFn
+	defer func() { runEditorFn = orig }()
+	edits := []string{"discovery:\n  workers: 0\n", "discovery:\n  workers: 8\n"}
+	runEditorFn = func(tmp string) error {
+		next := edits[0]
+		edits = edits[1:]
+		return os.WriteFile(tmp, []byte(next), 0o600)
+	}
+
+	var out bytes.Buffer
+	if err := ConfigEdit(strings.NewReader("y\n"), &out, Options{ConfigPath: path, CacheDir: t.TempDir()}); err != nil {
+		t.Fatalf("ConfigEdit: %v", err)
+	}
+	if !strings.Contains(out.String(), "at least 1") {
+		t.Fatalf("expected the first edit's problem to be shown, got %q", out.String())
+	}
+	if got, _ := os.ReadFile(path); string(got) != "discovery:\n  workers: 8\n" {
+		t.Fatalf("expected the valid edit to be saved, got %q", got)
+	}
+
+	edits = []string{"ux:\n  picker: vim\n"}
+	if err := ConfigEdit(strings.NewReader("n\n"), io.Discard, Options{ConfigPath: path, CacheDir: t.TempDir()}); err == nil {
+		t.Fatal("expected declining to re-edit an invalid file to fail")
+	}
+	if got, _ := os.ReadFile(path); string(got) != "discovery:\n  workers: 8\n" {
+		t.Fatalf("invalid edit must not be saved, got %q", got)
+	}
+}
+
+func TestWriteConfigFileWritesThroughSymlinks(t *testing.T) {
+	dir := t.TempDir()
+	target := filepath.Join(dir, "dotfiles", "config")
+	if err := os.MkdirAll(filepath.Dir(target), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(target, []byte("old\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	link := filepath.Join(dir, "config")
+	if err := os.Symlink(filepath.Join("dotfiles", "config"), link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	if err := writeConfigFile(link, []byte("new\n")); err != nil {
+		t.Fatalf("writeConfigFile: %v", err)
+	}
+	if info, err := os.Lstat(link); err != nil || info.Mode()&os.ModeSymlink == 0 {
+		t.Fatalf("expected the symlink to be kept, got %v, %v", info, err)
+	}
+	if got, _ := os.ReadFile(target); string(got) != "new\n" {
+		t.Fatalf("expected the link target to be updated, got %q", got)
+	}
+}
+
+func TestConfigSetKeepsBlankLinesInsideSections(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	content := `discovery:
+  workers: 12
+
+  # scanned when no region is picked
+  regions: [eu-west-1]
+cache:
+  enabled: true
+
+  ttl:
+    accounts: 24h
+
+    instances: 5m
+`
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := ConfigSet(io.Discard, Options{ConfigPath: path, CacheDir: t.TempDir()}, "discovery.workers", "8"); err != nil {
+		t.Fatalf("set workers: %v", err)
+	}
+	got, _ := os.ReadFile(path)
+	if want := strings.Replace(content, "workers: 12", "workers: 8", 1); string(got) != want {
+		t.Fatalf("expected blank lines inside sections to be kept, got:\n%s", got)
+	}
+}
+
+func TestConfigSetKeepsFileAndDirectoryModes(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "team")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "team.yaml")
+	if err := os.WriteFile(path, []byte("profile: corp-sso\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	opts := Options{ConfigPath: path, CacheDir: t.TempDir()}
+	if err := ConfigSet(io.Discard, opts, "discovery.workers", "8"); err != nil {
+		t.Fatalf("set workers: %v", err)
+	}
+
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o644 {
+		t.Fatalf("expected the file to stay 0644, got %v, %v", info.Mode().Perm(), err)
+	}
+	if info, err := os.Stat(dir); err != nil || info.Mode().Perm() != 0o755 {
+		t.Fatalf("expected the directory to stay 0755, got %v, %v", info.Mode().Perm(), err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected only team.yaml beside the config, got %v", entries)
+	}
+}
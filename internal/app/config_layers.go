@@ -0,0 +1,432 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const projectConfigName = ".swamp.yaml"
+
+var (
+	systemConfigPath = "/etc/swamp/config.yaml"
+	workingDirFn     = os.Getwd
+)
+
+// projectConfigKeys are the keys a .swamp.yaml may set. It comes from
+// whatever checkout the shell is in, so anything that runs commands, moves
+// the cache or loosens the policy (cache.*, document, policy, profile) stays
+// with the user and system files. "*" matches one path segment.
+var projectConfigKeys = [][]string{
+	{"discovery", "filter"},
+	{"discovery", "regions"},
+	{"discovery", "all_regions"},
+	{"discovery", "include_stopped"},
+	{"presets", "*", "account"},
+	{"presets", "*", "role"},
+	{"presets", "*", "regions"},
+	{"presets", "*", "include_stopped"},
+	{"presets", "*", "filter"},
+	{"ux", "auto_select_single"},
+	{"ux", "skip_region_select"},
+	{"ux", "picker"},
+}
+
+// configLayer is one config file in the precedence chain.
+type configLayer struct {
+	path string
+	root *yaml.Node
+}
+
+// loadLayeredConfig merges, from lowest to highest precedence, the system
+// file, the team files it or the user file include, the user file and the
+// nearest .swamp.yaml above the working directory. Keys the system file
+// lists under `locked:` keep their system value.
+func loadLayeredConfig(userPath string) (UserConfig, error) {
+	system, err := readConfigLayer(systemConfigPath)
+	if err != nil {
+		return UserConfig{}, err
+	}
+	user, err := readConfigLayer(userPath)
+	if err != nil {
+		return UserConfig{}, err
+	}
+	project, err := readConfigLayer(findProjectConfig(userPath))
+	if err != nil {
+		return UserConfig{}, err
+	}
+	if project != nil {
+		restrictProjectLayer(project.root, nil, project.path)
+	}
+
+	var includes []*configLayer
+	for _, layer := range []*configLayer{system, user} {
+		if layer == nil {
+			continue
+		}
+		for _, inc := range stringListValue(mappingValue(layer.root, "include")) {
+			path := expandTilde(inc)
+			if !filepath.IsAbs(path) {
+				path = filepath.Join(filepath.Dir(layer.path), path)
+			}
+			included, err := readConfigLayer(path)
+			if err != nil {
+				return UserConfig{}, err
+			}
+			if included == nil {
+				fmt.Fprintf(os.Stderr, "warning: included config file %s (from %s) does not exist\n", path, layer.path)
+				continue
+			}
+			includes = append(includes, included)
+		}
+	}
+
+	locked := map[string]bool{}
+	if system != nil {
+		for _, key := range stringListValue(mappingValue(system.root, "locked")) {
+			locked[key] = true
+		}
+	}
+
+	m := configMerger{
+		merged:  &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"},
+		origins: map[string]string{},
+		locked:  locked,
+	}
+	var files []string
+	ordered := append(append([]*configLayer{system}, includes...), user, project)
+	for _, layer := range ordered {
+		if layer == nil {
+			continue
+		}
+		files = append(files, layer.path)
+		if layer != system && mappingValue(layer.root, "locked") != nil {
+			fmt.Fprintf(os.Stderr, "warning: ignoring \"locked\" in %s (only %s can lock keys)\n", layer.path, systemConfigPath)
+		}
+		if layer != system && layer != user && mappingValue(layer.root, "include") != nil {
+			fmt.Fprintf(os.Stderr, "warning: ignoring \"include\" in %s (only the system and user config files can include team files)\n", layer.path)
+		}
+		m.merge(m.merged, layer.root, "", layer.path, layer != system)
+	}
+
+	var cfg UserConfig
+	if err := m.merged.Decode(&cfg); err != nil {
+		return UserConfig{}, fmt.Errorf("decode config files: %w", err)
+	}
+	cfg.origins = m.origins
+	cfg.files = files
+	for key := range locked {
+		cfg.Locked = append(cfg.Locked, key)
+	}
+	sort.Strings(cfg.Locked)
+	return cfg, nil
+}
+
+// lockedOptionKeys maps the ValueSource keys that flags, SWAMP_* variables
+// and presets can set to the config key a system lock names.
+var lockedOptionKeys = map[string]string{
+	"profile":             "profile",
+	"role":                "preferred_role",
+	"workers":             "discovery.workers",
+	"regions":             "discovery.regions",
+	"all-regions":         "discovery.all_regions",
+	"include-stopped":     "discovery.include_stopped",
+	"ssm-status":          "discovery.ssm_status",
+	"timeout":             "discovery.timeout",
+	"call-timeout":        "discovery.call_timeout",
+	"filter":              "discovery.filter",
+	"cache":               "cache.enabled",
+	"cache-dir":           "cache.dir",
+	"cache-mode":          "cache.mode",
+	"cache-ttl-accounts":  "cache.ttl_accounts",
+	"cache-ttl-roles":     "cache.ttl_roles",
+	"cache-ttl-regions":   "cache.ttl_regions",
+	"cache-ttl-instances": "cache.ttl_instances",
+	"cache-encrypt":       "cache.encrypt",
+	"skip-region-select":  "ux.skip_region_select",
+	"no-auto-select":      "ux.auto_select_single",
+	"picker":              "ux.picker",
+	"resume":              "ux.resume_by_default",
+}
+
+// enforceConfigLocks rejects a flag, SWAMP_* variable or preset that
+// changed a key the system config locks.
+func enforceConfigLocks(cfg UserConfig, sources map[string]string) error {
+	if len(cfg.Locked) == 0 {
+		return nil
+	}
+	keys := make([]string, 0, len(lockedOptionKeys))
+	for key := range lockedOptionKeys {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		source := sources[key]
+		if source == "built-in" || source == "" || strings.HasPrefix(source, "config") {
+			continue
+		}
+		if cfg.isLocked(lockedOptionKeys[key]) {
+			return fmt.Errorf("%s is locked by %s and cannot be overridden (source=%s)", lockedOptionKeys[key], systemConfigPath, source)
+		}
+	}
+	if cfg.isLocked("cache.key_command") && strings.TrimSpace(os.Getenv("SWAMP_CACHE_KEY_COMMAND")) != "" {
+		return fmt.Errorf("cache.key_command is locked by %s and cannot be overridden (source=env(SWAMP_CACHE_KEY_COMMAND))", systemConfigPath)
+	}
+	return nil
+}
+
+func (c UserConfig) isLocked(key string) bool {
+	for _, locked := range c.Locked {
+		if key == locked || strings.HasPrefix(key, locked+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// readConfigLayer parses one config file; a missing or empty file is nil.
+func readConfigLayer(path string) (*configLayer, error) {
+	if path == "" {
+		return nil, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("read config file %q: %w", path, err)
+	}
+	if len(strings.TrimSpace(string(data))) == 0 {
+		return nil, nil
+	}
+	var root map[string]any
+	if err := yaml.Unmarshal(data, &root); err != nil {
+		return nil, fmt.Errorf("parse config file %q: %w", path, err)
+	}
+	warnUnknownConfigKeys(root)
+	var cfg UserConfig
+	if err := yaml.Unmarshal(data, &cfg); err != nil {
+		return nil, fmt.Errorf("decode config file %q: %w", path, err)
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("parse config file %q: %w", path, err)
+	}
+	if len(doc.Content) == 0 || doc.Content[0].Kind != yaml.MappingNode {
+		return nil, nil
+	}
+	return &configLayer{path: path, root: doc.Content[0]}, nil
+}
+
+// findProjectConfig returns the nearest .swamp.yaml in the working
+// directory or one of its parents.
+func findProjectConfig(userPath string) string {
+	dir, err := workingDirFn()
+	if err != nil {
+		return ""
+	}
+	for {
+		candidate := filepath.Join(dir, projectConfigName)
+		if info, err := os.Stat(candidate); err == nil && !info.IsDir() && candidate != userPath {
+			return candidate
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// restrictProjectLayer drops every key of a project file that is not in
+// projectConfigKeys, with a warning.
+func restrictProjectLayer(node *yaml.Node, path []string, file string) {
+	var kept []*yaml.Node
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyPath := append(append([]string{}, path...), node.Content[i].Value)
+		value := node.Content[i+1]
+		switch {
+		case projectKeyAllowed(keyPath):
+		case value.Kind == yaml.MappingNode && projectKeyParent(keyPath):
+			restrictProjectLayer(value, keyPath, file)
+		default:
+			fmt.Fprintf(os.Stderr, "warning: ignoring %s from %s: project config files can only set filters, regions, presets and display options\n", strings.Join(keyPath, "."), file)
+			continue
+		}
+		kept = append(kept, node.Content[i], value)
+	}
+	node.Content = kept
+}
+
+func projectKeyAllowed(keyPath []string) bool {
+	for _, allowed := range projectConfigKeys {
+		if len(allowed) <= len(keyPath) && keyPathMatches(allowed, keyPath[:len(allowed)]) {
+			return true
+		}
+	}
+	return false
+}
+
+func projectKeyParent(keyPath []string) bool {
+	for _, allowed := range projectConfigKeys {
+		if len(allowed) > len(keyPath) && keyPathMatches(allowed[:len(keyPath)], keyPath) {
+			return true
+		}
+	}
+	return false
+}
+
+func keyPathMatches(pattern, keyPath []string) bool {
+	for i := range pattern {
+		if pattern[i] != "*" && pattern[i] != keyPath[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// source names the file a merged config value came from, falling back to
+// label when the config was not loaded from files.
+func (c UserConfig) source(key, label string) string {
+	if file, ok := c.origins[key]; ok {
+		return "config(" + file + ")"
+	}
+	return label
+}
+
+type configMerger struct {
+	merged  *yaml.Node
+	origins map[string]string
+	locked  map[string]bool
+}
+
+// merge copies src over dst. Sections merge key by key; scalars and lists
+// replace. Empty values do not override, so a generated user file with
+// blank fields leaves team defaults alone.
+func (m *configMerger) merge(dst, src *yaml.Node, prefix, file string, lockable bool) {
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		name, value := src.Content[i].Value, src.Content[i+1]
+		key := name
+		if prefix != "" {
+			key = prefix + "." + name
+		}
+		if prefix == "" && (name == "include" || name == "locked") {
+			continue
+		}
+		if isEmptyConfigValue(value) {
+			continue
+		}
+		if lockable && m.isLocked(key) {
+			fmt.Fprintf(os.Stderr, "warning: ignoring %s from %s: locked by %s\n", key, file, systemConfigPath)
+			continue
+		}
+
+		if value.Kind != yaml.MappingNode {
+			setMappingValue(dst, name, value)
+			m.origins[key] = file
+			continue
+		}
+		existing := mappingValue(dst, name)
+		if existing == nil || existing.Kind != yaml.MappingNode {
+			existing = &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+			setMappingValue(dst, name, existing)
+		}
+		if key == "accounts" {
+			// The first matching account entry wins, so entries from higher
+			// layers go in front.
+			m.prependAccounts(existing, value, file, lockable)
+			continue
+		}
+		m.merge(existing, value, key, file, lockable)
+	}
+}
+
+func (m *configMerger) prependAccounts(dst, src *yaml.Node, file string, lockable bool) {
+	var front []*yaml.Node
+	for i := 0; i+1 < len(src.Content); i += 2 {
+		key := "accounts." + src.Content[i].Value
+		if lockable && m.isLocked(key) {
+			fmt.Fprintf(os.Stderr, "warning: ignoring %s from %s: locked by %s\n", key, file, systemConfigPath)
+			continue
+		}
+		front = append(front, src.Content[i], src.Content[i+1])
+		m.recordOrigins(key, src.Content[i+1], file)
+	}
+	var rest []*yaml.Node
+	for i := 0; i+1 < len(dst.Content); i += 2 {
+		if mappingValue(src, dst.Content[i].Value) == nil {
+			rest = append(rest, dst.Content[i], dst.Content[i+1])
+		}
+	}
+	dst.Content = append(front, rest...)
+}
+
+func (m *configMerger) isLocked(key string) bool {
+	for locked := range m.locked {
+		if key == locked || strings.HasPrefix(key, locked+".") {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *configMerger) recordOrigins(key string, value *yaml.Node, file string) {
+	m.origins[key] = file
+	if value.Kind == yaml.MappingNode {
+		for i := 0; i+1 < len(value.Content); i += 2 {
+			m.recordOrigins(key+"."+value.Content[i].Value, value.Content[i+1], file)
+		}
+	}
+}
+
+func isEmptyConfigValue(node *yaml.Node) bool {
+	switch node.Kind {
+	case yaml.ScalarNode:
+		return node.Tag == "!!null" || (node.Tag == "!!str" && strings.TrimSpace(node.Value) == "")
+	case yaml.SequenceNode, yaml.MappingNode:
+		return len(node.Content) == 0
+	}
+	return false
+}
+
+func mappingValue(node *yaml.Node, name string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+func setMappingValue(node *yaml.Node, name string, value *yaml.Node) {
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == name {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: name}, value)
+}
+
+func stringListValue(node *yaml.Node) []string {
+	if node == nil {
+		return nil
+	}
+	if node.Kind == yaml.ScalarNode && strings.TrimSpace(node.Value) != "" {
+		return []string{strings.TrimSpace(node.Value)}
+	}
+	var out []string
+	for _, item := range node.Content {
+		if item.Kind == yaml.ScalarNode && strings.TrimSpace(item.Value) != "" {
+			out = append(out, strings.TrimSpace(item.Value))
+		}
+	}
+	return out
+}
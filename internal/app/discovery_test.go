@@ -1,6 +1,8 @@
 package app
 
 import (
+	"bytes"
+	"context"
 	"strings"
 	"testing"
 )
@@ -11,7 +13,7 @@ func TestDiscoverRoleTargetsPreferredRoleFallback(t *testing.T) {
 		fetchRolesForAcctFetcher = origBuild
 	}()
 
-	fetchRolesForAcctFetcher = func(profile, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
+	fetchRolesForAcctFetcher = func(ctx context.Context, profile, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
 		return []roleTarget{
 			{AccountID: accountID, AccountName: accountName, RoleName: "ReadOnly"},
 		}, nil
@@ -25,7 +27,7 @@ func TestDiscoverRoleTargetsPreferredRoleFallback(t *testing.T) {
 		CacheEnabled:      false,
 	}
 	accounts := []ssoAccountsResponse{testAccount("123", "acct")}
-	targets, err := discoverRoleTargets(opts, accounts, "us-east-1", "token")
+	targets, err := discoverRoleTargets(context.Background(), opts, accounts, "us-east-1", "token")
 	if err != nil {
 		t.Fatalf("discoverRoleTargets failed: %v", err)
 	}
@@ -38,7 +40,7 @@ func TestDiscoverRoleTargetsExplicitRoleNoMatchFails(t *testing.T) {
 	origBuild := fetchRolesForAcctFetcher
 	defer func() { fetchRolesForAcctFetcher = origBuild }()
 
-	fetchRolesForAcctFetcher = func(profile, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
+	fetchRolesForAcctFetcher = func(ctx context.Context, profile, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
 		return []roleTarget{
 			{AccountID: accountID, AccountName: accountName, RoleName: "ReadOnly"},
 		}, nil
@@ -51,8 +53,63 @@ func TestDiscoverRoleTargetsExplicitRoleNoMatchFails(t *testing.T) {
 		CacheEnabled: false,
 	}
 	accounts := []ssoAccountsResponse{testAccount("123", "acct")}
-	_, err := discoverRoleTargets(opts, accounts, "us-east-1", "token")
+	_, err := discoverRoleTargets(context.Background(), opts, accounts, "us-east-1", "token")
 	if err == nil || !strings.Contains(err.Error(), "no roles matched") {
 		t.Fatalf("expected no roles matched error, got %v", err)
 	}
 }
+
+func TestDiscoverRoleTargetsHidesDeniedRoles(t *testing.T) {
+	origBuild := fetchRolesForAcctFetcher
+	defer func() { fetchRolesForAcctFetcher = origBuild }()
+
+	fetchRolesForAcctFetcher = func(ctx context.Context, profile, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
+		return []roleTarget{
+			{AccountID: accountID, AccountName: accountName, RoleName: "AdministratorAccess"},
+			{AccountID: accountID, AccountName: accountName, RoleName: "ReadOnly"},
+		}, nil
+	}
+
+	opts := Options{
+		Profile:           "p",
+		Workers:           1,
+		RoleFilter:        "AdministratorAccess",
+		RoleFromPreferred: true,
+		policy:            accessPolicy{denyRoles: []string{"administrator*"}},
+	}
+	accounts := []ssoAccountsResponse{testAccount("123", "acct")}
+	targets, err := discoverRoleTargets(context.Background(), opts, accounts, "us-east-1", "token")
+	if err != nil {
+		t.Fatalf("discoverRoleTargets failed: %v", err)
+	}
+	if len(targets) != 1 || targets[0].RoleName != "ReadOnly" {
+		t.Fatalf("expected the denied role to stay hidden, got %+v", targets)
+	}
+}
+
+func TestDiscoveryReportsProgressToOptionsWriter(t *testing.T) {
+	origAccounts, origRoles := listSSOAccountsFetcher, fetchRolesForAcctFetcher
+	defer func() { listSSOAccountsFetcher, fetchRolesForAcctFetcher = origAccounts, origRoles }()
+
+	listSSOAccountsFetcher = func(ctx context.Context, profile, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
+		return []ssoAccountsResponse{testAccount("123", "acct")}, nil
+	}
+	fetchRolesForAcctFetcher = func(ctx context.Context, profile, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
+		return []roleTarget{{AccountID: accountID, AccountName: accountName, RoleName: "ReadOnly"}}, nil
+	}
+
+	var progress bytes.Buffer
+	opts := Options{Profile: "p", Workers: 1, RoleFilter: "Admin", RoleFromPreferred: true, progress: &progress}
+	accounts, err := discoverAccounts(context.Background(), opts, "us-east-1", "token")
+	if err != nil {
+		t.Fatalf("discoverAccounts: %v", err)
+	}
+	if _, err := discoverRoleTargets(context.Background(), opts, accounts, "us-east-1", "token"); err != nil {
+		t.Fatalf("discoverRoleTargets: %v", err)
+	}
+	for _, want := range []string{"Discovering accessible AWS accounts", "Discovering viable SSO roles", `Preferred role "Admin" was not found`} {
+		if !strings.Contains(progress.String(), want) {
+			t.Fatalf("expected %q in the progress writer, got:\n%s", want, progress.String())
+		}
+	}
+}
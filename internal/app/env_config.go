@@ -0,0 +1,143 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const configPathEnv = "SWAMP_CONFIG"
+
+// envSetting maps a SWAMP_* variable onto the option behind the flag key.
+type envSetting struct {
+	name  string
+	key   string
+	apply func(o *Options, value string) error
+}
+
+var envSettings = []envSetting{
+	{"SWAMP_PROFILE", "profile", envString(func(o *Options) *string { return &o.Profile })},
+	{"SWAMP_WORKERS", "workers", envInt(func(o *Options) *int { return &o.Workers })},
+	{"SWAMP_ACCOUNT", "account", envString(func(o *Options) *string { return &o.AccountFilter })},
+	{"SWAMP_PREFERRED_ROLE", "role", func(o *Options, v string) error {
+		o.RoleFilter = v
+		o.RoleFromPreferred = true
+		return nil
+	}},
+	{"SWAMP_ROLE", "role", func(o *Options, v string) error {
+		o.RoleFilter = v
+		o.RoleFromPreferred = false
+		return nil
+	}},
+	{"SWAMP_REGIONS", "regions", envString(func(o *Options) *string { return &o.RegionsArg })},
+	{"SWAMP_ALL_REGIONS", "all-regions", envBool(func(o *Options) *bool { return &o.AllRegions })},
+	{"SWAMP_SSM_STATUS", "ssm-status", envBool(func(o *Options) *bool { return &o.SSMStatus })},
+	{"SWAMP_SKIP_REGION_SELECT", "skip-region-select", envBool(func(o *Options) *bool { return &o.SkipRegionSelect })},
+	{"SWAMP_INCLUDE_STOPPED", "include-stopped", envBool(func(o *Options) *bool { return &o.IncludeStopped })},
+	{"SWAMP_RESUME", "resume", envBool(func(o *Options) *bool { return &o.Resume })},
+	{"SWAMP_LAST", "last", func(o *Options, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		o.LastN = n
+		o.Last = n > 0
+		return nil
+	}},
+	{"SWAMP_NO_AUTO_SELECT", "no-auto-select", envBool(func(o *Options) *bool { return &o.NoAutoSelect })},
+	{"SWAMP_PICKER", "picker", envString(func(o *Options) *string { return &o.Picker })},
+	{"SWAMP_PRESET", "preset", func(o *Options, v string) error {
+		// A preset named on the command line (`swamp NAME`) wins.
+		if o.Preset == "" {
+			o.Preset = v
+		}
+		return nil
+	}},
+	{"SWAMP_DOCUMENT", "document", envString(func(o *Options) *string { return &o.Document })},
+	{"SWAMP_FILTER", "filter", envString(func(o *Options) *string { return &o.Filter })},
+	{"SWAMP_CACHE", "cache", envBool(func(o *Options) *bool { return &o.CacheEnabled })},
+	{"SWAMP_CACHE_DIR", "cache-dir", func(o *Options, v string) error {
+		o.CacheDir = expandTilde(v)
+		return nil
+	}},
+	{"SWAMP_CACHE_MODE", "cache-mode", envString(func(o *Options) *string { return &o.CacheMode })},
+	{"SWAMP_CACHE_TTL_ACCOUNTS", "cache-ttl-accounts", envDuration(func(o *Options) *time.Duration { return &o.CacheTTLAccounts })},
+	{"SWAMP_CACHE_TTL_ROLES", "cache-ttl-roles", envDuration(func(o *Options) *time.Duration { return &o.CacheTTLRoles })},
+	{"SWAMP_CACHE_TTL_REGIONS", "cache-ttl-regions", envDuration(func(o *Options) *time.Duration { return &o.CacheTTLRegions })},
+	{"SWAMP_CACHE_TTL_INSTANCES", "cache-ttl-instances", envDuration(func(o *Options) *time.Duration { return &o.CacheTTLInstances })},
+	{"SWAMP_CACHE_ENCRYPT", "cache-encrypt", envBool(func(o *Options) *bool { return &o.CacheEncrypt })},
+	{"SWAMP_CACHE_KEY_COMMAND", "", envString(func(o *Options) *string { return &o.CacheKeyCommand })},
+	{"SWAMP_TIMEOUT", "timeout", envDuration(func(o *Options) *time.Duration { return &o.Timeout })},
+	{"SWAMP_CALL_TIMEOUT", "call-timeout", envDuration(func(o *Options) *time.Duration { return &o.CallTimeout })},
+}
+
+// applyEnv layers SWAMP_* variables over config values. Flags set on the
+// command line are left alone, and AWS_PROFILE fills in the profile when
+// nothing else did.
+func applyEnv(out *Options, cli Options, sources map[string]string) error {
+	for _, s := range envSettings {
+		if s.key != "" && cli.flagChanged(s.key) {
+			continue
+		}
+		value, ok := os.LookupEnv(s.name)
+		value = strings.TrimSpace(value)
+		if !ok || value == "" {
+			continue
+		}
+		if err := s.apply(out, value); err != nil {
+			return fmt.Errorf("invalid environment value %s=%q: %w", s.name, value, err)
+		}
+		if s.key != "" {
+			sources[s.key] = "env(" + s.name + ")"
+		}
+	}
+	if out.Profile == "" && !cli.flagChanged("profile") {
+		if profile := strings.TrimSpace(os.Getenv("AWS_PROFILE")); profile != "" {
+			out.Profile = profile
+			sources["profile"] = "env(AWS_PROFILE)"
+		}
+	}
+	return nil
+}
+
+func envString(field func(*Options) *string) func(*Options, string) error {
+	return func(o *Options, v string) error {
+		*field(o) = v
+		return nil
+	}
+}
+
+func envBool(field func(*Options) *bool) func(*Options, string) error {
+	return func(o *Options, v string) error {
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		*field(o) = b
+		return nil
+	}
+}
+
+func envInt(field func(*Options) *int) func(*Options, string) error {
+	return func(o *Options, v string) error {
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		*field(o) = n
+		return nil
+	}
+}
+
+func envDuration(field func(*Options) *time.Duration) func(*Options, string) error {
+	return func(o *Options, v string) error {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		*field(o) = d
+		return nil
+	}
+}
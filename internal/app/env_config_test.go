@@ -0,0 +1,76 @@
+package app
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestMergeOptionsEnvLayersBetweenConfigAndFlags(t *testing.T) {
+	t.Setenv("SWAMP_PROFILE", "env-profile")
+	t.Setenv("SWAMP_REGIONS", "eu-west-1,eu-central-1")
+	t.Setenv("SWAMP_CACHE_MODE", "speed")
+	t.Setenv("SWAMP_CACHE_TTL_INSTANCES", "5m")
+	t.Setenv("SWAMP_WORKERS", "4")
+	cli := Options{
+		Workers: 20,
+		FlagSet: map[string]bool{"workers": true},
+	}
+	cfg := UserConfig{
+		Profile:   "config-profile",
+		Cache:     userConfigCache{Mode: "balanced"},
+		Discovery: userConfigDisc{Regions: []string{"us-east-1"}},
+	}
+
+	got, err := mergeOptions(cli, cfg)
+	if err != nil {
+		t.Fatalf("mergeOptions: %v", err)
+	}
+	if got.Profile != "env-profile" || got.RegionsArg != "eu-west-1,eu-central-1" || got.CacheMode != "speed" || got.CacheTTLInstances != 5*time.Minute {
+		t.Fatalf("expected env values over config, got %+v", got)
+	}
+	if got.Workers != 20 || sourceOf(got, "workers") != "flag" {
+		t.Fatalf("expected flag workers to win, got %d (source=%s)", got.Workers, sourceOf(got, "workers"))
+	}
+	if src := sourceOf(got, "cache-mode"); src != "env(SWAMP_CACHE_MODE)" {
+		t.Fatalf("expected env source, got %q", src)
+	}
+}
+
+func TestMergeOptionsAWSProfileFallback(t *testing.T) {
+	t.Setenv("SWAMP_PROFILE", "")
+	t.Setenv("AWS_PROFILE", "aws-profile")
+
+	got, err := mergeOptions(Options{}, UserConfig{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Profile != "aws-profile" || sourceOf(got, "profile") != "env(AWS_PROFILE)" {
+		t.Fatalf("expected AWS_PROFILE fallback, got %q (source=%s)", got.Profile, sourceOf(got, "profile"))
+	}
+
+	got, err = mergeOptions(Options{}, UserConfig{Profile: "config-profile"})
+	if err != nil || got.Profile != "config-profile" {
+		t.Fatalf("expected config profile over AWS_PROFILE, got %q (err=%v)", got.Profile, err)
+	}
+}
+
+func TestMergeOptionsRejectsInvalidEnvValue(t *testing.T) {
+	t.Setenv("SWAMP_INCLUDE_STOPPED", "maybe")
+	_, err := mergeOptions(Options{}, UserConfig{})
+	if err == nil || !strings.Contains(err.Error(), "SWAMP_INCLUDE_STOPPED") {
+		t.Fatalf("expected env parse error, got %v", err)
+	}
+}
+
+func TestResolveConfigPathFromEnv(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "swamp.yaml")
+	t.Setenv(configPathEnv, path)
+	if got := resolveConfigPath(""); got != path {
+		t.Fatalf("expected %s, got %s", path, got)
+	}
+	if got := resolveConfigPath("/explicit.yaml"); got != "/explicit.yaml" {
+		t.Fatalf("expected --config to win, got %s", got)
+	}
+}
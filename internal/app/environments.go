@@ -0,0 +1,173 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+var errNotConfirmed = errors.New("confirmation did not match; not connecting")
+
+var confirmProtectedFn = func(opts Options, c instanceCandidate) error {
+	return opts.confirmProtected(os.Stdin, os.Stdout, c)
+}
+
+var environmentColors = map[string]string{
+	"red":     "31",
+	"green":   "32",
+	"yellow":  "33",
+	"blue":    "34",
+	"magenta": "35",
+	"cyan":    "36",
+	"white":   "37",
+}
+
+// userConfigEnvironments keeps the `environments:` entries in file order,
+// since an account gets the first environment that lists it.
+type userConfigEnvironments []userConfigEnvironmentEntry
+
+type userConfigEnvironmentEntry struct {
+	Name string
+	userConfigEnvironment
+}
+
+type userConfigEnvironment struct {
+	Accounts  []string `yaml:"accounts"`
+	Color     string   `yaml:"color"`
+	Protected bool     `yaml:"protected"`
+	Confirm   string   `yaml:"confirm"`
+}
+
+func (e *userConfigEnvironments) UnmarshalYAML(node *yaml.Node) error {
+	if node.Kind != yaml.MappingNode {
+		return fmt.Errorf("line %d: environments must map a label to its accounts", node.Line)
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		var entry userConfigEnvironment
+		if err := node.Content[i+1].Decode(&entry); err != nil {
+			return err
+		}
+		*e = append(*e, userConfigEnvironmentEntry{Name: node.Content[i].Value, userConfigEnvironment: entry})
+	}
+	return nil
+}
+
+// accountEnvironment is a parsed `environments:` entry. It is saved in the
+// reload spec, so __scan labels lines the same way.
+type accountEnvironment struct {
+	Name      string   `json:"name"`
+	Accounts  []string `json:"accounts"`
+	Color     string   `json:"color"`
+	Protected bool     `json:"protected"`
+	Confirm   string   `json:"confirm"`
+}
+
+func parseEnvironments(entries userConfigEnvironments) ([]accountEnvironment, error) {
+	out := make([]accountEnvironment, 0, len(entries))
+	for _, e := range entries {
+		name := strings.TrimSpace(e.Name)
+		env := accountEnvironment{
+			Name:      name,
+			Color:     strings.ToLower(strings.TrimSpace(e.Color)),
+			Protected: e.Protected,
+			Confirm:   strings.TrimSpace(e.Confirm),
+		}
+		if env.Color != "" {
+			if _, ok := environmentColors[env.Color]; !ok {
+				return nil, fmt.Errorf("invalid config value environments.%s.color=%q (expected one of %s)", name, e.Color, strings.Join(environmentColorNames(), ", "))
+			}
+		}
+		for _, match := range e.Accounts {
+			match = strings.TrimSpace(match)
+			if _, err := path.Match(strings.ToLower(match), ""); err != nil || match == "" {
+				return nil, fmt.Errorf("invalid config value environments.%s.accounts: %q is not an account ID or name glob", name, match)
+			}
+			env.Accounts = append(env.Accounts, match)
+		}
+		out = append(out, env)
+	}
+	return out, nil
+}
+
+func environmentColorNames() []string {
+	names := make([]string, 0, len(environmentColors))
+	for name := range environmentColors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// environmentFor returns the first of the config's `environments:` entries
+// listing the account, or nil.
+func (o Options) environmentFor(accountID, accountName string) *accountEnvironment {
+	for i := range o.environments {
+		for _, match := range o.environments[i].Accounts {
+			if accountMatches(match, accountID, accountName) {
+				return &o.environments[i]
+			}
+		}
+	}
+	return nil
+}
+
+// environmentLabel renders "[prod] " in the environment's color, or "" for
+// accounts without one. NO_COLOR drops the color but keeps the label.
+func (o Options) environmentLabel(accountID, accountName string) string {
+	env := o.environmentFor(accountID, accountName)
+	if env == nil {
+		return ""
+	}
+	label := "[" + env.Name + "]"
+	if code, ok := environmentColors[env.Color]; ok && os.Getenv("NO_COLOR") == "" {
+		label = "\x1b[1;" + code + "m" + label + "\x1b[0m"
+	}
+	return label + " "
+}
+
+var ansiEscape = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+
+// stripANSI removes color codes; fzf --ansi prints selections without them.
+func stripANSI(s string) string {
+	return ansiEscape.ReplaceAllString(s, "")
+}
+
+// announceTarget prints a connection banner prefixed with the target's
+// environment label.
+func (o Options) announceTarget(c instanceCandidate, format string, args ...any) {
+	fmt.Println(o.environmentLabel(c.AccountID, c.AccountName) + fmt.Sprintf(format, args...))
+}
+
+// confirmProtected makes the user type the account name, or the
+// environment's confirm phrase, before connecting to a protected account.
+func (o Options) confirmProtected(in io.Reader, out io.Writer, c instanceCandidate) error {
+	env := o.environmentFor(c.AccountID, c.AccountName)
+	if env == nil || !env.Protected {
+		return nil
+	}
+	phrase := env.Confirm
+	if phrase == "" {
+		phrase = c.AccountName
+	}
+	if phrase == "" {
+		phrase = c.AccountID
+	}
+	fmt.Fprintf(out, "%s%s (%s) is a protected account. Type %q to connect: ", o.environmentLabel(c.AccountID, c.AccountName), c.AccountName, c.AccountID, phrase)
+	answer, err := bufio.NewReader(in).ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(out)
+		return errNotConfirmed
+	}
+	if strings.TrimSpace(answer) != phrase {
+		return errNotConfirmed
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestEnvironmentsFirstListedEnvironmentWins(t *testing.T) {
+	dir := isolateConfigLayers(t)
+	path := filepath.Join(dir, "config.yaml")
+	writeConfigLayerFile(t, path, `environments:
+  prod:
+    accounts: ["123456789012", "prod-*"]
+    color: red
+    protected: true
+  staging:
+    accounts: ["*"]
+    color: Yellow
+`)
+	cfg, err := loadLayeredConfig(path)
+	if err != nil {
+		t.Fatalf("loadLayeredConfig: %v", err)
+	}
+	opts, err := mergeOptions(Options{Workers: 1}, cfg)
+	if err != nil {
+		t.Fatalf("mergeOptions: %v", err)
+	}
+	t.Setenv("NO_COLOR", "")
+
+	if env := opts.environmentFor("999", "Prod-Search"); env == nil || env.Name != "prod" {
+		t.Fatalf("expected the name glob to pick prod, got %+v", env)
+	}
+	if env := opts.environmentFor("123456789012", "payments"); env == nil || env.Name != "prod" {
+		t.Fatalf("expected the account ID to pick prod, got %+v", env)
+	}
+	if got := opts.environmentLabel("1", "sandbox"); got != "\x1b[1;33m[staging]\x1b[0m " {
+		t.Fatalf("expected a yellow staging label, got %q", got)
+	}
+	t.Setenv("NO_COLOR", "1")
+	if got := opts.environmentLabel("1", "sandbox"); got != "[staging] " {
+		t.Fatalf("expected a plain label with NO_COLOR, got %q", got)
+	}
+
+	cfg.Environments[1].Color = "orange"
+	if _, err := mergeOptions(Options{Workers: 1}, cfg); err == nil || !strings.Contains(err.Error(), "environments.staging.color") {
+		t.Fatalf("expected an invalid color error, got %v", err)
+	}
+}
+
+func TestConfirmProtectedRequiresPhrase(t *testing.T) {
+	opts := Options{environments: []accountEnvironment{
+		{Name: "prod", Accounts: []string{"prod-*"}, Protected: true},
+		{Name: "prod-db", Accounts: []string{"db-*"}, Protected: true, Confirm: "yes, prod"},
+		{Name: "dev", Accounts: []string{"dev-*"}},
+	}}
+	cases := []struct {
+		account string
+		input   string
+		wantErr bool
+	}{
+		{"dev-1", "", false},
+		{"prod-payments", "prod-payments\n", false},
+		{"prod-payments", "staging-payments\n", true},
+		{"prod-payments", "", true},
+		{"db-main", "db-main\n", true},
+		{"db-main", "  yes, prod \n", false},
+	}
+	for _, tc := range cases {
+		var out bytes.Buffer
+		err := opts.confirmProtected(strings.NewReader(tc.input), &out, instanceCandidate{AccountID: "1", AccountName: tc.account})
+		if tc.wantErr != errors.Is(err, errNotConfirmed) {
+			t.Fatalf("%s with %q: expected error=%t, got %v", tc.account, tc.input, tc.wantErr, err)
+		}
+	}
+}
+
+func TestSelectAccountShowsEnvironmentLabel(t *testing.T) {
+	t.Setenv("NO_COLOR", "")
+	orig := pickLineFn
+	defer func() { pickLineFn = orig }()
+
+	var shown []string
+	pickLineFn = func(_ Options, lines []string, prompt string, preview bool) (string, bool, error) {
+		shown = lines
+		// fzf --ansi prints the selection without color codes.
+		for _, line := range lines {
+			if strings.Contains(line, "prod") {
+				return stripANSI(line), true, nil
+			}
+		}
+		return "", false, nil
+	}
+	chosen, err := selectAccountWithFZF(Options{environments: []accountEnvironment{{Name: "prod", Accounts: []string{"prod"}, Color: "red"}}}, []ssoAccountsResponse{testAccount("111111111111", "dev"), testAccount("222222222222", "prod")})
+	if err != nil {
+		t.Fatalf("selectAccountWithFZF: %v", err)
+	}
+	if chosen == nil || chosen.AccountList[0].AccountID != "222222222222" {
+		t.Fatalf("expected prod to be selected, got %+v", chosen)
+	}
+	if !strings.Contains(strings.Join(shown, "\n"), "\x1b[1;31m[prod]\x1b[0m prod |") {
+		t.Fatalf("expected a red prod label, got %q", shown)
+	}
+}
@@ -0,0 +1,289 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/template"
+)
+
+const defaultExportNameTemplate = "{{.AccountName}}-{{.RoleName}}"
+
+// ExportInput is what `swamp export-profiles` was given on the command line.
+type ExportInput struct {
+	Path         string
+	NameTemplate string
+	DryRun       bool
+	Remove       bool
+}
+
+// exportProfileName is the data a --name template sees.
+type exportProfileName struct {
+	Profile     string
+	AccountID   string
+	AccountName string
+	RoleName    string
+}
+
+// The managed block is keyed by the source profile so exports from several
+// SSO profiles can live in one file.
+func exportBlockMarkers(profile string) (begin, end string) {
+	return fmt.Sprintf("# BEGIN swamp export-profiles (%s)", profile),
+		fmt.Sprintf("# END swamp export-profiles (%s)", profile)
+}
+
+// ExportProfiles discovers every account/role pair the SSO profile can use
+// and writes one named profile per pair into a managed block of the AWS
+// config (or another file). Rerunning replaces the block; nothing outside
+// it is touched.
+func ExportProfiles(ctx context.Context, opts Options, in ExportInput) error {
+	in.Path = strings.TrimSpace(in.Path)
+	if in.Path == "" {
+		in.Path = awsConfigPath()
+	}
+	in.Path = expandTilde(in.Path)
+	nameTmpl, err := parseExportNameTemplate(in.NameTemplate)
+	if err != nil {
+		return err
+	}
+
+	// Removing only needs the profile name for the block markers; the
+	// source profile may already be gone from ~/.aws/config.
+	if in.Remove {
+		local, err := resolveLocalConfig(opts)
+		if err != nil {
+			return err
+		}
+		return writeExportBlock(in.Path, local.Profile, nil, in.DryRun, os.Stdout)
+	}
+
+	// Progress goes to stderr; stdout is kept for --dry-run.
+	opts.progress = os.Stderr
+	resolved, cfg, err := resolveRuntimeOptions(opts)
+	if err != nil {
+		return err
+	}
+	if err := validateOptionsWithSource(resolved); err != nil {
+		return err
+	}
+	if err := configureAtRest(resolved); err != nil {
+		return err
+	}
+	if !cfg.SourceExists {
+		return fmt.Errorf("profile %q was not found in ~/.aws/config", resolved.Profile)
+	}
+	resolved.cacheStore = newCacheStore(resolved)
+	resolved.cacheStore.ctx = ctx
+	defer func() { _ = resolved.cacheStore.flushStats() }()
+	defer resolved.cacheStore.waitForRefreshes(os.Stderr, refreshGracePeriod)
+	resolved.budget = newDiscoveryBudget(resolved.Timeout)
+
+	accessToken, err := ensureSSOLoginAndGetToken(ctx, resolved.progressOut(), resolved.Profile, cfg.SSOStartURL)
+	if err != nil {
+		if ctx.Err() != nil {
+			return describeContextError(resolved, context.Cause(ctx))
+		}
+		return fmt.Errorf("failed to authenticate profile %q: %w", resolved.Profile, err)
+	}
+	ssoRegion := resolveSSORegion(cfg)
+	discoveryCtx, cancel := resolved.discoveryContext(ctx)
+	defer cancel()
+	accounts, err := discoverAccounts(discoveryCtx, resolved, ssoRegion, accessToken)
+	if err != nil {
+		return describeContextError(resolved, err)
+	}
+	targets, err := discoverRoleTargetsFn(discoveryCtx, resolved, accounts, ssoRegion, accessToken)
+	if err != nil {
+		return describeContextError(resolved, err)
+	}
+
+	section, err := renderExportProfiles(cfg, targets, nameTmpl)
+	if err != nil {
+		return err
+	}
+	return writeExportBlock(in.Path, resolved.Profile, section, in.DryRun, os.Stdout)
+}
+
+func parseExportNameTemplate(text string) (*template.Template, error) {
+	if strings.TrimSpace(text) == "" {
+		text = defaultExportNameTemplate
+	}
+	tmpl, err := template.New("name").Option("missingkey=error").Parse(text)
+	if err != nil {
+		return nil, fmt.Errorf("invalid --name template: %w", err)
+	}
+	return tmpl, nil
+}
+
+// renderExportProfiles writes the sections in a stable order so reruns only
+// change the file when the account/role matrix changed.
+func renderExportProfiles(base profileConfig, targets []roleTarget, nameTmpl *template.Template) ([]byte, error) {
+	type named struct {
+		name   string
+		target roleTarget
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("no account/role pairs to export")
+	}
+	var profiles []named
+	owner := map[string]roleTarget{}
+	for _, t := range targets {
+		var b strings.Builder
+		data := exportProfileName{Profile: base.Name, AccountID: t.AccountID, AccountName: t.AccountName, RoleName: t.RoleName}
+		if err := nameTmpl.Execute(&b, data); err != nil {
+			return nil, fmt.Errorf("render --name template: %w", err)
+		}
+		name := exportProfileNameClean(b.String())
+		if name == "" {
+			return nil, fmt.Errorf("--name template produced an empty profile name for %s/%s", t.AccountID, t.RoleName)
+		}
+		if prev, ok := owner[name]; ok {
+			return nil, fmt.Errorf("profile name %q is used by both %s/%s and %s/%s; include {{.AccountID}} in --name", name, prev.AccountID, prev.RoleName, t.AccountID, t.RoleName)
+		}
+		owner[name] = t
+		profiles = append(profiles, named{name: name, target: t})
+	}
+	sort.Slice(profiles, func(i, j int) bool { return profiles[i].name < profiles[j].name })
+
+	var buf bytes.Buffer
+	for i, p := range profiles {
+		if i > 0 {
+			buf.WriteString("\n")
+		}
+		writeProfileSection(&buf, p.name, base, p.target)
+	}
+	return buf.Bytes(), nil
+}
+
+// exportProfileNameClean replaces characters the INI format or the AWS CLI
+// do not accept in a profile name.
+func exportProfileNameClean(name string) string {
+	name = strings.TrimSpace(name)
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == ' ' || r == '\t' || r == '[' || r == ']' || r == '#' || r == ';' || r == '=':
+			return '-'
+		case r < ' ':
+			return -1
+		}
+		return r
+	}, name)
+}
+
+// writeExportBlock replaces (or with a nil section removes) the managed block
+// for profile in path; --dry-run prints the block instead. Profiles defined
+// outside the block keep precedence: a generated name that collides with one
+// is an error rather than a second section for the same profile.
+func writeExportBlock(path, profile string, section []byte, dryRun bool, stdout *os.File) error {
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("read %s: %w", path, err)
+	}
+
+	updated, err := replaceExportBlock(content, profile, section)
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	count := strings.Count(string(section), "[profile ")
+	if dryRun {
+		if section != nil {
+			fmt.Fprint(stdout, exportBlock(profile, section))
+		}
+		return nil
+	}
+	if bytes.Equal(updated, content) {
+		fmt.Fprintf(os.Stderr, "%s is up to date (%d profiles)\n", path, count)
+		return nil
+	}
+	if err := writeConfigFile(path, updated); err != nil {
+		return fmt.Errorf("write %s: %w", path, err)
+	}
+	if section == nil {
+		fmt.Fprintf(os.Stderr, "Removed profiles exported from %q in %s\n", profile, path)
+		return nil
+	}
+	fmt.Fprintf(os.Stderr, "Wrote %d profiles to %s\n", count, path)
+	return nil
+}
+
+func replaceExportBlock(content []byte, profile string, section []byte) ([]byte, error) {
+	begin, end := exportBlockMarkers(profile)
+	lines := strings.SplitAfter(string(content), "\n")
+	if len(lines) > 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+
+	start, stop := -1, -1
+	for i, line := range lines {
+		switch strings.TrimSpace(line) {
+		case begin:
+			if start >= 0 {
+				return nil, fmt.Errorf("found %q twice", begin)
+			}
+			start = i
+		case end:
+			if start >= 0 && stop < 0 {
+				stop = i
+			}
+		}
+	}
+	if start >= 0 && stop < 0 {
+		return nil, fmt.Errorf("found %q without %q; fix the file by hand", begin, end)
+	}
+
+	before, after := lines, []string(nil)
+	if start >= 0 {
+		before, after = lines[:start], lines[stop+1:]
+	}
+	outside := existingProfileNames(append(append([]string{}, before...), after...))
+	for _, name := range existingProfileNames(strings.SplitAfter(string(section), "\n")) {
+		if containsString(outside, name) {
+			return nil, fmt.Errorf("profile %q is already defined outside the swamp block; pick another --name template", name)
+		}
+	}
+
+	var buf bytes.Buffer
+	for _, line := range before {
+		buf.WriteString(line)
+	}
+	if section != nil {
+		if buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n")) {
+			buf.WriteString("\n")
+		}
+		if start < 0 && buf.Len() > 0 && !bytes.HasSuffix(buf.Bytes(), []byte("\n\n")) {
+			buf.WriteString("\n")
+		}
+		buf.WriteString(exportBlock(profile, section))
+	}
+	for _, line := range after {
+		buf.WriteString(line)
+	}
+	return buf.Bytes(), nil
+}
+
+func exportBlock(profile string, section []byte) string {
+	begin, end := exportBlockMarkers(profile)
+	return begin + "\n" +
+		"# Generated by `swamp export-profiles`; edits inside this block are overwritten.\n" +
+		string(section) + end + "\n"
+}
+
+func existingProfileNames(lines []string) []string {
+	var names []string
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			continue
+		}
+		section := strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(line, "["), "]"))
+		if name, ok := strings.CutPrefix(section, "profile "); ok {
+			names = append(names, strings.TrimSpace(name))
+		} else if section == "default" {
+			names = append(names, section)
+		}
+	}
+	return names
+}
@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestExportProfilesBlockIsIdempotent(t *testing.T) {
+	base := profileConfig{Name: "corp", SSOSession: "corp", Region: "eu-west-1"}
+	tmpl, err := parseExportNameTemplate("acme-{{.AccountName}}-{{.RoleName}}")
+	if err != nil {
+		t.Fatal(err)
+	}
+	targets := []roleTarget{
+		{AccountID: "222", AccountName: "prod", RoleName: "ReadOnly"},
+		{AccountID: "111", AccountName: "dev box", RoleName: "Admin"},
+	}
+	section, err := renderExportProfiles(base, targets, tmpl)
+	if err != nil {
+		t.Fatalf("renderExportProfiles: %v", err)
+	}
+	if !strings.HasPrefix(string(section), "[profile acme-dev-box-Admin]\nsso_session = corp\nsso_account_id = 111\nsso_role_name = Admin\nregion = eu-west-1\n") {
+		t.Fatalf("unexpected sections:\n%s", section)
+	}
+
+	path := filepath.Join(t.TempDir(), "config")
+	original := "[profile corp]\nsso_session = corp\n\n# my notes\n[sso-session corp]\nsso_start_url = https://example.awsapps.com/start\n"
+	if err := os.WriteFile(path, []byte(original), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := writeExportBlock(path, "corp", section, false, nil); err != nil {
+		t.Fatalf("writeExportBlock: %v", err)
+	}
+	first, _ := os.ReadFile(path)
+	if !strings.HasPrefix(string(first), original+"\n# BEGIN swamp export-profiles (corp)\n") || !strings.HasSuffix(string(first), "# END swamp export-profiles (corp)\n") {
+		t.Fatalf("expected the block appended after untouched content:\n%s", first)
+	}
+	if info, _ := os.Stat(path); info.Mode().Perm() != 0o644 {
+		t.Fatalf("expected the file mode to be kept, got %v", info.Mode())
+	}
+
+	// Content added after the block by hand survives a rerun with fewer roles.
+	edited := string(first) + "\n[profile manual]\nregion = us-east-1\n"
+	if err := os.WriteFile(path, []byte(edited), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	section, _ = renderExportProfiles(base, targets[:1], tmpl)
+	if err := writeExportBlock(path, "corp", section, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	second, _ := os.ReadFile(path)
+	if strings.Contains(string(second), "acme-dev-box-Admin") || !strings.Contains(string(second), "[profile acme-prod-ReadOnly]") || !strings.HasSuffix(string(second), "[profile manual]\nregion = us-east-1\n") {
+		t.Fatalf("expected only the block to change:\n%s", second)
+	}
+	if err := writeExportBlock(path, "corp", section, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if third, _ := os.ReadFile(path); string(third) != string(second) {
+		t.Fatalf("expected a rerun to leave the file unchanged:\n%s", third)
+	}
+
+	if err := writeExportBlock(path, "corp", nil, false, nil); err != nil {
+		t.Fatal(err)
+	}
+	if removed, _ := os.ReadFile(path); string(removed) != original+"\n\n[profile manual]\nregion = us-east-1\n" {
+		t.Fatalf("unexpected content after --remove:\n%q", removed)
+	}
+}
+
+func TestExportProfilesRejectsNameClashes(t *testing.T) {
+	base := profileConfig{Name: "corp", SSOStartURL: "https://example.awsapps.com/start", SSORegion: "us-east-1"}
+	tmpl, _ := parseExportNameTemplate("{{.RoleName}}")
+	if _, err := renderExportProfiles(base, []roleTarget{{AccountID: "1", RoleName: "Admin"}, {AccountID: "2", RoleName: "Admin"}}, tmpl); err == nil || !strings.Contains(err.Error(), "{{.AccountID}}") {
+		t.Fatalf("expected a duplicate name error, got %v", err)
+	}
+
+	tmpl, _ = parseExportNameTemplate("")
+	section, err := renderExportProfiles(base, []roleTarget{{AccountID: "1", AccountName: "prod", RoleName: "Admin"}}, tmpl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := replaceExportBlock([]byte("[profile prod-Admin]\nregion = us-east-1\n"), "corp", section); err == nil || !strings.Contains(err.Error(), "outside the swamp block") {
+		t.Fatalf("expected a clash with a hand-written profile, got %v", err)
+	}
+	if _, err := replaceExportBlock([]byte("# BEGIN swamp export-profiles (corp)\n[profile x]\n"), "corp", section); err == nil {
+		t.Fatal("expected an error for a block without an end marker")
+	}
+	if _, err := parseExportNameTemplate("{{.Nope"); err == nil {
+		t.Fatal("expected a template parse error")
+	}
+}
+
+func TestExportProfilesDryRunPrintsBlock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config")
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := writeExportBlock(path, "corp", []byte("[profile a]\n"), true, w); err != nil {
+		t.Fatal(err)
+	}
+	w.Close()
+	out, _ := io.ReadAll(r)
+	if !strings.Contains(string(out), "[profile a]\n# END swamp export-profiles (corp)\n") {
+		t.Fatalf("unexpected dry-run output %q", out)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Fatal("expected --dry-run not to create the file")
+	}
+}
+
+func TestExportProfilesRemoveSkipsTheDeletedSourceProfile(t *testing.T) {
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	awsDir := filepath.Join(home, ".aws")
+	if err := os.Mkdir(awsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(awsDir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(awsDir, "config")
+	content := "[default]\nregion = us-east-1\n\n" + exportBlock("gone", []byte("[profile gone-dev-Admin]\nsso_account_id = 111\n"))
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chmod(path, 0o644); err != nil {
+		t.Fatal(err)
+	}
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	writeConfigLayerFile(t, configPath, "profile: gone\n")
+
+	opts := Options{ConfigPath: configPath}
+	if err := ExportProfiles(context.Background(), opts, ExportInput{Remove: true}); err != nil {
+		t.Fatalf("ExportProfiles --remove: %v", err)
+	}
+	if got, _ := os.ReadFile(path); strings.Contains(string(got), "gone-dev-Admin") {
+		t.Fatalf("expected the block to be removed:\n%s", got)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o644 {
+		t.Fatalf("expected the file to stay 0644, got %v, %v", info.Mode().Perm(), err)
+	}
+	if info, err := os.Stat(awsDir); err != nil || info.Mode().Perm() != 0o755 {
+		t.Fatalf("expected ~/.aws to stay 0755, got %v, %v", info.Mode().Perm(), err)
+	}
+}
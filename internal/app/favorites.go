@@ -0,0 +1,493 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+const favoritesFileName = "favorites.json"
+
+var favoriteAliasPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]*$`)
+
+// favoritesFile lives next to the user config rather than in the cache
+// directory so --cache-clear does not throw favorites away.
+type favoritesFile struct {
+	Version   int                       `json:"version"`
+	Favorites map[string]favoriteTarget `json:"favorites"`
+}
+
+// favoriteTarget is either a full scope with an instance, or a scope plus a
+// Key=Value tag selector that is resolved at connect time.
+type favoriteTarget struct {
+	Profile   string          `json:"profile"`
+	Scope     recentScope     `json:"scope"`
+	Instance  *recentInstance `json:"instance,omitempty"`
+	Tag       string          `json:"tag,omitempty"`
+	CreatedAt string          `json:"created_at"`
+}
+
+// FavoriteInput is what `swamp fav add` was given on the command line.
+// With no scope flags the last successful connection is saved.
+type FavoriteInput struct {
+	Account  string
+	Role     string
+	Region   string
+	Instance string
+	Tag      string
+	Force    bool
+}
+
+func favoritesPath(configPath string) string {
+	return filepath.Join(filepath.Dir(resolveConfigPath(configPath)), favoritesFileName)
+}
+
+func loadFavorites(configPath string) (favoritesFile, error) {
+	data, err := os.ReadFile(favoritesPath(configPath))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return favoritesFile{Version: 1, Favorites: map[string]favoriteTarget{}}, nil
+		}
+		return favoritesFile{}, err
+	}
+	if data, err = openAtRest(data); err != nil {
+		return favoritesFile{}, fmt.Errorf("read %s: %w", favoritesPath(configPath), err)
+	}
+	var out favoritesFile
+	if err := json.Unmarshal(data, &out); err != nil {
+		return favoritesFile{}, fmt.Errorf("parse %s: %w", favoritesPath(configPath), err)
+	}
+	if out.Version == 0 {
+		out.Version = 1
+	}
+	if out.Favorites == nil {
+		out.Favorites = map[string]favoriteTarget{}
+	}
+	return out, nil
+}
+
+func saveFavorites(configPath string, favs favoritesFile) error {
+	path := favoritesPath(configPath)
+	dir := filepath.Dir(path)
+	if err := mkdirPrivate(dir); err != nil {
+		return err
+	}
+	content, err := json.MarshalIndent(favs, "", "  ")
+	if err != nil {
+		return err
+	}
+	if content, err = sealAtRest(content); err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(dir, "favorites-*.tmp")
+	if err != nil {
+		return err
+	}
+	tmpName := tmp.Name()
+	if _, err := tmp.Write(content); err != nil {
+		tmp.Close()
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	if err := os.Rename(tmpName, path); err != nil {
+		_ = os.Remove(tmpName)
+		return err
+	}
+	return nil
+}
+
+// updateFavorites applies fn to the favorites file under a lock and saves
+// the result unless fn fails.
+func updateFavorites(configPath string, fn func(*favoritesFile) error) error {
+	return withFileLock(favoritesPath(configPath), func() error {
+		favs, err := loadFavorites(configPath)
+		if err != nil {
+			return err
+		}
+		if err := fn(&favs); err != nil {
+			return err
+		}
+		if err := saveFavorites(configPath, favs); err != nil {
+			return fmt.Errorf("save favorites: %w", err)
+		}
+		return nil
+	})
+}
+
+func AddFavorite(opts Options, alias string, in FavoriteInput) error {
+	alias = strings.TrimPrefix(strings.TrimSpace(alias), "@")
+	if !favoriteAliasPattern.MatchString(alias) {
+		return fmt.Errorf("invalid favorite alias %q (use letters, digits, '.', '_' or '-')", alias)
+	}
+	resolved, err := resolveLocalOptions(opts)
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(resolved.Profile) == "" {
+		return fmt.Errorf("--profile is required (or set profile in config)")
+	}
+	fav, err := buildFavorite(resolved, in)
+	if err != nil {
+		return err
+	}
+
+	err = updateFavorites(resolved.ConfigPath, func(favs *favoritesFile) error {
+		if _, exists := favs.Favorites[alias]; exists && !in.Force {
+			return fmt.Errorf("favorite @%s already exists (use --force to replace it)", alias)
+		}
+		favs.Favorites[alias] = fav
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Saved @%s: %s\n", alias, favoriteSummary(fav))
+	return nil
+}
+
+func buildFavorite(opts Options, in FavoriteInput) (favoriteTarget, error) {
+	fav := favoriteTarget{
+		Profile:   opts.Profile,
+		CreatedAt: time.Now().UTC().Format(time.RFC3339),
+	}
+	if in.Account == "" && in.Role == "" && in.Region == "" && in.Instance == "" && in.Tag == "" {
+		recent, err := loadRecentTargets(opts.CacheDir)
+		if err != nil {
+			return favoriteTarget{}, fmt.Errorf("load recent targets: %w", err)
+		}
+		scope, inst, ok := recent.getLastInstance(opts.Profile)
+		if !ok {
+			return favoriteTarget{}, fmt.Errorf("no recent target for profile %q; pass --account, --role and --instance or --tag", opts.Profile)
+		}
+		fav.Scope = scope
+		fav.Instance = &inst
+		return fav, nil
+	}
+
+	if in.Account == "" || in.Role == "" {
+		return favoriteTarget{}, fmt.Errorf("--account and --role are required when saving an explicit favorite")
+	}
+	if !isAccountID(in.Account) {
+		return favoriteTarget{}, fmt.Errorf("--account must be a 12-digit account ID, got %q", in.Account)
+	}
+	if (in.Instance == "") == (in.Tag == "") {
+		return favoriteTarget{}, fmt.Errorf("exactly one of --instance or --tag is required")
+	}
+	fav.Scope = recentScope{AccountID: in.Account, RoleName: in.Role, Region: in.Region}
+	if in.Instance != "" {
+		if in.Region == "" {
+			return favoriteTarget{}, fmt.Errorf("--region is required with --instance")
+		}
+		fav.Instance = &recentInstance{InstanceID: in.Instance, Region: in.Region}
+		return fav, nil
+	}
+	if _, _, ok := parseTagSelector(in.Tag); !ok {
+		return favoriteTarget{}, fmt.Errorf("--tag must look like Key=Value, got %q", in.Tag)
+	}
+	fav.Tag = in.Tag
+	return fav, nil
+}
+
+func ListFavorites(opts Options) error {
+	resolved, err := resolveLocalOptions(opts)
+	if err != nil {
+		return err
+	}
+	favs, err := loadFavorites(resolved.ConfigPath)
+	if err != nil {
+		return err
+	}
+	return writeFavoritesTable(os.Stdout, favs)
+}
+
+func writeFavoritesTable(w io.Writer, favs favoritesFile) error {
+	if len(favs.Favorites) == 0 {
+		fmt.Fprintln(w, "No favorites saved. Add one with `swamp fav add <alias>`.")
+		return nil
+	}
+	aliases := make([]string, 0, len(favs.Favorites))
+	for alias := range favs.Favorites {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ALIAS\tPROFILE\tACCOUNT\tROLE\tREGION\tTARGET")
+	for _, alias := range aliases {
+		fav := favs.Favorites[alias]
+		fmt.Fprintf(tw, "@%s\t%s\t%s\t%s\t%s\t%s\n", alias, fav.Profile, favoriteAccount(fav.Scope), fav.Scope.RoleName, orDash(fav.region()), fav.target())
+	}
+	return tw.Flush()
+}
+
+func RemoveFavorites(opts Options, aliases []string) error {
+	resolved, err := resolveLocalOptions(opts)
+	if err != nil {
+		return err
+	}
+	err = updateFavorites(resolved.ConfigPath, func(favs *favoritesFile) error {
+		for _, alias := range aliases {
+			alias = strings.TrimPrefix(strings.TrimSpace(alias), "@")
+			if _, ok := favs.Favorites[alias]; !ok {
+				return fmt.Errorf("favorite @%s not found", alias)
+			}
+			delete(favs.Favorites, alias)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Removed %d favorite(s)\n", len(aliases))
+	return nil
+}
+
+func (f favoriteTarget) region() string {
+	if f.Instance != nil {
+		return f.Instance.Region
+	}
+	return f.Scope.Region
+}
+
+func (f favoriteTarget) target() string {
+	if f.Instance != nil {
+		return f.Instance.InstanceID
+	}
+	return "tag:" + f.Tag
+}
+
+func favoriteAccount(scope recentScope) string {
+	if scope.AccountName == "" {
+		return scope.AccountID
+	}
+	return fmt.Sprintf("%s (%s)", scope.AccountName, scope.AccountID)
+}
+
+func favoriteSummary(f favoriteTarget) string {
+	return fmt.Sprintf("profile=%s account=%s role=%s region=%s target=%s", f.Profile, f.Scope.AccountID, f.Scope.RoleName, orDash(f.region()), f.target())
+}
+
+func isAccountID(value string) bool {
+	if len(value) != 12 {
+		return false
+	}
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+func parseTagSelector(selector string) (string, string, bool) {
+	key, value, ok := strings.Cut(selector, "=")
+	key = strings.TrimSpace(key)
+	if !ok || key == "" {
+		return "", "", false
+	}
+	return key, strings.TrimSpace(value), true
+}
+
+// applyFavorite resolves opts.Favorite before the AWS profile config is
+// read, since the favorite decides which profile to use unless --profile
+// was given explicitly.
+func applyFavorite(opts *Options) error {
+	alias := strings.TrimPrefix(strings.TrimSpace(opts.Favorite), "@")
+	favs, err := loadFavorites(opts.ConfigPath)
+	if err != nil {
+		return err
+	}
+	fav, ok := favs.Favorites[alias]
+	if !ok {
+		return fmt.Errorf("favorite @%s not found (see `swamp fav ls`)", alias)
+	}
+	if !opts.flagChanged("profile") && fav.Profile != "" {
+		opts.Profile = fav.Profile
+		if opts.ValueSource != nil {
+			opts.ValueSource["profile"] = "favorite(@" + alias + ")"
+		}
+	}
+	opts.favorite = &fav
+	return nil
+}
+
+// connectFavorite connects straight to a favorite. It returns false when
+// the caller should fall back to the interactive flow in the favorite's
+// scope.
+func connectFavorite(ctx context.Context, opts Options, cfg profileConfig, fav favoriteTarget, ssoRegion string) (bool, error) {
+	if !opts.policy.allowsTarget(roleTarget{AccountID: fav.Scope.AccountID, AccountName: fav.Scope.AccountName, RoleName: fav.Scope.RoleName}) {
+		fmt.Printf("Favorite %s/%s is not allowed by policy; continuing interactively.\n", fav.Scope.AccountID, fav.Scope.RoleName)
+		return false, nil
+	}
+	if fav.Instance != nil {
+		return connectSavedTarget(ctx, opts, cfg, fav.Scope, *fav.Instance, ssoRegion)
+	}
+
+	key, value, _ := parseTagSelector(fav.Tag)
+	target := roleTarget{AccountID: fav.Scope.AccountID, AccountName: fav.Scope.AccountName, RoleName: fav.Scope.RoleName}
+	opts = opts.forTarget(target)
+	if _, err := opts.policy.sessionDocument(opts.Document); err != nil {
+		return false, err
+	}
+	tmpConfigPath, profileNames, err := buildTempAWSConfigFn(cfg, []roleTarget{target})
+	if err != nil {
+		return false, fmt.Errorf("failed to build temporary AWS config: %w", err)
+	}
+	defer func() {
+		opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
+	}()
+
+	regions := []string{fav.Scope.Region}
+	if fav.Scope.Region == "" {
+		discoveryCtx, cancel := opts.discoveryContext(ctx)
+		regions, err = discoverRegionsFn(discoveryCtx, opts, cfg, []roleTarget{target}, tmpConfigPath, profileNames, ssoRegion)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return false, context.Cause(ctx)
+			}
+			fmt.Printf("Favorite scope is no longer available (%v); continuing interactively.\n", err)
+			return false, nil
+		}
+	}
+
+	candidates, err := scanAllInstancesFn(ctx, opts, tmpConfigPath, []roleTarget{target}, profileNames, regions, opts.Workers, !opts.IncludeStopped)
+	if err != nil && ctx.Err() != nil {
+		return false, context.Cause(ctx)
+	}
+	var matches []instanceCandidate
+	for _, c := range candidates {
+		if v, ok := c.Tags[key]; ok && v == value {
+			matches = append(matches, c)
+		}
+	}
+	sort.Slice(matches, func(i, j int) bool {
+		return matches[i].DisplayLine < matches[j].DisplayLine
+	})
+
+	var selected *instanceCandidate
+	switch {
+	case len(matches) == 0:
+		fmt.Printf("No instances match tag %s; continuing interactively.\n", fav.Tag)
+		return false, nil
+	case len(matches) == 1 && !opts.NoAutoSelect:
+		selected = &matches[0]
+	default:
+		done := make(chan scanResult)
+		close(done)
+		var action pickerAction
+		selected, action, err = opts.pickInstance(matches, scanResult{Done: 1, Total: 1}, done, nil)
+		if err != nil {
+			return false, fmt.Errorf("selection failed: %w", err)
+		}
+		if action != pickerConnect || selected == nil {
+			return false, nil
+		}
+	}
+
+	scope := fav.Scope
+	scope.Region = selected.Region
+	if err := startSavedSession(opts, tmpConfigPath, scope, *selected); err != nil {
+		if errors.Is(err, errNotConfirmed) {
+			return false, err
+		}
+		fmt.Printf("Favorite connection failed (%v); continuing interactively.\n", err)
+		return false, nil
+	}
+	return true, nil
+}
+
+// favoritePin is a favorite the instance picker keeps on top, labelled
+// with its alias. It matches one instance, or every instance in its scope
+// carrying the tag for a tag favorite.
+type favoritePin struct {
+	Alias      string `json:"alias"`
+	AccountID  string `json:"account_id"`
+	RoleName   string `json:"role_name"`
+	Region     string `json:"region"`
+	InstanceID string `json:"instance_id,omitempty"`
+	TagKey     string `json:"tag_key,omitempty"`
+	TagValue   string `json:"tag_value,omitempty"`
+}
+
+func (p favoritePin) matches(c instanceCandidate) bool {
+	if c.AccountID != p.AccountID || c.RoleName != p.RoleName || (p.Region != "" && c.Region != p.Region) {
+		return false
+	}
+	if p.InstanceID != "" {
+		return c.InstanceID == p.InstanceID
+	}
+	value, ok := c.Tags[p.TagKey]
+	return ok && value == p.TagValue
+}
+
+// pinnedAlias returns the alias of the pinned favorite matching c. Lines
+// are labelled from opts.pins when they are rendered, like the frecency
+// marker, so candidates and the history saved from them keep their plain
+// display line.
+func (o Options) pinnedAlias(c instanceCandidate) string {
+	for _, pin := range o.pins {
+		if pin.matches(c) {
+			return pin.Alias
+		}
+	}
+	return ""
+}
+
+func (o Options) favoriteLabel(c instanceCandidate, display string) string {
+	if alias := o.pinnedAlias(c); alias != "" {
+		return "★ @" + alias + " | " + display
+	}
+	return display
+}
+
+// favoritePinsFor returns the favorites that fall inside the scan scope,
+// by alias. They only match instances the scan returns, so a saved
+// instance that is gone never shows up in the picker.
+func favoritePinsFor(favs favoritesFile, profile string, targets []roleTarget, regions []string) []favoritePin {
+	aliases := make([]string, 0, len(favs.Favorites))
+	for alias := range favs.Favorites {
+		aliases = append(aliases, alias)
+	}
+	sort.Strings(aliases)
+
+	var pins []favoritePin
+	for _, alias := range aliases {
+		fav := favs.Favorites[alias]
+		if fav.Profile != profile {
+			continue
+		}
+		pin := favoritePin{Alias: alias, AccountID: fav.Scope.AccountID, RoleName: fav.Scope.RoleName, Region: fav.Scope.Region}
+		if fav.Instance != nil {
+			pin.InstanceID, pin.Region = fav.Instance.InstanceID, fav.Instance.Region
+		} else if key, value, ok := parseTagSelector(fav.Tag); ok {
+			pin.TagKey, pin.TagValue = key, value
+		} else {
+			continue
+		}
+		// A tag favorite saved without --region matches in any region.
+		if pin.Region != "" && !containsString(regions, pin.Region) {
+			continue
+		}
+		for _, t := range targets {
+			if t.AccountID == pin.AccountID && t.RoleName == pin.RoleName {
+				pins = append(pins, pin)
+				break
+			}
+		}
+	}
+	return pins
+}
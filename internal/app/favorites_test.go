@@ -0,0 +1,211 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func newTestFavoriteOptions(t *testing.T) Options {
+	t.Helper()
+	dir := t.TempDir()
+	return Options{
+		Profile:    "dev",
+		ConfigPath: filepath.Join(dir, "config.yaml"),
+		CacheDir:   filepath.Join(dir, "cache"),
+		FlagSet:    map[string]bool{"profile": true, "cache-dir": true},
+	}
+}
+
+func TestAddFavoriteFromLastConnection(t *testing.T) {
+	opts := newTestFavoriteOptions(t)
+	scope := recentScope{AccountID: "111111111111", AccountName: "acct", RoleName: "Admin", Region: "eu-west-1"}
+	inst := recentInstance{InstanceID: "i-123", Region: "eu-west-1", DisplayLine: "web | i-123"}
+	if err := saveRecentTargets(opts.CacheDir, "dev", scope, inst); err != nil {
+		t.Fatalf("saveRecentTargets: %v", err)
+	}
+
+	if err := AddFavorite(opts, "@web", FavoriteInput{}); err != nil {
+		t.Fatalf("AddFavorite: %v", err)
+	}
+	favs, err := loadFavorites(opts.ConfigPath)
+	if err != nil {
+		t.Fatalf("loadFavorites: %v", err)
+	}
+	fav, ok := favs.Favorites["web"]
+	if !ok || fav.Profile != "dev" || fav.Instance == nil || fav.Instance.InstanceID != "i-123" || fav.Scope.AccountID != scope.AccountID {
+		t.Fatalf("unexpected favorite %+v", fav)
+	}
+
+	if err := AddFavorite(opts, "web", FavoriteInput{}); err == nil {
+		t.Fatal("expected duplicate alias to require --force")
+	}
+	if err := AddFavorite(opts, "web", FavoriteInput{Force: true}); err != nil {
+		t.Fatalf("expected --force to replace favorite: %v", err)
+	}
+}
+
+func TestAddFavoriteValidatesExplicitScope(t *testing.T) {
+	opts := newTestFavoriteOptions(t)
+	tests := []struct {
+		name string
+		in   FavoriteInput
+	}{
+		{name: "missing role", in: FavoriteInput{Account: "111111111111", Instance: "i-1", Region: "us-east-1"}},
+		{name: "account name", in: FavoriteInput{Account: "prod", Role: "Admin", Instance: "i-1", Region: "us-east-1"}},
+		{name: "instance without region", in: FavoriteInput{Account: "111111111111", Role: "Admin", Instance: "i-1"}},
+		{name: "instance and tag", in: FavoriteInput{Account: "111111111111", Role: "Admin", Instance: "i-1", Region: "us-east-1", Tag: "Name=web"}},
+		{name: "bad tag", in: FavoriteInput{Account: "111111111111", Role: "Admin", Tag: "web"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := AddFavorite(opts, "x", tt.in); err == nil {
+				t.Fatal("expected validation error")
+			}
+		})
+	}
+	if err := AddFavorite(opts, "bad alias", FavoriteInput{}); err == nil {
+		t.Fatal("expected invalid alias error")
+	}
+}
+
+func TestRemoveFavoritesAndList(t *testing.T) {
+	opts := newTestFavoriteOptions(t)
+	in := FavoriteInput{Account: "111111111111", Role: "Admin", Tag: "Role=bastion"}
+	if err := AddFavorite(opts, "bastion", in); err != nil {
+		t.Fatalf("AddFavorite: %v", err)
+	}
+	favs, _ := loadFavorites(opts.ConfigPath)
+	var buf bytes.Buffer
+	if err := writeFavoritesTable(&buf, favs); err != nil {
+		t.Fatalf("writeFavoritesTable: %v", err)
+	}
+	if !strings.Contains(buf.String(), "@bastion") || !strings.Contains(buf.String(), "tag:Role=bastion") {
+		t.Fatalf("unexpected table:\n%s", buf.String())
+	}
+
+	if err := RemoveFavorites(opts, []string{"@bastion"}); err != nil {
+		t.Fatalf("RemoveFavorites: %v", err)
+	}
+	if err := RemoveFavorites(opts, []string{"bastion"}); err == nil {
+		t.Fatal("expected error removing a missing favorite")
+	}
+}
+
+func TestApplyFavoriteUsesFavoriteProfileUnlessFlagSet(t *testing.T) {
+	opts := newTestFavoriteOptions(t)
+	favs := favoritesFile{Version: 1, Favorites: map[string]favoriteTarget{
+		"web": {Profile: "prod", Scope: recentScope{AccountID: "111111111111", RoleName: "Admin"}, Instance: &recentInstance{InstanceID: "i-1", Region: "us-east-1"}},
+	}}
+	if err := saveFavorites(opts.ConfigPath, favs); err != nil {
+		t.Fatalf("saveFavorites: %v", err)
+	}
+
+	run := Options{Favorite: "web", ConfigPath: opts.ConfigPath, Profile: "dev", ValueSource: map[string]string{}}
+	if err := applyFavorite(&run); err != nil {
+		t.Fatalf("applyFavorite: %v", err)
+	}
+	if run.Profile != "prod" || run.ValueSource["profile"] != "favorite(@web)" || run.favorite == nil {
+		t.Fatalf("expected favorite profile to apply, got %+v", run)
+	}
+
+	run = Options{Favorite: "web", ConfigPath: opts.ConfigPath, Profile: "dev", FlagSet: map[string]bool{"profile": true}}
+	if err := applyFavorite(&run); err != nil {
+		t.Fatalf("applyFavorite: %v", err)
+	}
+	if run.Profile != "dev" {
+		t.Fatalf("expected --profile to win, got %q", run.Profile)
+	}
+
+	run = Options{Favorite: "missing", ConfigPath: opts.ConfigPath}
+	if err := applyFavorite(&run); err == nil {
+		t.Fatal("expected unknown favorite error")
+	}
+}
+
+func TestFavoritePinsRankScannedInstancesAndTagMatches(t *testing.T) {
+	target := roleTarget{AccountID: "111111111111", AccountName: "acct", RoleName: "Admin"}
+	favs := favoritesFile{Favorites: map[string]favoriteTarget{
+		"bastion": {Profile: "dev", Scope: recentScope{AccountID: target.AccountID, RoleName: "Admin", Region: "us-east-1"}, Tag: "Role=bastion"},
+		"db":      {Profile: "dev", Scope: recentScope{AccountID: target.AccountID, RoleName: "Admin"}, Instance: &recentInstance{InstanceID: "i-db", Region: "us-east-1", DisplayLine: "db | i-db"}},
+		"gone":    {Profile: "dev", Scope: recentScope{AccountID: target.AccountID, RoleName: "Admin"}, Instance: &recentInstance{InstanceID: "i-gone", Region: "us-east-1"}},
+		"other":   {Profile: "dev", Scope: recentScope{AccountID: "222222222222", RoleName: "Admin"}, Instance: &recentInstance{InstanceID: "i-x", Region: "us-east-1"}},
+	}}
+	opts := Options{pins: favoritePinsFor(favs, "dev", []roleTarget{target}, []string{"us-east-1"})}
+	if len(opts.pins) != 3 {
+		t.Fatalf("expected the in-scope favorites to be pinned, got %+v", opts.pins)
+	}
+
+	scanned := func(id, display string, tags map[string]string) instanceCandidate {
+		return instanceCandidate{DisplayLine: display, InstanceID: id, Region: "us-east-1", AccountID: target.AccountID, RoleName: "Admin", Tags: tags}
+	}
+	got := opts.rankInstances([]instanceCandidate{
+		scanned("i-app", "app | i-app", nil),
+		scanned("i-db", "db | i-db", nil),
+		scanned("i-b1", "b1 | i-b1", map[string]string{"Role": "bastion"}),
+	})
+	var ids []string
+	for _, c := range got {
+		ids = append(ids, c.InstanceID)
+	}
+	if strings.Join(ids, ",") != "i-db,i-b1,i-app" {
+		t.Fatalf("expected scanned favorites first and no saved-only instances, got %v", ids)
+	}
+	if got[0].DisplayLine != "db | i-db" {
+		t.Fatalf("expected the display line to stay plain, got %q", got[0].DisplayLine)
+	}
+	if line := opts.instanceLine(got[0]); !strings.HasPrefix(line, "★ @db | db | i-db\t") {
+		t.Fatalf("expected the alias to be added when the line is rendered, got %q", line)
+	}
+	if line := opts.instanceLine(got[1]); !strings.HasPrefix(line, "★ @bastion | b1 | i-b1\t") {
+		t.Fatalf("expected the tag favorite to label matching instances, got %q", line)
+	}
+}
+
+func TestFavoritePinsWithoutRegionMatchAnyRegion(t *testing.T) {
+	target := roleTarget{AccountID: "111111111111", AccountName: "acct", RoleName: "Admin"}
+	favs := favoritesFile{Favorites: map[string]favoriteTarget{
+		"bastion": {Profile: "dev", Scope: recentScope{AccountID: target.AccountID, RoleName: "Admin"}, Tag: "Role=bastion"},
+	}}
+	opts := Options{pins: favoritePinsFor(favs, "dev", []roleTarget{target}, []string{"eu-west-1", "us-east-1"})}
+	if len(opts.pins) != 1 {
+		t.Fatalf("expected the region-less tag favorite to be pinned, got %+v", opts.pins)
+	}
+	for _, region := range []string{"eu-west-1", "us-east-1"} {
+		c := instanceCandidate{InstanceID: "i-" + region, Region: region, AccountID: target.AccountID, RoleName: "Admin", Tags: map[string]string{"Role": "bastion"}}
+		if opts.pinnedAlias(c) != "bastion" {
+			t.Fatalf("expected the favorite to match in %s", region)
+		}
+	}
+}
+
+func TestConnectFavoriteByTagConnectsToSingleMatch(t *testing.T) {
+	installRunTestSeams(t)
+
+	fav := favoriteTarget{Profile: "dev", Scope: recentScope{AccountID: "111111111111", RoleName: "Admin", Region: "us-east-1"}, Tag: "Role=bastion"}
+	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
+		return "/tmp/mock-config.ini", map[string]string{targetKey(targets[0]): "swamp-1"}, nil
+	}
+	scanAllInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) ([]instanceCandidate, error) {
+		return []instanceCandidate{
+			{InstanceID: "i-web", Region: "us-east-1", ProfileName: "swamp-1", Tags: map[string]string{"Role": "web"}},
+			{InstanceID: "i-bastion", Region: "us-east-1", ProfileName: "swamp-1", Tags: map[string]string{"Role": "bastion"}},
+		}, nil
+	}
+	var started string
+	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID, document string) error {
+		started = instanceID
+		return nil
+	}
+	removeFileFn = func(path string) error { return nil }
+
+	ok, err := connectFavorite(context.Background(), Options{Profile: "dev", Workers: 1, CacheDir: t.TempDir()}, profileConfig{}, fav, "us-east-1")
+	if err != nil || !ok {
+		t.Fatalf("expected favorite connection, got ok=%v err=%v", ok, err)
+	}
+	if started != "i-bastion" {
+		t.Fatalf("expected session to i-bastion, got %q", started)
+	}
+}
@@ -0,0 +1,390 @@
+package app
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// filterFields are the fields a --filter term can test. account matches the
+// account ID or name; tag:KEY tests an instance tag.
+var filterFields = []string{"account", "role", "region", "id", "name", "state", "type", "platform", "ip", "tag:KEY"}
+
+// filterExpr is a parsed --filter expression: space-separated terms that
+// must all match. Each picker level only checks the terms it knows about,
+// so `role=Admin` narrows roles but not accounts.
+type filterExpr struct {
+	terms []filterTerm
+}
+
+type filterTerm struct {
+	field  string
+	tag    string
+	op     string // "=", "!=", "~" or "!~"; "in" and "not in" parse to "=" and "!="
+	values []string
+	re     *regexp.Regexp
+}
+
+func parseFilter(input string) (filterExpr, error) {
+	p := filterParser{in: []rune(input)}
+	var expr filterExpr
+	for {
+		p.skipSpace()
+		if p.done() {
+			return expr, nil
+		}
+		term, err := p.term()
+		if err != nil {
+			return filterExpr{}, fmt.Errorf("invalid filter %q: %w", input, err)
+		}
+		expr.terms = append(expr.terms, term)
+	}
+}
+
+type filterParser struct {
+	in  []rune
+	pos int
+}
+
+func (p *filterParser) done() bool { return p.pos >= len(p.in) }
+
+func (p *filterParser) peek() rune {
+	if p.done() {
+		return 0
+	}
+	return p.in[p.pos]
+}
+
+func (p *filterParser) skipSpace() {
+	for !p.done() && unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+}
+
+func (p *filterParser) term() (filterTerm, error) {
+	start := p.pos
+	for !p.done() && !unicode.IsSpace(p.peek()) && !strings.ContainsRune("=!~", p.peek()) {
+		p.pos++
+	}
+	var t filterTerm
+	field := string(p.in[start:p.pos])
+	switch {
+	case strings.HasPrefix(strings.ToLower(field), "tag:") && len(field) > len("tag:"):
+		t.field, t.tag = "tag", field[len("tag:"):]
+	case containsString(filterFields, strings.ToLower(field)):
+		t.field = strings.ToLower(field)
+	case field == "":
+		return t, fmt.Errorf("expected a field at position %d", start+1)
+	default:
+		return t, fmt.Errorf("unknown field %q (fields: %s)", field, strings.Join(filterFields, ", "))
+	}
+
+	p.skipSpace()
+	switch {
+	case p.consume("!="):
+		t.op = "!="
+	case p.consume("!~"):
+		t.op = "!~"
+	case p.consume("=="), p.consume("="):
+		t.op = "="
+	case p.consume("~"):
+		t.op = "~"
+	case p.consumeWord("in"):
+		t.op = "="
+	case p.consumeWord("not"):
+		p.skipSpace()
+		if !p.consumeWord("in") {
+			return t, fmt.Errorf("expected \"in\" after \"not\" for %s", field)
+		}
+		t.op = "!="
+	default:
+		return t, fmt.Errorf("expected =, !=, ~, !~, in or not in after %s", field)
+	}
+	p.skipSpace()
+
+	values, err := p.values(t.op == "~" || t.op == "!~")
+	if err != nil {
+		return t, fmt.Errorf("%s: %w", field, err)
+	}
+	if len(values) == 0 {
+		return t, fmt.Errorf("missing value for %s", field)
+	}
+	t.values = values
+	if t.op == "~" || t.op == "!~" {
+		if len(values) != 1 {
+			return t, fmt.Errorf("%s: a regex takes a single value", field)
+		}
+		if t.re, err = regexp.Compile(values[0]); err != nil {
+			return t, fmt.Errorf("%s: %w", field, err)
+		}
+	}
+	for _, v := range values {
+		if _, err := path.Match(strings.ToLower(v), ""); t.re == nil && err != nil {
+			return t, fmt.Errorf("%s: %q is not a valid glob", field, v)
+		}
+	}
+	return t, nil
+}
+
+func (p *filterParser) consume(s string) bool {
+	if strings.HasPrefix(string(p.in[p.pos:]), s) {
+		p.pos += len([]rune(s))
+		return true
+	}
+	return false
+}
+
+// consumeWord matches a keyword followed by a space or "(".
+func (p *filterParser) consumeWord(word string) bool {
+	rest := string(p.in[p.pos:])
+	if !strings.HasPrefix(strings.ToLower(rest), word) {
+		return false
+	}
+	after := []rune(rest)[len(word):]
+	if len(after) > 0 && !unicode.IsSpace(after[0]) && after[0] != '(' {
+		return false
+	}
+	p.pos += len(word)
+	return true
+}
+
+// values reads "(a, b)", a quoted string or a bare word. Bare words split
+// on commas unless single is set (regexes may contain commas).
+func (p *filterParser) values(single bool) ([]string, error) {
+	if p.peek() == '(' {
+		p.pos++
+		var out []string
+		for {
+			p.skipSpace()
+			if p.peek() == ')' {
+				p.pos++
+				return out, nil
+			}
+			if p.done() {
+				return nil, fmt.Errorf("missing )")
+			}
+			var v string
+			if p.peek() == '"' {
+				s, err := p.quoted()
+				if err != nil {
+					return nil, err
+				}
+				v = s
+			} else {
+				start := p.pos
+				for !p.done() && !strings.ContainsRune(",)", p.peek()) {
+					p.pos++
+				}
+				v = strings.TrimSpace(string(p.in[start:p.pos]))
+			}
+			if v != "" {
+				out = append(out, v)
+			}
+			p.skipSpace()
+			if p.peek() == ',' {
+				p.pos++
+			}
+		}
+	}
+	if p.peek() == '"' {
+		s, err := p.quoted()
+		if err != nil {
+			return nil, err
+		}
+		return []string{s}, nil
+	}
+	start := p.pos
+	for !p.done() && !unicode.IsSpace(p.peek()) {
+		p.pos++
+	}
+	word := string(p.in[start:p.pos])
+	if single {
+		return []string{word}, nil
+	}
+	return splitCSV(word), nil
+}
+
+func (p *filterParser) quoted() (string, error) {
+	p.pos++
+	var b strings.Builder
+	for !p.done() {
+		r := p.peek()
+		p.pos++
+		switch {
+		case r == '\\' && !p.done() && (p.peek() == '"' || p.peek() == '\\'):
+			b.WriteRune(p.peek())
+			p.pos++
+		case r == '"':
+			return b.String(), nil
+		default:
+			b.WriteRune(r)
+		}
+	}
+	return "", fmt.Errorf("unterminated quote")
+}
+
+// match tests one term against a field's values; account passes both the
+// ID and the name and matches when either does. Globs are case-insensitive,
+// regexes are not unless they start with (?i).
+func (t filterTerm) match(values ...string) bool {
+	matched := false
+	for _, v := range values {
+		if t.re != nil {
+			matched = matched || t.re.MatchString(v)
+			continue
+		}
+		for _, want := range t.values {
+			if ok, _ := path.Match(strings.ToLower(want), strings.ToLower(v)); ok {
+				matched = true
+			}
+		}
+	}
+	if t.op == "!=" || t.op == "!~" {
+		return !matched
+	}
+	return matched
+}
+
+func (f filterExpr) empty() bool { return len(f.terms) == 0 }
+
+// check runs the terms lookup knows a value for; the rest pass.
+func (f filterExpr) check(lookup func(t filterTerm) ([]string, bool)) bool {
+	for _, t := range f.terms {
+		values, ok := lookup(t)
+		if ok && !t.match(values...) {
+			return false
+		}
+	}
+	return true
+}
+
+func (f filterExpr) matchAccount(accountID, accountName string) bool {
+	return f.check(func(t filterTerm) ([]string, bool) {
+		if t.field == "account" {
+			return []string{accountID, accountName}, true
+		}
+		return nil, false
+	})
+}
+
+func (f filterExpr) matchTarget(target roleTarget) bool {
+	return f.check(func(t filterTerm) ([]string, bool) {
+		switch t.field {
+		case "account":
+			return []string{target.AccountID, target.AccountName}, true
+		case "role":
+			return []string{target.RoleName}, true
+		}
+		return nil, false
+	})
+}
+
+func (f filterExpr) matchRegion(region string) bool {
+	return f.check(func(t filterTerm) ([]string, bool) {
+		if t.field == "region" {
+			return []string{region}, true
+		}
+		return nil, false
+	})
+}
+
+func (f filterExpr) matchInstance(c instanceCandidate) bool {
+	return f.check(func(t filterTerm) ([]string, bool) {
+		switch t.field {
+		case "account":
+			return []string{c.AccountID, c.AccountName}, true
+		case "role":
+			return []string{c.RoleName}, true
+		case "region":
+			return []string{c.Region}, true
+		case "id":
+			return []string{c.InstanceID}, true
+		case "name":
+			return []string{c.Name}, true
+		case "state":
+			return []string{c.State}, true
+		case "type":
+			return []string{c.InstanceType}, true
+		case "platform":
+			return []string{c.Platform}, true
+		case "ip":
+			return []string{c.PrivateIP}, true
+		case "tag":
+			return []string{c.Tags[t.tag]}, true
+		}
+		return nil, false
+	})
+}
+
+// wantsStopped reports whether a state term could match anything but
+// running instances, in which case the scan has to include stopped ones.
+func (f filterExpr) wantsStopped() bool {
+	for _, t := range f.terms {
+		if t.field != "state" {
+			continue
+		}
+		if t.op != "=" {
+			return true
+		}
+		for _, v := range t.values {
+			if !strings.EqualFold(v, "running") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (f filterExpr) filterAccounts(accounts []ssoAccountsResponse) []ssoAccountsResponse {
+	if f.empty() {
+		return accounts
+	}
+	var out []ssoAccountsResponse
+	for _, a := range accounts {
+		if len(a.AccountList) > 0 && f.matchAccount(a.AccountList[0].AccountID, a.AccountList[0].AccountName) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func (f filterExpr) filterTargets(targets []roleTarget) []roleTarget {
+	if f.empty() {
+		return targets
+	}
+	var out []roleTarget
+	for _, t := range targets {
+		if f.matchTarget(t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (f filterExpr) filterRegions(regions []string) []string {
+	if f.empty() {
+		return regions
+	}
+	var out []string
+	for _, r := range regions {
+		if f.matchRegion(r) {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func (f filterExpr) filterInstances(cands []instanceCandidate) []instanceCandidate {
+	if f.empty() {
+		return cands
+	}
+	var out []instanceCandidate
+	for _, c := range cands {
+		if f.matchInstance(c) {
+			out = append(out, c)
+		}
+	}
+	return out
+}
@@ -0,0 +1,110 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParseFilterExample(t *testing.T) {
+	f, err := parseFilter(`account~"^prod-" role in (Admin, PowerUser) region!=us-east-1 tag:Team=payments state=running,stopped`)
+	if err != nil {
+		t.Fatalf("parseFilter: %v", err)
+	}
+	if len(f.terms) != 5 {
+		t.Fatalf("expected 5 terms, got %+v", f.terms)
+	}
+	role := f.terms[1]
+	if role.field != "role" || role.op != "=" || strings.Join(role.values, ",") != "Admin,PowerUser" {
+		t.Fatalf("unexpected role term %+v", role)
+	}
+	if tag := f.terms[3]; tag.field != "tag" || tag.tag != "Team" {
+		t.Fatalf("unexpected tag term %+v", tag)
+	}
+	if !f.wantsStopped() {
+		t.Fatal("expected state=running,stopped to need stopped instances")
+	}
+
+	base := instanceCandidate{AccountID: "1", AccountName: "prod-payments", RoleName: "PowerUser", Region: "eu-west-1", State: "stopped", Tags: map[string]string{"Team": "Payments"}}
+	if !f.matchInstance(base) {
+		t.Fatalf("expected %+v to match", base)
+	}
+	for name, mutate := range map[string]func(*instanceCandidate){
+		"account": func(c *instanceCandidate) { c.AccountName = "staging-prod-payments" },
+		"role":    func(c *instanceCandidate) { c.RoleName = "ReadOnly" },
+		"region":  func(c *instanceCandidate) { c.Region = "us-east-1" },
+		"tag":     func(c *instanceCandidate) { delete(c.Tags, "Team") },
+		"state":   func(c *instanceCandidate) { c.State = "terminated" },
+	} {
+		c := base
+		c.Tags = map[string]string{"Team": "Payments"}
+		mutate(&c)
+		if f.matchInstance(c) {
+			t.Fatalf("expected a different %s to fail the filter, got a match for %+v", name, c)
+		}
+	}
+}
+
+func TestFilterLevelsOnlyCheckTheirFields(t *testing.T) {
+	f, err := parseFilter(`account not in ("*-sandbox", 123456789012) role=Admin*`)
+	if err != nil {
+		t.Fatalf("parseFilter: %v", err)
+	}
+	if f.matchAccount("1", "team-Sandbox") || f.matchAccount("123456789012", "shared") {
+		t.Fatal("expected sandbox accounts and the excluded ID to be filtered out")
+	}
+	if !f.matchAccount("2", "prod") {
+		t.Fatal("expected role terms to be ignored at the account level")
+	}
+	if f.matchTarget(roleTarget{AccountID: "2", AccountName: "prod", RoleName: "ReadOnly"}) {
+		t.Fatal("expected the role glob to apply at the role level")
+	}
+	if !f.matchTarget(roleTarget{AccountID: "2", AccountName: "prod", RoleName: "AdministratorAccess"}) {
+		t.Fatal("expected AdministratorAccess to match role=Admin*")
+	}
+	if !f.matchRegion("us-east-1") {
+		t.Fatal("expected regions to pass without region terms")
+	}
+}
+
+func TestParseFilterErrors(t *testing.T) {
+	for input, want := range map[string]string{
+		"owner=me":          "unknown field",
+		"role":              "expected =, !=",
+		"role=":             "missing value",
+		`name~"(unclosed"`:  "missing closing",
+		`account="prod`:     "unterminated quote",
+		"role in (a, b":     "missing )",
+		"role not (a)":      `expected "in"`,
+		"=prod":             "expected a field",
+		"account=prod[":     "not a valid glob",
+		"state = running  ": "",
+	} {
+		_, err := parseFilter(input)
+		if want == "" {
+			if err != nil {
+				t.Fatalf("%q: unexpected error %v", input, err)
+			}
+			continue
+		}
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("%q: expected error containing %q, got %v", input, want, err)
+		}
+	}
+}
+
+func TestMergeOptionsFilterIncludesStoppedForStateTerms(t *testing.T) {
+	cfg := UserConfig{}
+	cfg.Discovery.Filter = "state=stopped"
+	opts, err := mergeOptions(Options{Workers: 1}, cfg)
+	if err != nil {
+		t.Fatalf("mergeOptions: %v", err)
+	}
+	if !opts.IncludeStopped || sourceOf(opts, "include-stopped") != "filter" {
+		t.Fatalf("expected stopped instances to be scanned, got %v (source=%s)", opts.IncludeStopped, sourceOf(opts, "include-stopped"))
+	}
+
+	cfg.Discovery.Filter = "bogus=1"
+	if _, err := mergeOptions(Options{Workers: 1}, cfg); err == nil || !strings.Contains(err.Error(), "source=config") {
+		t.Fatalf("expected a filter error naming its source, got %v", err)
+	}
+}
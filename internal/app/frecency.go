@@ -0,0 +1,170 @@
+package app
+
+import (
+	"math"
+	"sort"
+	"time"
+)
+
+// frecencyMarker prefixes picker lines for items the user connects to often.
+const frecencyMarker = "● "
+
+type usageStat struct {
+	Count      int    `json:"count"`
+	LastUsedAt string `json:"last_used_at"`
+}
+
+func usageKeyAccount(accountID string) string {
+	return "account:" + accountID
+}
+
+func usageKeyRole(accountID, roleName string) string {
+	return "role:" + accountID + ":" + roleName
+}
+
+func usageKeyRegion(region string) string {
+	return "region:" + region
+}
+
+func usageKeyInstance(instanceID string) string {
+	return "instance:" + instanceID
+}
+
+func candidateUsageKey(c instanceCandidate) string {
+	return usageKeyInstance(c.InstanceID)
+}
+
+func recordUsage(usage map[string]usageStat, scope recentScope, inst recentInstance, now string) map[string]usageStat {
+	if usage == nil {
+		usage = map[string]usageStat{}
+	}
+	region := inst.Region
+	if region == "" {
+		region = scope.Region
+	}
+	for _, key := range []string{
+		usageKeyAccount(scope.AccountID),
+		usageKeyRole(scope.AccountID, scope.RoleName),
+		usageKeyRegion(region),
+		usageKeyInstance(inst.InstanceID),
+	} {
+		stat := usage[key]
+		stat.Count++
+		stat.LastUsedAt = now
+		usage[key] = stat
+	}
+	return pruneUsage(usage, recentUsageLimit)
+}
+
+// pruneUsage keeps the limit most recently used keys, so accounts and
+// instances that are long gone do not pile up.
+func pruneUsage(usage map[string]usageStat, limit int) map[string]usageStat {
+	if len(usage) <= limit {
+		return usage
+	}
+	keys := make([]string, 0, len(usage))
+	for key := range usage {
+		keys = append(keys, key)
+	}
+	// RFC3339 UTC timestamps sort in time order.
+	sort.Slice(keys, func(i, j int) bool {
+		a, b := usage[keys[i]], usage[keys[j]]
+		if a.LastUsedAt != b.LastUsedAt {
+			return a.LastUsedAt > b.LastUsedAt
+		}
+		if a.Count != b.Count {
+			return a.Count > b.Count
+		}
+		return keys[i] < keys[j]
+	})
+	for _, key := range keys[limit:] {
+		delete(usage, key)
+	}
+	return usage
+}
+
+// frecencyScore weights use counts by how recently the item was last used,
+// so a box used daily last month drops below one used a few times this week.
+func frecencyScore(stat usageStat, now time.Time) float64 {
+	last, err := time.Parse(time.RFC3339, stat.LastUsedAt)
+	if err != nil {
+		return float64(stat.Count)
+	}
+	age := now.Sub(last)
+	weight := 10.0
+	switch {
+	case age <= 4*24*time.Hour:
+		weight = 100
+	case age <= 14*24*time.Hour:
+		weight = 70
+	case age <= 31*24*time.Hour:
+		weight = 50
+	case age <= 90*24*time.Hour:
+		weight = 30
+	}
+	return float64(stat.Count) * weight
+}
+
+func (f recentTargetsFile) frecency(profile string, now time.Time) map[string]float64 {
+	scores := map[string]float64{}
+	for key, stat := range f.Profiles[profile].Usage {
+		scores[key] = frecencyScore(stat, now)
+	}
+	return scores
+}
+
+// rankByFrecency moves used items to the front, highest score first, and
+// keeps the existing order for everything else. usage holds the frecency
+// scores of the active profile, keyed like recentProfileData.Usage.
+func rankByFrecency[T any](usage map[string]float64, items []T, key func(T) string) []T {
+	out := append([]T(nil), items...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return usage[key(out[i])] > usage[key(out[j])]
+	})
+	return out
+}
+
+// rankedCandidates keeps the instances a picker shows in frecency order
+// while scan results stream in. Pinned favorites stay on top.
+type rankedCandidates struct {
+	opts  Options
+	items []instanceCandidate
+}
+
+// add appends batch and reports whether it had to reorder the list, i.e. a
+// used instance arrived after instances it now ranks ahead of.
+func (r *rankedCandidates) add(batch []instanceCandidate) bool {
+	start := len(r.items)
+	r.items = append(r.items, batch...)
+	for i := max(start, 1); i < len(r.items); i++ {
+		if r.opts.instanceRank(r.items[i]) > r.opts.instanceRank(r.items[i-1]) {
+			r.items = r.opts.rankInstances(r.items)
+			return true
+		}
+	}
+	return false
+}
+
+// rankInstances orders instances like rankByFrecency, with pinned
+// favorites ahead of everything else.
+func (o Options) rankInstances(cands []instanceCandidate) []instanceCandidate {
+	out := append([]instanceCandidate(nil), cands...)
+	sort.SliceStable(out, func(i, j int) bool {
+		return o.instanceRank(out[i]) > o.instanceRank(out[j])
+	})
+	return out
+}
+
+func (o Options) instanceRank(c instanceCandidate) float64 {
+	if o.pinnedAlias(c) != "" {
+		return math.Inf(1)
+	}
+	return o.usage[candidateUsageKey(c)]
+}
+
+func (o Options) frecencyLabel(key, display string) string {
+	if o.usage[key] > 0 {
+		return frecencyMarker + display
+	}
+	return display
+}
@@ -0,0 +1,124 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFrecencyScorePrefersRecentUse(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	recent := usageStat{Count: 3, LastUsedAt: now.Add(-24 * time.Hour).Format(time.RFC3339)}
+	stale := usageStat{Count: 20, LastUsedAt: now.Add(-200 * 24 * time.Hour).Format(time.RFC3339)}
+	if frecencyScore(recent, now) <= frecencyScore(stale, now) {
+		t.Fatalf("expected recent use (%v) to outrank stale use (%v)", frecencyScore(recent, now), frecencyScore(stale, now))
+	}
+}
+
+func TestRankByFrecencyKeepsOrderForUnusedItems(t *testing.T) {
+	usage := map[string]float64{"region:eu-west-1": 100, "region:us-west-2": 300}
+
+	got := rankByFrecency(usage, []string{"ap-south-1", "eu-west-1", "us-east-1", "us-west-2"}, usageKeyRegion)
+	if strings.Join(got, ",") != "us-west-2,eu-west-1,ap-south-1,us-east-1" {
+		t.Fatalf("unexpected order %v", got)
+	}
+}
+
+func TestStreamedInstancesAreRankedAfterThePickerOpens(t *testing.T) {
+	opts := Options{usage: map[string]float64{"instance:i-late": 300, "instance:i-later": 100}}
+
+	updates := make(chan scanResult, 4)
+	updates <- scanResult{Candidates: []instanceCandidate{{InstanceID: "i-a", DisplayLine: "a"}}}
+	updates <- scanResult{Candidates: []instanceCandidate{{InstanceID: "i-b", DisplayLine: "b"}}}
+	updates <- scanResult{Candidates: []instanceCandidate{{InstanceID: "i-c", DisplayLine: "c"}, {InstanceID: "i-later", DisplayLine: "later"}}}
+	updates <- scanResult{Candidates: []instanceCandidate{{InstanceID: "i-late", DisplayLine: "late"}, {InstanceID: "i-d", DisplayLine: "d"}}}
+	close(updates)
+
+	initial, _, complete := bufferScanResults(updates, 2)
+	if complete || len(initial) != 2 {
+		t.Fatalf("expected the picker to open after 2 candidates, got %d (complete=%v)", len(initial), complete)
+	}
+	ranked := rankedCandidates{opts: opts, items: rankByFrecency(opts.usage, initial, candidateUsageKey)}
+	var reordered []bool
+	for r := range updates {
+		reordered = append(reordered, ranked.add(r.Candidates))
+	}
+	var ids []string
+	for _, c := range ranked.items {
+		ids = append(ids, c.InstanceID)
+	}
+	if strings.Join(ids, ",") != "i-late,i-later,i-a,i-b,i-c,i-d" {
+		t.Fatalf("unexpected order %v", ids)
+	}
+	if !reordered[0] || !reordered[1] {
+		t.Fatalf("expected both late batches to reorder the list, got %v", reordered)
+	}
+	if ranked.add([]instanceCandidate{{InstanceID: "i-e"}}) {
+		t.Fatal("an unused instance should be appended without reordering")
+	}
+}
+
+func TestSaveRecentTargetsRecordsUsage(t *testing.T) {
+	dir := t.TempDir()
+	scope := recentScope{AccountID: "123456789012", RoleName: "Admin", Region: "eu-west-1"}
+	inst := recentInstance{InstanceID: "i-1", Region: "eu-west-1"}
+	for i := 0; i < 2; i++ {
+		if err := saveRecentTargets(dir, "p", scope, inst); err != nil {
+			t.Fatalf("saveRecentTargets: %v", err)
+		}
+	}
+	got, err := loadRecentTargets(dir)
+	if err != nil {
+		t.Fatalf("loadRecentTargets: %v", err)
+	}
+	usage := got.Profiles["p"].Usage
+	for _, key := range []string{usageKeyAccount("123456789012"), usageKeyRole("123456789012", "Admin"), usageKeyRegion("eu-west-1"), usageKeyInstance("i-1")} {
+		if usage[key].Count != 2 {
+			t.Fatalf("expected %s to be counted twice, got %+v", key, usage[key])
+		}
+	}
+	if scores := got.frecency("p", time.Now()); scores[usageKeyInstance("i-1")] <= 0 {
+		t.Fatalf("expected positive score, got %v", scores)
+	}
+}
+
+func TestRecordUsageDropsLeastRecentlyUsedKeys(t *testing.T) {
+	usage := map[string]usageStat{}
+	for i := 0; i < recentUsageLimit; i++ {
+		usage[usageKeyInstance(fmt.Sprintf("i-old-%d", i))] = usageStat{Count: 5, LastUsedAt: "2026-01-01T00:00:00Z"}
+	}
+	usage[usageKeyInstance("i-older")] = usageStat{Count: 50, LastUsedAt: "2025-01-01T00:00:00Z"}
+
+	usage = recordUsage(usage, recentScope{AccountID: "1", RoleName: "Admin"}, recentInstance{InstanceID: "i-new", Region: "eu-west-1"}, "2026-10-01T00:00:00Z")
+	if len(usage) != recentUsageLimit {
+		t.Fatalf("expected usage to be capped at %d keys, got %d", recentUsageLimit, len(usage))
+	}
+	if _, ok := usage[usageKeyInstance("i-new")]; !ok {
+		t.Fatal("expected the new connection to be kept")
+	}
+	if _, ok := usage[usageKeyInstance("i-older")]; ok {
+		t.Fatal("expected the least recently used key to be dropped")
+	}
+}
+
+func TestSelectAccountOrdersAndMarksFrequentAccounts(t *testing.T) {
+	orig := pickLineFn
+	defer func() { pickLineFn = orig }()
+
+	var shown []string
+	pickLineFn = func(_ Options, lines []string, prompt string, preview bool) (string, bool, error) {
+		shown = lines
+		return lines[0], true, nil
+	}
+	chosen, err := selectAccountWithFZF(Options{usage: map[string]float64{usageKeyAccount("222222222222"): 50}}, []ssoAccountsResponse{testAccount("111111111111", "dev"), testAccount("222222222222", "prod")})
+	if err != nil {
+		t.Fatalf("selectAccountWithFZF: %v", err)
+	}
+	if !strings.HasPrefix(shown[0], frecencyMarker+"prod") || strings.HasPrefix(shown[1], frecencyMarker) {
+		t.Fatalf("expected prod first and marked, got %q", shown)
+	}
+	if chosen.AccountList[0].AccountID != "222222222222" {
+		t.Fatalf("expected prod to be selected, got %+v", chosen)
+	}
+}
@@ -2,11 +2,22 @@ package app
 
 import (
 	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
+	"net"
+	"net/http"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 const fzfBackOption = "< Back"
@@ -18,102 +29,151 @@ func withBackOption(lines []string) []string {
 	return append([]string{fzfBackOption}, lines...)
 }
 
-func selectAccountWithFZF(accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
+// pickLine, pickLines and pickInstance show the picker Run resolved into
+// opts.Picker. The package-level seams hold the fzf pickers so tests can
+// replace them.
+func (o Options) pickLine(lines []string, prompt string, preview bool) (string, bool, error) {
+	if o.Picker == "builtin" {
+		return pickLineBuiltin(lines, prompt, preview)
+	}
+	return pickLineFn(o, lines, prompt, preview)
+}
+
+func (o Options) pickLines(lines []string, prompt string, preview bool) ([]string, bool, error) {
+	if o.Picker == "builtin" {
+		return pickLinesBuiltin(lines, prompt, preview)
+	}
+	return pickLinesFn(o, lines, prompt, preview)
+}
+
+func (o Options) pickInstance(initial []instanceCandidate, progress scanResult, updates <-chan scanResult, reload *reloadSpec) (*instanceCandidate, pickerAction, error) {
+	if o.Picker == "builtin" {
+		return pickWithBuiltin(o, initial, progress, updates, reload)
+	}
+	return pickInstanceFn(o, initial, progress, updates, reload)
+}
+
+func selectAccountWithFZF(opts Options, accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
 	lookup := make(map[string]ssoAccountsResponse, len(accounts))
 	lines := make([]string, 0, len(accounts))
+	accounts = rankByFrecency(opts.usage, accounts, func(a ssoAccountsResponse) string {
+		if len(a.AccountList) == 0 {
+			return ""
+		}
+		return usageKeyAccount(a.AccountList[0].AccountID)
+	})
 	for _, a := range accounts {
 		if len(a.AccountList) == 0 {
 			continue
 		}
 		acct := a.AccountList[0]
-		line := fmt.Sprintf("%s | %s | %s", acct.AccountName, acct.AccountID, acct.EmailAddress)
+		display := opts.frecencyLabel(usageKeyAccount(acct.AccountID), opts.environmentLabel(acct.AccountID, acct.AccountName)+fmt.Sprintf("%s | %s | %s", acct.AccountName, acct.AccountID, acct.EmailAddress))
+		line := fzfKeyedLine(display, previewKeyAccount(acct.AccountID))
 		lines = append(lines, line)
-		lookup[line] = a
+		lookup[stripANSI(line)] = a
 	}
 	if len(lines) == 0 {
 		return nil, nil
 	}
-	selected, ok, err := pickLineWithFZF(lines, "Select account > ")
+	selected, ok, err := opts.pickLine(lines, "Select account > ", true)
 	if err != nil {
 		return nil, err
 	}
 	if !ok {
 		return nil, nil
 	}
-	chosen, found := lookup[selected]
+	chosen, found := lookup[stripANSI(selected)]
 	if !found {
 		return nil, fmt.Errorf("selected account not found")
 	}
 	return &chosen, nil
 }
 
-func selectRoleTargetWithFZF(targets []roleTarget) (*roleTarget, bool, error) {
+// selectRoleTargetsWithFZF lets the user mark several roles with TAB; the
+// instance scan then covers all of them.
+func selectRoleTargetsWithFZF(opts Options, targets []roleTarget) ([]roleTarget, bool, error) {
 	lookup := make(map[string]roleTarget, len(targets))
 	lines := make([]string, 0, len(targets))
-	for _, t := range targets {
-		line := fmt.Sprintf("%s | %s | %s", t.AccountName, t.AccountID, t.RoleName)
+	roleKey := func(t roleTarget) string { return usageKeyRole(t.AccountID, t.RoleName) }
+	for _, t := range rankByFrecency(opts.usage, targets, roleKey) {
+		display := opts.frecencyLabel(roleKey(t), opts.environmentLabel(t.AccountID, t.AccountName)+fmt.Sprintf("%s | %s | %s", t.AccountName, t.AccountID, t.RoleName))
+		line := fzfKeyedLine(display, previewKeyRole(t))
 		lines = append(lines, line)
-		lookup[line] = t
+		lookup[stripANSI(line)] = t
 	}
 	if len(lines) == 0 {
 		return nil, false, nil
 	}
 	lines = withBackOption(lines)
-	selected, ok, err := pickLineWithFZF(lines, "Select role > ")
+	selected, ok, err := opts.pickLines(lines, "Select role > ", true)
 	if err != nil {
 		return nil, false, err
 	}
 	if !ok {
 		return nil, false, nil
 	}
-	if selected == fzfBackOption {
+	if containsString(selected, fzfBackOption) {
 		return nil, true, nil
 	}
-	chosen, found := lookup[selected]
-	if !found {
-		return nil, false, fmt.Errorf("selected role not found")
+	var chosen []roleTarget
+	for _, line := range selected {
+		t, found := lookup[stripANSI(line)]
+		if !found {
+			return nil, false, fmt.Errorf("selected role not found")
+		}
+		chosen = append(chosen, t)
 	}
-	return &chosen, false, nil
+	return chosen, false, nil
 }
 
-func selectRegionWithFZF(regions []string) (string, bool, error) {
+// selectRegionsWithFZF lets the user mark several regions with TAB.
+func selectRegionsWithFZF(opts Options, regions []string) ([]string, bool, error) {
 	lookup := make(map[string]string, len(regions))
 	lines := make([]string, 0, len(regions))
-	for _, region := range regions {
-		line := fmt.Sprintf("%s | %s", region, regionDisplayName(region))
+	for _, region := range rankByFrecency(opts.usage, regions, usageKeyRegion) {
+		line := opts.frecencyLabel(usageKeyRegion(region), fmt.Sprintf("%s | %s", region, regionDisplayName(region)))
 		lines = append(lines, line)
 		lookup[line] = region
 	}
 	if len(lines) == 0 {
-		return "", false, nil
+		return nil, false, nil
 	}
 	lines = withBackOption(lines)
 
-	selected, ok, err := pickLineWithFZF(lines, "Select region > ")
+	selected, ok, err := opts.pickLines(lines, "Select region > ", false)
 	if err != nil {
-		return "", false, err
+		return nil, false, err
 	}
 	if !ok {
-		return "", false, nil
+		return nil, false, nil
 	}
-	if selected == fzfBackOption {
-		return "", true, nil
+	if containsString(selected, fzfBackOption) {
+		return nil, true, nil
 	}
-	region, found := lookup[selected]
-	if !found {
-		return "", false, fmt.Errorf("selected region not found")
+	var chosen []string
+	for _, line := range selected {
+		region, found := lookup[line]
+		if !found {
+			return nil, false, fmt.Errorf("selected region not found")
+		}
+		chosen = append(chosen, region)
 	}
-	return region, false, nil
+	return chosen, false, nil
 }
 
-func pickLineWithFZF(lines []string, prompt string) (string, bool, error) {
+func pickLineWithFZF(opts Options, lines []string, prompt string, preview bool) (string, bool, error) {
 	var in bytes.Buffer
 	for _, line := range lines {
 		in.WriteString(line)
 		in.WriteString("\n")
 	}
 
-	cmd := exec.Command("fzf", "--height", "80%", "--layout", "reverse", "--prompt", prompt)
+	args := []string{"--ansi", "--height", "80%", "--layout", "reverse", "--prompt", prompt}
+	if preview {
+		args = append(args, opts.fzfPreviewArgs()...)
+	}
+	cmd := exec.Command("fzf", args...)
+	cmd.Env = atRestEnv()
 	cmd.Stdin = &in
 	var out bytes.Buffer
 	cmd.Stdout = &out
@@ -134,18 +194,24 @@ func pickLineWithFZF(lines []string, prompt string) (string, bool, error) {
 	return selected, true, nil
 }
 
-func pickWithFZF(candidates []instanceCandidate) (*instanceCandidate, bool, error) {
+// multiSelectHeader is shown by pickers that accept several lines.
+const multiSelectHeader = "TAB to mark several, ENTER to confirm"
+
+// pickLinesWithFZF is pickLineWithFZF with --multi: it returns every marked
+// line, or the highlighted one when nothing was marked.
+func pickLinesWithFZF(opts Options, lines []string, prompt string, preview bool) ([]string, bool, error) {
 	var in bytes.Buffer
-	lookup := make(map[string]instanceCandidate, len(candidates))
-	in.WriteString(fzfBackOption)
-	in.WriteString("\n")
-	for _, c := range candidates {
-		in.WriteString(c.DisplayLine)
+	for _, line := range lines {
+		in.WriteString(line)
 		in.WriteString("\n")
-		lookup[c.DisplayLine] = c
 	}
 
-	cmd := exec.Command("fzf", "--ansi", "--height", "80%", "--layout", "reverse", "--prompt", "Select EC2 instance > ")
+	args := []string{"--ansi", "--height", "80%", "--layout", "reverse", "--multi", "--prompt", prompt, "--header", multiSelectHeader}
+	if preview {
+		args = append(args, opts.fzfPreviewArgs()...)
+	}
+	cmd := exec.Command("fzf", args...)
+	cmd.Env = atRestEnv()
 	cmd.Stdin = &in
 	var out bytes.Buffer
 	cmd.Stdout = &out
@@ -159,17 +225,352 @@ func pickWithFZF(candidates []instanceCandidate) (*instanceCandidate, bool, erro
 		}
 		return nil, false, err
 	}
+	var selected []string
+	for _, line := range strings.Split(out.String(), "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			selected = append(selected, line)
+		}
+	}
+	return selected, len(selected) > 0, nil
+}
+
+// streamScanBatches writes every scan batch to w as it arrives, so a slow
+// region never keeps instances out of the picker. fzf only appends to what
+// it read, so when a used instance arrived after ones it ranks ahead of,
+// the final order is returned for a single reload once the scan is done.
+func streamScanBatches(opts Options, w io.Writer, initial []instanceCandidate, updates <-chan scanResult, onBatch func(r scanResult, count int)) ([]instanceCandidate, bool) {
+	count := len(initial)
+	ranked := rankedCandidates{opts: opts, items: append([]instanceCandidate(nil), initial...)}
+	reordered := false
+	for r := range updates {
+		if ranked.add(r.Candidates) {
+			reordered = true
+		}
+		count += len(r.Candidates)
+		onBatch(r, count)
+		for _, c := range r.Candidates {
+			_, _ = io.WriteString(w, opts.instanceLine(c)+"\n")
+		}
+	}
+	return ranked.items, reordered
+}
+
+func pickWithFZF(opts Options, initial []instanceCandidate, progress scanResult, updates <-chan scanResult, reload *reloadSpec) (*instanceCandidate, pickerAction, error) {
+	var mu sync.Mutex
+	var exited atomic.Bool
+	lookup := make(map[string]instanceCandidate, len(initial))
+	for _, c := range initial {
+		lookup[stripANSI(opts.instanceLine(c))] = c
+	}
+
+	args := []string{
+		"--ansi", "--height", "80%", "--layout", "reverse",
+		"--prompt", "Select EC2 instance > ",
+		"--header", fzfInstanceHeader(scanProgressHeader(progress, len(initial)), reload != nil),
+		"--expect", "ctrl-p,alt-a,alt-r",
+	}
+	args = append(args, opts.fzfPreviewArgs()...)
+	listener := newFZFListener()
+	defer listener.close()
+	if listener != nil {
+		args = append(args, "--listen="+listener.Addr)
+	}
+	if reload != nil {
+		reload.Listen = listener
+		if err := reload.save(); err != nil {
+			return nil, pickerConnect, err
+		}
+		args = append(args, fzfReloadArgs(reload)...)
+	}
+	cmd := exec.Command("fzf", args...)
+	cmd.Env = listener.env(atRestEnv())
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, pickerConnect, err
+	}
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	if err := cmd.Start(); err != nil {
+		return nil, pickerConnect, err
+	}
 
-	selectedLine := strings.TrimSpace(out.String())
+	_, _ = io.WriteString(stdin, strings.Join(opts.instancePickerLines(initial), "\n")+"\n")
+
+	go func() {
+		defer stdin.Close()
+		ranked, reordered := streamScanBatches(opts, stdin, initial, updates, func(r scanResult, count int) {
+			mu.Lock()
+			for _, c := range r.Candidates {
+				lookup[stripANSI(opts.instanceLine(c))] = c
+			}
+			mu.Unlock()
+			if r.Total > 0 {
+				progress = r
+			}
+			if listener != nil && !exited.Load() && (reload == nil || !reload.reloaded()) {
+				postFZFAction(listener, "change-header:"+fzfInstanceHeader(scanProgressHeader(progress, count), reload != nil))
+			}
+		})
+		if reordered && listener != nil && !exited.Load() && (reload == nil || !reload.reloaded()) {
+			_ = stdin.Close()
+			listener.reloadLines(opts.instancePickerLines(ranked))
+		}
+	}()
+
+	err = cmd.Wait()
+	exited.Store(true)
+	if err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && exitErr.ExitCode() == 130 {
+			return nil, pickerConnect, nil
+		}
+		return nil, pickerConnect, err
+	}
+
+	key, selectedLine := parseFZFExpectOutput(out.String())
+	switch key {
+	case "alt-a":
+		return nil, pickerBackToAccounts, nil
+	case "alt-r":
+		return nil, pickerBackToRoles, nil
+	}
 	if selectedLine == "" {
-		return nil, false, nil
+		return nil, pickerConnect, nil
 	}
 	if selectedLine == fzfBackOption {
-		return nil, true, nil
+		return nil, pickerBack, nil
+	}
+	mu.Lock()
+	selected, ok := lookup[stripANSI(selectedLine)]
+	mu.Unlock()
+	if !ok && reload != nil {
+		for _, c := range reload.results() {
+			if stripANSI(opts.instanceLine(c)) == stripANSI(selectedLine) {
+				selected, ok = c, true
+				break
+			}
+		}
 	}
-	selected, ok := lookup[selectedLine]
 	if !ok {
-		return nil, false, fmt.Errorf("selected value not found in lookup")
+		return nil, pickerConnect, fmt.Errorf("selected value not found in lookup")
+	}
+	if key == "ctrl-p" {
+		return &selected, pickerPortForward, nil
+	}
+	return &selected, pickerConnect, nil
+}
+
+// parseFZFExpectOutput splits fzf --expect output into the key that ended
+// the picker (empty for enter) and the selected line.
+func parseFZFExpectOutput(out string) (string, string) {
+	key, selected, _ := strings.Cut(strings.TrimRight(out, "\n"), "\n")
+	return strings.TrimSpace(key), strings.TrimSpace(selected)
+}
+
+// instancePickerLines is the full instance picker list, back option first.
+func (o Options) instancePickerLines(cands []instanceCandidate) []string {
+	lines := []string{fzfBackOption}
+	for _, c := range cands {
+		lines = append(lines, o.instanceLine(c))
+	}
+	return lines
+}
+
+func (o Options) instanceLine(c instanceCandidate) string {
+	return fzfKeyedLine(o.frecencyLabel(candidateUsageKey(c), o.environmentLabel(c.AccountID, c.AccountName)+o.favoriteLabel(c, c.DisplayLine)), previewKeyInstance(c))
+}
+
+// fzfKeyedLine appends a hidden, tab-separated key to a picker line. fzf
+// only displays and matches the first field (see fzfPreviewArgs) and prints
+// the full line back, so lookups keep working on the keyed form while
+// previews and key bindings can refer to the key as {2}.
+func fzfKeyedLine(display, key string) string {
+	return display + "\t" + key
+}
+
+func (o Options) fzfPreviewArgs() []string {
+	args := []string{"--delimiter", "\t", "--with-nth", "1"}
+	if o.previewCommand == "" {
+		return args
+	}
+	return append(args,
+		"--preview", o.previewCommand,
+		"--preview-window", "right,50%,wrap",
+	)
+}
+
+// fzfReloadArgs binds the in-picker actions that call back into swamp.
+// change-header must come last since its colon form takes the rest of the
+// binding as text.
+func fzfReloadArgs(spec *reloadSpec) []string {
+	exe := shellQuote(swampExecutable)
+	path := shellQuote(spec.Path)
+	return []string{
+		"--bind", fmt.Sprintf("ctrl-r:reload(%s __scan --fresh %s)+change-header:%s", exe, path, fzfInstanceHeader("Rescanning without cache...", true)),
+		"--bind", fmt.Sprintf("ctrl-s:reload(%s __scan --toggle-stopped %s)+change-header:%s", exe, path, fzfInstanceHeader("Rescanning with stopped instances toggled...", true)),
+		"--bind", fmt.Sprintf("ctrl-y:execute-silent(%s __copy-id {2})", exe),
+	}
+}
+
+func fzfInstanceHeader(status string, reload bool) string {
+	var keys []string
+	if reload {
+		keys = append(keys, "ctrl-r rescan", "ctrl-s toggle stopped", "ctrl-y copy ID")
+	}
+	keys = append(keys, "ctrl-p port-forward", "alt-a accounts", "alt-r roles")
+	return status + "\n" + strings.Join(keys, " | ")
+}
+
+func scanProgressHeader(progress scanResult, found int) string {
+	header := scanProgressStatus(progress, found)
+	if progress.Stale > 0 {
+		header += fmt.Sprintf(" (%d from stale cache, refreshing)", progress.Stale)
+	}
+	return header
+}
+
+func scanProgressStatus(progress scanResult, found int) string {
+	switch {
+	case progress.Err != nil:
+		return fmt.Sprintf("Scan stopped at %d/%d: %v; %d instances", progress.Done, progress.Total, progress.Err, found)
+	case progress.Total == 0:
+		return fmt.Sprintf("Scanning...; %d instances so far", found)
+	case progress.Done >= progress.Total:
+		return fmt.Sprintf("Scan complete %d/%d; %d instances", progress.Done, progress.Total, found)
+	default:
+		return fmt.Sprintf("Scanning %d/%d; %d instances so far", progress.Done, progress.Total, found)
+	}
+}
+
+// fzfListener is where swamp reaches a running fzf's HTTP API to update the
+// header. fzf 0.56+ listens on a unix socket in a private temp dir; older
+// versions get a loopback port. Requests always carry a random FZF_API_KEY,
+// so other local users cannot send fzf actions such as execute(...).
+type fzfListener struct {
+	Addr   string `json:"addr"`
+	Socket bool   `json:"socket"`
+	APIKey string `json:"api_key"`
+	dir    string
+}
+
+// newFZFListener returns nil when the installed fzf is too old to accept
+// change-header over its HTTP API.
+func newFZFListener() *fzfListener {
+	major, minor, ok := fzfVersion()
+	if !ok || (major == 0 && minor < 46) {
+		return nil
+	}
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil
+	}
+	dir, err := os.MkdirTemp("", "swamp-fzf-")
+	if err != nil {
+		return nil
+	}
+	l := &fzfListener{APIKey: hex.EncodeToString(key), dir: dir}
+	if major > 0 || minor >= 56 {
+		l.Addr, l.Socket = filepath.Join(dir, "fzf.sock"), true
+		return l
+	}
+	// Without socket support the port can only be found by binding and
+	// releasing it; the API key keeps a process that grabs it in between
+	// from getting anything useful.
+	tl, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		l.close()
+		return nil
+	}
+	defer tl.Close()
+	l.Addr = tl.Addr().String()
+	return l
+}
+
+// reloadLines replaces fzf's list with lines, which fzf reads back from a
+// file in the listener's private directory.
+func (l *fzfListener) reloadLines(lines []string) {
+	path := filepath.Join(l.dir, "lines")
+	if err := os.WriteFile(path, []byte(strings.Join(lines, "\n")+"\n"), 0o600); err != nil {
+		return
+	}
+	postFZFAction(l, "reload(cat "+shellQuote(path)+")")
+}
+
+func (l *fzfListener) env(base []string) []string {
+	if l == nil {
+		return base
+	}
+	return append(base, "FZF_API_KEY="+l.APIKey)
+}
+
+func (l *fzfListener) close() {
+	if l != nil && l.dir != "" {
+		_ = os.RemoveAll(l.dir)
+	}
+}
+
+var (
+	fzfVersionOnce     sync.Once
+	fzfMajor, fzfMinor int
+	fzfVersionKnown    bool
+)
+
+func fzfVersion() (int, int, bool) {
+	fzfVersionOnce.Do(func() {
+		out, err := exec.Command("fzf", "--version").Output()
+		if err != nil {
+			return
+		}
+		fzfMajor, fzfMinor, fzfVersionKnown = parseFZFVersion(string(out))
+	})
+	return fzfMajor, fzfMinor, fzfVersionKnown
+}
+
+func parseFZFVersion(raw string) (int, int, bool) {
+	fields := strings.Fields(raw)
+	if len(fields) == 0 {
+		return 0, 0, false
+	}
+	parts := strings.Split(fields[0], ".")
+	if len(parts) < 2 {
+		return 0, 0, false
+	}
+	major, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return 0, 0, false
+	}
+	minor, err := strconv.Atoi(parts[1])
+	if err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
+
+func postFZFAction(l *fzfListener, action string) {
+	client := http.Client{Timeout: 500 * time.Millisecond}
+	url := "http://" + l.Addr
+	if l.Socket {
+		url = "http://fzf"
+		client.Transport = &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, "unix", l.Addr)
+			},
+		}
+	}
+	// fzf may still be starting up when the first progress update arrives.
+	for attempt := 0; attempt < 10; attempt++ {
+		req, err := http.NewRequest(http.MethodPost, url, strings.NewReader(action))
+		if err != nil {
+			return
+		}
+		req.Header.Set("x-api-key", l.APIKey)
+		resp, err := client.Do(req)
+		if err == nil {
+			resp.Body.Close()
+			return
+		}
+		time.Sleep(50 * time.Millisecond)
 	}
-	return &selected, false, nil
 }
@@ -1,7 +1,13 @@
 package app
 
 import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"path/filepath"
 	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -30,3 +36,93 @@ func TestWithBackOption(t *testing.T) {
 		}
 	}
 }
+
+func TestParseFZFVersion(t *testing.T) {
+	major, minor, ok := parseFZFVersion("0.54.3 (brew)\n")
+	if !ok || major != 0 || minor != 54 {
+		t.Fatalf("unexpected parse: %d.%d ok=%t", major, minor, ok)
+	}
+	if _, _, ok := parseFZFVersion("garbage"); ok {
+		t.Fatal("expected unparsable version to fail")
+	}
+}
+
+func TestScanProgressHeader(t *testing.T) {
+	if got := scanProgressHeader(scanResult{Done: 3, Total: 17}, 5); got != "Scanning 3/17; 5 instances so far" {
+		t.Fatalf("unexpected in-progress header: %q", got)
+	}
+	if got := scanProgressHeader(scanResult{Done: 17, Total: 17}, 9); got != "Scan complete 17/17; 9 instances" {
+		t.Fatalf("unexpected complete header: %q", got)
+	}
+}
+
+func TestPostFZFActionSendsAPIKeyOverSocket(t *testing.T) {
+	l := &fzfListener{Addr: filepath.Join(t.TempDir(), "fzf.sock"), Socket: true, APIKey: "secret"}
+	ln, err := net.Listen("unix", l.Addr)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	got := make(chan string, 1)
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		got <- r.Header.Get("x-api-key") + " " + string(body)
+	})}
+	go srv.Serve(ln)
+	defer srv.Close()
+
+	postFZFAction(l, "change-header:hi")
+	if msg := <-got; msg != "secret change-header:hi" {
+		t.Fatalf("expected the API key and action, got %q", msg)
+	}
+	if env := l.env(nil); len(env) != 1 || env[0] != "FZF_API_KEY=secret" {
+		t.Fatalf("expected fzf to get the key in its environment, got %v", env)
+	}
+}
+
+func TestSelectRegionsWithFZFReturnsEveryMarkedRegion(t *testing.T) {
+	orig := pickLinesFn
+	defer func() { pickLinesFn = orig }()
+
+	pickLinesFn = func(_ Options, lines []string, prompt string, preview bool) ([]string, bool, error) {
+		return lines[1:], true, nil
+	}
+	got, back, err := selectRegionsWithFZF(Options{}, []string{"eu-west-1", "us-east-1"})
+	if err != nil || back {
+		t.Fatalf("unexpected result: back=%t err=%v", back, err)
+	}
+	if !reflect.DeepEqual(got, []string{"eu-west-1", "us-east-1"}) {
+		t.Fatalf("expected both regions, got %v", got)
+	}
+}
+
+func TestStreamScanBatchesWritesRankedBatchesWhileRegionsAreStillScanning(t *testing.T) {
+	opts := Options{usage: map[string]float64{"instance:i-used": 50}}
+
+	initial := []instanceCandidate{{InstanceID: "i-a", DisplayLine: "a"}}
+	updates := make(chan scanResult)
+	r, w := io.Pipe()
+	type result struct {
+		ranked    []instanceCandidate
+		reordered bool
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer w.Close()
+		ranked, reordered := streamScanBatches(opts, w, initial, updates, func(scanResult, int) {})
+		done <- result{ranked, reordered}
+	}()
+
+	// The used instance ranks ahead of what fzf already shows; it must be
+	// written right away even though a slow region has not reported yet.
+	updates <- scanResult{Candidates: []instanceCandidate{{InstanceID: "i-used", DisplayLine: "used"}}, Done: 1, Total: 2}
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil || !strings.Contains(line, "i-used") {
+		t.Fatalf("expected the ranked batch to be written before the scan finished, got %q, %v", line, err)
+	}
+
+	close(updates)
+	got := <-done
+	if !got.reordered || len(got.ranked) != 2 || got.ranked[0].InstanceID != "i-used" {
+		t.Fatalf("expected a final reload with the used instance first, got %+v", got)
+	}
+}
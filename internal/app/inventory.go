@@ -0,0 +1,320 @@
+package app
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"gopkg.in/yaml.v3"
+)
+
+// inventoryRecord is one instance as printed by `swamp ls`.
+type inventoryRecord struct {
+	AccountID        string            `json:"account_id" yaml:"account_id"`
+	AccountName      string            `json:"account_name" yaml:"account_name"`
+	Role             string            `json:"role" yaml:"role"`
+	Region           string            `json:"region" yaml:"region"`
+	InstanceID       string            `json:"instance_id" yaml:"instance_id"`
+	Name             string            `json:"name" yaml:"name"`
+	PrivateIP        string            `json:"private_ip" yaml:"private_ip"`
+	State            string            `json:"state" yaml:"state"`
+	Platform         string            `json:"platform" yaml:"platform"`
+	InstanceType     string            `json:"instance_type" yaml:"instance_type"`
+	AvailabilityZone string            `json:"availability_zone" yaml:"availability_zone"`
+	LaunchTime       string            `json:"launch_time" yaml:"launch_time"`
+	SSMPingStatus    string            `json:"ssm_ping_status" yaml:"ssm_ping_status"`
+	Tags             map[string]string `json:"tags" yaml:"tags"`
+}
+
+// inventorySortKeys maps --sort keys to record fields.
+var inventorySortKeys = map[string]func(r inventoryRecord) string{
+	"account":    func(r inventoryRecord) string { return r.AccountName },
+	"account_id": func(r inventoryRecord) string { return r.AccountID },
+	"role":       func(r inventoryRecord) string { return r.Role },
+	"region":     func(r inventoryRecord) string { return r.Region },
+	"id":         func(r inventoryRecord) string { return r.InstanceID },
+	"name":       func(r inventoryRecord) string { return r.Name },
+	"ip":         func(r inventoryRecord) string { return r.PrivateIP },
+	"state":      func(r inventoryRecord) string { return r.State },
+	"platform":   func(r inventoryRecord) string { return r.Platform },
+	"type":       func(r inventoryRecord) string { return r.InstanceType },
+	"launch":     func(r inventoryRecord) string { return r.LaunchTime },
+}
+
+var inventoryCSVHeader = []string{"account_id", "account_name", "role", "region", "instance_id", "name", "private_ip", "state", "platform", "instance_type", "availability_zone", "launch_time", "ssm_ping_status", "tags"}
+
+// inventoryOutput is a parsed --output value.
+type inventoryOutput struct {
+	format string
+	tmpl   *template.Template
+}
+
+func parseInventoryOutput(value string) (inventoryOutput, error) {
+	value = strings.TrimSpace(value)
+	if name, text, ok := strings.Cut(value, "="); ok && name == "template" {
+		tmpl, err := template.New("output").Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return inventoryOutput{}, fmt.Errorf("invalid --output template: %w", err)
+		}
+		return inventoryOutput{format: "template", tmpl: tmpl}, nil
+	}
+	switch value {
+	case "", "table":
+		return inventoryOutput{format: "table"}, nil
+	case "json", "yaml", "csv":
+		return inventoryOutput{format: value}, nil
+	}
+	return inventoryOutput{}, fmt.Errorf("invalid --output %q (expected json, yaml, csv, table or template=...)", value)
+}
+
+// parseInventorySort turns "region,-name" into sort keys; a leading "-"
+// sorts that key in descending order. tag:KEY sorts by a tag value.
+func parseInventorySort(value string) ([]string, error) {
+	keys := splitCSV(value)
+	for _, key := range keys {
+		name := strings.TrimPrefix(key, "-")
+		if strings.HasPrefix(name, "tag:") && len(name) > len("tag:") {
+			continue
+		}
+		if _, ok := inventorySortKeys[name]; !ok {
+			names := make([]string, 0, len(inventorySortKeys))
+			for k := range inventorySortKeys {
+				names = append(names, k)
+			}
+			sort.Strings(names)
+			return nil, fmt.Errorf("invalid --sort key %q (expected %s or tag:KEY)", name, strings.Join(names, ", "))
+		}
+	}
+	return keys, nil
+}
+
+func sortInventory(records []inventoryRecord, keys []string) {
+	sort.SliceStable(records, func(i, j int) bool {
+		for _, key := range keys {
+			name := strings.TrimPrefix(key, "-")
+			value := func(r inventoryRecord) string {
+				if tag, ok := strings.CutPrefix(name, "tag:"); ok {
+					return r.Tags[tag]
+				}
+				return inventorySortKeys[name](r)
+			}
+			a, b := strings.ToLower(value(records[i])), strings.ToLower(value(records[j]))
+			if a == b {
+				continue
+			}
+			if strings.HasPrefix(key, "-") {
+				return a > b
+			}
+			return a < b
+		}
+		return false
+	})
+}
+
+func inventoryFromCandidate(c instanceCandidate) inventoryRecord {
+	// queryInstances fills blanks with "-" for the picker line.
+	blank := func(s string) string {
+		if s == "-" {
+			return ""
+		}
+		return s
+	}
+	tags := c.Tags
+	if tags == nil {
+		tags = map[string]string{}
+	}
+	return inventoryRecord{
+		AccountID:        c.AccountID,
+		AccountName:      c.AccountName,
+		Role:             c.RoleName,
+		Region:           c.Region,
+		InstanceID:       c.InstanceID,
+		Name:             blank(c.Name),
+		PrivateIP:        blank(c.PrivateIP),
+		State:            blank(c.State),
+		Platform:         blank(c.Platform),
+		InstanceType:     c.InstanceType,
+		AvailabilityZone: c.AvailabilityZone,
+		LaunchTime:       c.LaunchTime,
+		SSMPingStatus:    c.PingStatus,
+		Tags:             tags,
+	}
+}
+
+func writeInventory(w io.Writer, records []inventoryRecord, out inventoryOutput) error {
+	switch out.format {
+	case "json":
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if records == nil {
+			records = []inventoryRecord{}
+		}
+		return enc.Encode(records)
+	case "yaml":
+		enc := yaml.NewEncoder(w)
+		enc.SetIndent(2)
+		if err := enc.Encode(records); err != nil {
+			return err
+		}
+		return enc.Close()
+	case "csv":
+		cw := csv.NewWriter(w)
+		_ = cw.Write(inventoryCSVHeader)
+		for _, r := range records {
+			_ = cw.Write([]string{r.AccountID, r.AccountName, r.Role, r.Region, r.InstanceID, r.Name, r.PrivateIP, r.State, r.Platform, r.InstanceType, r.AvailabilityZone, r.LaunchTime, r.SSMPingStatus, formatTags(r.Tags)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "template":
+		for _, r := range records {
+			if err := out.tmpl.Execute(w, r); err != nil {
+				return fmt.Errorf("render --output template: %w", err)
+			}
+			fmt.Fprintln(w)
+		}
+		return nil
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ACCOUNT\tACCOUNT ID\tROLE\tREGION\tINSTANCE\tNAME\tIP\tSTATE\tPLATFORM")
+	for _, r := range records {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n", r.AccountName, r.AccountID, r.Role, r.Region, r.InstanceID, orDash(r.Name), orDash(r.PrivateIP), orDash(r.State), orDash(r.Platform))
+	}
+	return tw.Flush()
+}
+
+// formatTags renders tags as sorted key=value pairs separated by ";".
+func formatTags(tags map[string]string) string {
+	keys := make([]string, 0, len(tags))
+	for k := range tags {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	parts := make([]string, 0, len(keys))
+	for _, k := range keys {
+		parts = append(parts, k+"="+tags[k])
+	}
+	return strings.Join(parts, ";")
+}
+
+// List runs the interactive flow's discovery without pickers, scanning
+// every allowed account, role and discovered region as with
+// --skip-region-select, and prints the instances.
+func List(ctx context.Context, opts Options, output, sortBy string) error {
+	out, err := parseInventoryOutput(output)
+	if err != nil {
+		return err
+	}
+	sortKeys, err := parseInventorySort(sortBy)
+	if err != nil {
+		return err
+	}
+
+	// Progress goes to stderr so stdout carries only the inventory.
+	opts.progress = os.Stderr
+	resolved, cfg, err := resolveRuntimeOptions(opts)
+	if err != nil {
+		return err
+	}
+	if err := validateOptionsWithSource(resolved); err != nil {
+		return err
+	}
+	if err := configureAtRest(resolved); err != nil {
+		return err
+	}
+	if !cfg.SourceExists {
+		return fmt.Errorf("profile %q was not found in ~/.aws/config", resolved.Profile)
+	}
+	resolved.cacheStore = newCacheStore(resolved)
+	resolved.cacheStore.ctx = ctx
+	defer func() { _ = resolved.cacheStore.flushStats() }()
+	defer resolved.cacheStore.waitForRefreshes(os.Stderr, refreshGracePeriod)
+	resolved.budget = newDiscoveryBudget(resolved.Timeout)
+
+	accessToken, err := ensureSSOLoginAndGetToken(ctx, resolved.progressOut(), resolved.Profile, cfg.SSOStartURL)
+	if err != nil {
+		if ctx.Err() != nil {
+			return describeContextError(resolved, context.Cause(ctx))
+		}
+		return fmt.Errorf("failed to authenticate profile %q: %w", resolved.Profile, err)
+	}
+	cands, err := scanInventory(ctx, resolved, cfg, resolveSSORegion(cfg), accessToken)
+	if err != nil {
+		return describeContextError(resolved, err)
+	}
+
+	records := make([]inventoryRecord, 0, len(cands))
+	for _, c := range cands {
+		records = append(records, inventoryFromCandidate(c))
+	}
+	sortInventory(records, append(sortKeys, "account", "role", "region", "name", "id"))
+	return writeInventory(os.Stdout, records, out)
+}
+
+// scanInventory discovers accounts, roles and regions like the interactive
+// flow and scans each account with its `accounts:` overrides applied.
+func scanInventory(ctx context.Context, opts Options, cfg profileConfig, ssoRegion, accessToken string) ([]instanceCandidate, error) {
+	discoveryCtx, cancel := opts.discoveryContext(ctx)
+	accounts, err := discoverAccounts(discoveryCtx, opts, ssoRegion, accessToken)
+	if err == nil {
+		var targets []roleTarget
+		targets, err = discoverRoleTargetsFn(discoveryCtx, opts, accounts, ssoRegion, accessToken)
+		accounts = accountsWithTargets(accounts, targets)
+		cancel()
+		if err != nil {
+			return nil, err
+		}
+		return scanInventoryTargets(ctx, opts, cfg, ssoRegion, accounts, targets)
+	}
+	cancel()
+	return nil, err
+}
+
+func scanInventoryTargets(ctx context.Context, opts Options, cfg profileConfig, ssoRegion string, accounts []ssoAccountsResponse, targets []roleTarget) ([]instanceCandidate, error) {
+	tmpConfigPath, profileNames, err := buildTempAWSConfigFn(cfg, targets)
+	if err != nil {
+		return nil, fmt.Errorf("failed to build temporary AWS config: %w", err)
+	}
+	defer opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
+
+	var all []instanceCandidate
+	for _, acct := range accounts {
+		info := acct.AccountList[0]
+		acctOpts := opts.forAccount(info.AccountID, info.AccountName)
+		acctTargets := targetsInAccount(targets, info.AccountID)
+
+		discoveryCtx, cancel := acctOpts.discoveryContext(ctx)
+		regions, err := discoverRegionsFn(discoveryCtx, acctOpts, cfg, acctTargets, tmpConfigPath, profileNames, ssoRegion)
+		cancel()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil, context.Cause(ctx)
+			}
+			fmt.Fprintf(os.Stderr, "warning: skipping account %s: %v\n", info.AccountID, err)
+			continue
+		}
+		cands, err := scanAllInstancesFn(ctx, acctOpts, tmpConfigPath, acctTargets, profileNames, regions, acctOpts.Workers, !acctOpts.IncludeStopped)
+		all = append(all, cands...)
+		if err != nil {
+			return all, err
+		}
+	}
+	return all, nil
+}
+
+// accountsWithTargets drops accounts left without a role after filtering.
+func accountsWithTargets(accounts []ssoAccountsResponse, targets []roleTarget) []ssoAccountsResponse {
+	var out []ssoAccountsResponse
+	for _, a := range accounts {
+		if len(a.AccountList) > 0 && len(targetsInAccount(targets, a.AccountList[0].AccountID)) > 0 {
+			out = append(out, a)
+		}
+	}
+	return out
+}
@@ -0,0 +1,107 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestScanInventoryCoversEveryAccountAndRegion(t *testing.T) {
+	installWarmTestSeams(t)
+	opts := newTestCacheOptions(t, "fresh")
+	opts.Workers = 2
+	opts.filter, _ = parseFilter("account=prod")
+	queryInstancesFetcher = func(ctx context.Context, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+		return []instanceCandidate{{InstanceID: "i-" + target.AccountID + "-" + region, AccountID: target.AccountID, AccountName: target.AccountName, Region: region}}, nil
+	}
+
+	cands, err := scanInventory(context.Background(), opts, profileConfig{}, "us-east-1", "token")
+	if err != nil {
+		t.Fatalf("scanInventory: %v", err)
+	}
+	var ids []string
+	for _, c := range cands {
+		ids = append(ids, c.InstanceID)
+	}
+	if strings.Join(ids, ",") != "i-111-eu-west-1,i-111-us-east-1" && strings.Join(ids, ",") != "i-111-us-east-1,i-111-eu-west-1" {
+		t.Fatalf("expected prod instances from both regions, got %v", ids)
+	}
+}
+
+func TestWriteInventoryFormats(t *testing.T) {
+	records := []inventoryRecord{
+		inventoryFromCandidate(instanceCandidate{AccountID: "2", AccountName: "prod", RoleName: "Admin", Region: "us-east-1", InstanceID: "i-2", Name: "web", PrivateIP: "-", State: "running", Platform: "Linux", Tags: map[string]string{"Team": "core", "Env": "prod"}}),
+		inventoryFromCandidate(instanceCandidate{AccountID: "1", AccountName: "dev", RoleName: "Admin", Region: "eu-west-1", InstanceID: "i-1", Name: "-", State: "stopped"}),
+	}
+	sortInventory(records, []string{"account"})
+	if records[0].InstanceID != "i-1" {
+		t.Fatalf("expected dev first, got %+v", records)
+	}
+
+	var buf bytes.Buffer
+	if err := writeInventory(&buf, records, inventoryOutput{format: "json"}); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("invalid json %q: %v", buf.String(), err)
+	}
+	if decoded[1]["instance_id"] != "i-2" || decoded[1]["private_ip"] != "" || decoded[0]["name"] != "" {
+		t.Fatalf("unexpected json %s", buf.String())
+	}
+	if _, ok := decoded[1]["DisplayLine"]; ok {
+		t.Fatal("json should not carry the picker line")
+	}
+
+	buf.Reset()
+	if err := writeInventory(&buf, records, inventoryOutput{format: "csv"}); err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(buf.String()), "\n"); len(lines) != 3 || !strings.HasSuffix(lines[2], ",Env=prod;Team=core") {
+		t.Fatalf("unexpected csv %q", buf.String())
+	}
+
+	out, err := parseInventoryOutput(`template={{.InstanceID}} {{index .Tags "Team"}}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := writeInventory(&buf, records, out); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "i-1 \ni-2 core\n" {
+		t.Fatalf("unexpected template output %q", buf.String())
+	}
+
+	buf.Reset()
+	if err := writeInventory(&buf, records, inventoryOutput{format: "table"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "i-1       -") {
+		t.Fatalf("expected dashes for blank table cells, got %q", buf.String())
+	}
+}
+
+func TestInventoryOptionErrors(t *testing.T) {
+	if _, err := parseInventoryOutput("xml"); err == nil || !strings.Contains(err.Error(), "invalid --output") {
+		t.Fatalf("expected an --output error, got %v", err)
+	}
+	if _, err := parseInventoryOutput("template={{.Nope"); err == nil {
+		t.Fatal("expected a template parse error")
+	}
+	if _, err := parseInventorySort("region,-tag:Team,owner"); err == nil || !strings.Contains(err.Error(), `"owner"`) {
+		t.Fatalf("expected an unknown sort key error, got %v", err)
+	}
+
+	records := []inventoryRecord{{Name: "a", Region: "x"}, {Name: "b", Region: "x"}, {Name: "c", Region: "a"}}
+	keys, err := parseInventorySort("region,-name")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sortInventory(records, keys)
+	if records[0].Name != "c" || records[1].Name != "b" || records[2].Name != "a" {
+		t.Fatalf("unexpected order %+v", records)
+	}
+}
@@ -0,0 +1,51 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"path/filepath"
+	"strings"
+)
+
+// lockDirName holds per-key refresh locks inside the cache directory. Lock
+// files are never deleted while swamp runs: removing one could let two
+// processes hold "the same" lock on different inodes.
+const lockDirName = "locks"
+
+// withFileLock runs fn while holding an exclusive lock on path+".lock", so
+// read-modify-write updates of shared state files from concurrent swamp
+// processes are serialized instead of overwriting each other.
+func withFileLock(path string, fn func() error) error {
+	if err := mkdirPrivate(filepath.Dir(path)); err != nil {
+		return err
+	}
+	lock, _, err := acquireFileLock(path+".lock", true)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	return fn()
+}
+
+// withCacheDirLock is withFileLock for files the user owns, such as config
+// files: the lock lives in the cache directory's lock dir, named after the
+// file's absolute path, so nothing is left beside the edited file.
+func withCacheDirLock(cacheDir, path string, fn func() error) error {
+	if strings.TrimSpace(cacheDir) == "" {
+		cacheDir = defaultCacheDir()
+	}
+	dir := filepath.Join(cacheDir, lockDirName)
+	if err := mkdirPrivate(dir); err != nil {
+		return err
+	}
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	sum := sha256.Sum256([]byte(path))
+	lock, _, err := acquireFileLock(filepath.Join(dir, "file-"+hex.EncodeToString(sum[:8])+".lock"), true)
+	if err != nil {
+		return err
+	}
+	defer lock.release()
+	return fn()
+}
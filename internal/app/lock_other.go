@@ -0,0 +1,13 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package app
+
+type fileLock struct{}
+
+// acquireFileLock is a no-op where flock is unavailable; concurrent runs
+// fall back to last-writer-wins.
+func acquireFileLock(path string, wait bool) (*fileLock, bool, error) {
+	return &fileLock{}, true, nil
+}
+
+func (l *fileLock) release() {}
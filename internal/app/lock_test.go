@@ -0,0 +1,74 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestSaveRecentTargetsConcurrentWritersKeepAllHistory(t *testing.T) {
+	dir := t.TempDir()
+	scope := recentScope{AccountID: "123456789012", RoleName: "Admin", Region: "us-east-1"}
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			inst := recentInstance{InstanceID: fmt.Sprintf("i-%d", i), Region: "us-east-1"}
+			if err := saveRecentTargets(dir, "p", scope, inst); err != nil {
+				t.Errorf("saveRecentTargets: %v", err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	got, err := loadRecentTargets(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := len(got.history("p")); n != 20 {
+		t.Fatalf("expected 20 history entries, got %d", n)
+	}
+}
+
+func TestRefreshAsyncSkipsKeyLockedByAnotherProcess(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	key := cacheKeyAccounts(opts.Profile, "us-east-1")
+	lock, ok, err := opts.cacheStore.acquireRefreshLock(opts.Profile, key)
+	if err != nil || !ok {
+		t.Fatalf("acquire lock: ok=%v err=%v", ok, err)
+	}
+	defer lock.release()
+
+	var calls atomic.Int32
+	opts.cacheStore.refreshAsync(opts.Profile, key, func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+	opts.cacheStore.waitForRefreshes(io.Discard, 2*time.Second)
+	if calls.Load() != 0 {
+		t.Fatal("expected refresh to be skipped while another writer holds the lock")
+	}
+}
+
+func TestRefreshAsyncSkipsKeyRefreshedMeanwhile(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	key := cacheKeyAccounts(opts.Profile, "us-east-1")
+	if err := opts.cacheStore.writeJSON(opts.Profile, key, time.Minute, []ssoAccountsResponse{}); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls atomic.Int32
+	opts.cacheStore.refreshAsync(opts.Profile, key, func(ctx context.Context) error {
+		calls.Add(1)
+		return nil
+	})
+	opts.cacheStore.waitForRefreshes(io.Discard, 2*time.Second)
+	if calls.Load() != 0 {
+		t.Fatal("expected no refresh for an entry that is already fresh")
+	}
+}
@@ -0,0 +1,579 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+)
+
+type pickerKeyKind int
+
+const (
+	keyRune pickerKeyKind = iota
+	keyEnter
+	keyBackspace
+	keyUp
+	keyDown
+	keyPageUp
+	keyPageDown
+	keyTab
+	keyClearQuery
+	keyCancel
+	keyCtrlP
+	keyAltA
+	keyAltR
+)
+
+type pickerKey struct {
+	kind pickerKeyKind
+	r    rune
+}
+
+// pickerFeed carries lines and header changes that arrive while the
+// built-in picker is open, e.g. streamed instance scan results.
+type pickerFeed struct {
+	Lines  []string
+	Header string
+	// Replace swaps in Lines as the whole list instead of appending them.
+	Replace bool
+}
+
+type builtinPicker struct {
+	prompt  string
+	header  string
+	items   []string
+	multi   bool
+	query   []rune
+	matches []int
+	cursor  int
+	offset  int
+	marked  map[int]bool
+
+	// actions turns ctrl-p, alt-a and alt-r into the instance picker's
+	// port-forward and back-to-accounts/roles keys, as with fzf --expect;
+	// action is the one that closed the picker.
+	actions bool
+	action  pickerAction
+}
+
+func newBuiltinPicker(lines []string, prompt, header string, multi bool) *builtinPicker {
+	p := &builtinPicker{
+		prompt: prompt,
+		header: header,
+		items:  append([]string(nil), lines...),
+		multi:  multi,
+		marked: map[int]bool{},
+	}
+	p.refilter()
+	return p
+}
+
+func (p *builtinPicker) add(lines []string) {
+	p.items = append(p.items, lines...)
+	p.refilter()
+}
+
+// replace swaps the list for lines, keeping the cursor on the same item.
+func (p *builtinPicker) replace(lines []string) {
+	current := ""
+	if p.cursor < len(p.matches) {
+		current = p.items[p.matches[p.cursor]]
+	}
+	p.items = append([]string(nil), lines...)
+	p.marked = map[int]bool{}
+	p.refilter()
+	for i, idx := range p.matches {
+		if p.items[idx] == current {
+			p.cursor = i
+			break
+		}
+	}
+}
+
+func (p *builtinPicker) refilter() {
+	terms := strings.Fields(string(p.query))
+	type scored struct {
+		idx   int
+		score int
+	}
+	var hits []scored
+	for i, item := range p.items {
+		total := 0
+		ok := true
+		for _, term := range terms {
+			score, matched := fuzzyScore(pickerDisplay(item), term)
+			if !matched {
+				ok = false
+				break
+			}
+			total += score
+		}
+		if ok {
+			hits = append(hits, scored{idx: i, score: total})
+		}
+	}
+	if len(terms) > 0 {
+		sort.SliceStable(hits, func(i, j int) bool {
+			return hits[i].score > hits[j].score
+		})
+	}
+	p.matches = p.matches[:0]
+	for _, h := range hits {
+		p.matches = append(p.matches, h.idx)
+	}
+	if p.cursor >= len(p.matches) {
+		p.cursor = len(p.matches) - 1
+	}
+	if p.cursor < 0 {
+		p.cursor = 0
+	}
+}
+
+// handleKey applies one key press. It reports done when the picker should
+// close and accepted when the user confirmed a selection.
+func (p *builtinPicker) handleKey(k pickerKey, pageSize int) (done, accepted bool) {
+	switch k.kind {
+	case keyRune:
+		p.query = append(p.query, k.r)
+		p.cursor = 0
+		p.refilter()
+	case keyBackspace:
+		if len(p.query) > 0 {
+			p.query = p.query[:len(p.query)-1]
+			p.refilter()
+		}
+	case keyClearQuery:
+		p.query = p.query[:0]
+		p.refilter()
+	case keyCtrlP:
+		if p.actions {
+			if len(p.matches) == 0 {
+				return false, false
+			}
+			p.action = pickerPortForward
+			return true, true
+		}
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case keyAltA, keyAltR:
+		if p.actions {
+			p.action = pickerBackToAccounts
+			if k.kind == keyAltR {
+				p.action = pickerBackToRoles
+			}
+			return true, true
+		}
+	case keyUp:
+		if p.cursor > 0 {
+			p.cursor--
+		}
+	case keyDown:
+		if p.cursor < len(p.matches)-1 {
+			p.cursor++
+		}
+	case keyPageUp:
+		p.cursor -= pageSize
+		if p.cursor < 0 {
+			p.cursor = 0
+		}
+	case keyPageDown:
+		p.cursor += pageSize
+		if p.cursor > len(p.matches)-1 {
+			p.cursor = len(p.matches) - 1
+		}
+		if p.cursor < 0 {
+			p.cursor = 0
+		}
+	case keyTab:
+		if p.multi && len(p.matches) > 0 {
+			idx := p.matches[p.cursor]
+			p.marked[idx] = !p.marked[idx]
+			if !p.marked[idx] {
+				delete(p.marked, idx)
+			}
+			if p.cursor < len(p.matches)-1 {
+				p.cursor++
+			}
+		}
+	case keyEnter:
+		// Like fzf, Enter does nothing while the query matches nothing.
+		if len(p.marked) == 0 && len(p.matches) == 0 {
+			return false, false
+		}
+		return true, true
+	case keyCancel:
+		return true, false
+	}
+	return false, false
+}
+
+func (p *builtinPicker) selection() []string {
+	if len(p.marked) > 0 {
+		idxs := make([]int, 0, len(p.marked))
+		for idx := range p.marked {
+			idxs = append(idxs, idx)
+		}
+		sort.Ints(idxs)
+		out := make([]string, 0, len(idxs))
+		for _, idx := range idxs {
+			out = append(out, p.items[idx])
+		}
+		return out
+	}
+	if len(p.matches) == 0 {
+		return nil
+	}
+	return []string{p.items[p.matches[p.cursor]]}
+}
+
+func (p *builtinPicker) render(width, height int) []byte {
+	var buf bytes.Buffer
+	buf.WriteString("\x1b[H")
+	writeRow := func(text string) {
+		buf.WriteString(truncateRunes(text, width))
+		buf.WriteString("\x1b[K\r\n")
+	}
+
+	writeRow(p.prompt + string(p.query))
+	used := 1
+	if p.header != "" {
+		buf.WriteString("\x1b[2m" + truncateRunes(p.header, width) + "\x1b[0m\x1b[K\r\n")
+		used++
+	}
+	count := fmt.Sprintf("  %d/%d", len(p.matches), len(p.items))
+	if p.multi && len(p.marked) > 0 {
+		count += fmt.Sprintf(" (%d marked)", len(p.marked))
+	}
+	writeRow(count)
+	used++
+
+	rows := height - used
+	if rows < 1 {
+		rows = 1
+	}
+	if p.cursor < p.offset {
+		p.offset = p.cursor
+	}
+	if p.cursor >= p.offset+rows {
+		p.offset = p.cursor - rows + 1
+	}
+	for i := p.offset; i < len(p.matches) && i < p.offset+rows; i++ {
+		idx := p.matches[i]
+		mark := "  "
+		if p.marked[idx] {
+			mark = " *"
+		}
+		line := truncateRunes(mark+pickerDisplay(p.items[idx]), width)
+		if i == p.cursor {
+			buf.WriteString("\x1b[7m" + line + "\x1b[0m\x1b[K\r\n")
+			continue
+		}
+		writeRow(line)
+	}
+	buf.WriteString("\x1b[J")
+	fmt.Fprintf(&buf, "\x1b[1;%dH", utf8.RuneCountInString(p.prompt)+len(p.query)+1)
+	return buf.Bytes()
+}
+
+func runBuiltinPicker(p *builtinPicker, feed <-chan pickerFeed) ([]string, bool, error) {
+	term, err := openRawTerminal()
+	if err != nil {
+		return nil, false, fmt.Errorf("open terminal for built-in picker: %w", err)
+	}
+	_, _ = term.write([]byte("\x1b[?1049h"))
+
+	var stop sync.WaitGroup
+	quit := make(chan struct{})
+	keys := make(chan []byte)
+	readErr := make(chan error, 1)
+	stop.Add(1)
+	go func() {
+		defer stop.Done()
+		buf := make([]byte, 64)
+		for {
+			select {
+			case <-quit:
+				return
+			default:
+			}
+			n, err := term.read(buf)
+			if err != nil {
+				readErr <- err
+				return
+			}
+			if n == 0 {
+				continue
+			}
+			chunk := append([]byte(nil), buf[:n]...)
+			select {
+			case keys <- chunk:
+			case <-quit:
+				return
+			}
+		}
+	}()
+	defer func() {
+		close(quit)
+		stop.Wait()
+		_, _ = term.write([]byte("\x1b[?1049l"))
+		_ = term.restore()
+	}()
+
+	for {
+		width, height := term.size()
+		_, _ = term.write(p.render(width, height))
+		select {
+		case chunk := <-keys:
+			for _, k := range decodePickerKeys(chunk) {
+				done, accepted := p.handleKey(k, height-3)
+				if done {
+					if !accepted {
+						return nil, false, nil
+					}
+					return p.selection(), true, nil
+				}
+			}
+		case update, ok := <-feed:
+			if !ok {
+				feed = nil
+				continue
+			}
+			if update.Header != "" {
+				p.header = update.Header
+			}
+			if update.Replace {
+				p.replace(update.Lines)
+			} else if len(update.Lines) > 0 {
+				p.add(update.Lines)
+			}
+		case err := <-readErr:
+			return nil, false, err
+		}
+	}
+}
+
+func decodePickerKeys(chunk []byte) []pickerKey {
+	var keys []pickerKey
+	for len(chunk) > 0 {
+		switch {
+		case bytes.HasPrefix(chunk, []byte("\x1b[A")), bytes.HasPrefix(chunk, []byte("\x1bOA")):
+			keys = append(keys, pickerKey{kind: keyUp})
+			chunk = chunk[3:]
+			continue
+		case bytes.HasPrefix(chunk, []byte("\x1b[B")), bytes.HasPrefix(chunk, []byte("\x1bOB")):
+			keys = append(keys, pickerKey{kind: keyDown})
+			chunk = chunk[3:]
+			continue
+		case bytes.HasPrefix(chunk, []byte("\x1b[5~")):
+			keys = append(keys, pickerKey{kind: keyPageUp})
+			chunk = chunk[4:]
+			continue
+		case bytes.HasPrefix(chunk, []byte("\x1b[6~")):
+			keys = append(keys, pickerKey{kind: keyPageDown})
+			chunk = chunk[4:]
+			continue
+		case bytes.HasPrefix(chunk, []byte("\x1ba")):
+			keys = append(keys, pickerKey{kind: keyAltA})
+			chunk = chunk[2:]
+			continue
+		case bytes.HasPrefix(chunk, []byte("\x1br")):
+			keys = append(keys, pickerKey{kind: keyAltR})
+			chunk = chunk[2:]
+			continue
+		case chunk[0] == 0x1b && len(chunk) > 1 && chunk[1] == '[':
+			// Unknown CSI sequence: skip to its final byte.
+			i := 2
+			for i < len(chunk) && (chunk[i] < 0x40 || chunk[i] > 0x7e) {
+				i++
+			}
+			if i < len(chunk) {
+				i++
+			}
+			chunk = chunk[i:]
+			continue
+		}
+
+		b := chunk[0]
+		switch b {
+		case 0x1b, 0x03, 0x07:
+			keys = append(keys, pickerKey{kind: keyCancel})
+		case '\r', '\n':
+			keys = append(keys, pickerKey{kind: keyEnter})
+		case 0x7f, 0x08:
+			keys = append(keys, pickerKey{kind: keyBackspace})
+		case '\t':
+			keys = append(keys, pickerKey{kind: keyTab})
+		case 0x10:
+			keys = append(keys, pickerKey{kind: keyCtrlP})
+		case 0x0b:
+			keys = append(keys, pickerKey{kind: keyUp})
+		case 0x0e:
+			keys = append(keys, pickerKey{kind: keyDown})
+		case 0x15:
+			keys = append(keys, pickerKey{kind: keyClearQuery})
+		default:
+			r, size := utf8.DecodeRune(chunk)
+			if r != utf8.RuneError && unicode.IsPrint(r) {
+				keys = append(keys, pickerKey{kind: keyRune, r: r})
+			}
+			chunk = chunk[size:]
+			continue
+		}
+		chunk = chunk[1:]
+	}
+	return keys
+}
+
+// fuzzyScore reports whether pattern matches text as a subsequence and how
+// well: consecutive runs and matches at word starts score higher. Matching is
+// case-insensitive unless the pattern contains an upper-case letter.
+func fuzzyScore(text, pattern string) (int, bool) {
+	if pattern == "" {
+		return 0, true
+	}
+	if strings.ToLower(pattern) == pattern {
+		text = strings.ToLower(text)
+	}
+	t := []rune(text)
+	pat := []rune(pattern)
+
+	best := -1
+	for start := range t {
+		if t[start] != pat[0] {
+			continue
+		}
+		score := 0
+		pi := 0
+		prev := -1
+		for i := start; i < len(t) && pi < len(pat); i++ {
+			if t[i] != pat[pi] {
+				continue
+			}
+			score += 16
+			if prev >= 0 && i == prev+1 {
+				score += 12
+			} else if prev >= 0 {
+				score -= min(i-prev-1, 8)
+			}
+			if i == 0 || isWordBoundary(t[i-1]) {
+				score += 10
+			}
+			prev = i
+			pi++
+		}
+		if pi == len(pat) && score > best {
+			best = score
+		}
+	}
+	if best < 0 {
+		return 0, false
+	}
+	return best, true
+}
+
+func isWordBoundary(r rune) bool {
+	return unicode.IsSpace(r) || strings.ContainsRune("|-_./:,=", r)
+}
+
+// pickerDisplay strips the hidden preview key added by fzfKeyedLine and
+// any color codes, which would break truncation and the cursor highlight.
+func pickerDisplay(line string) string {
+	if idx := strings.IndexByte(line, '\t'); idx >= 0 {
+		line = line[:idx]
+	}
+	return stripANSI(line)
+}
+
+func truncateRunes(s string, width int) string {
+	if width <= 0 || utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
+
+func pickLineBuiltin(lines []string, prompt string, preview bool) (string, bool, error) {
+	selected, ok, err := runBuiltinPicker(newBuiltinPicker(lines, prompt, "", false), nil)
+	if err != nil || !ok || len(selected) == 0 {
+		return "", false, err
+	}
+	return selected[0], true, nil
+}
+
+func pickLinesBuiltin(lines []string, prompt string, preview bool) ([]string, bool, error) {
+	p := newBuiltinPicker(lines, prompt, multiSelectHeader, true)
+	return runBuiltinPicker(p, nil)
+}
+
+func pickWithBuiltin(opts Options, initial []instanceCandidate, progress scanResult, updates <-chan scanResult, _ *reloadSpec) (*instanceCandidate, pickerAction, error) {
+	var mu sync.Mutex
+	lookup := make(map[string]instanceCandidate, len(initial))
+	for _, c := range initial {
+		lookup[opts.instanceLine(c)] = c
+	}
+
+	feed := make(chan pickerFeed)
+	quit := make(chan struct{})
+	defer close(quit)
+	go func() {
+		defer close(feed)
+		count := len(initial)
+		ranked := rankedCandidates{opts: opts, items: append([]instanceCandidate(nil), initial...)}
+		for r := range updates {
+			var batch []string
+			mu.Lock()
+			for _, c := range r.Candidates {
+				line := opts.instanceLine(c)
+				lookup[line] = c
+				batch = append(batch, line)
+			}
+			mu.Unlock()
+			count += len(r.Candidates)
+			if r.Total > 0 {
+				progress = r
+			}
+			update := pickerFeed{Lines: batch, Header: builtinInstanceHeader(scanProgressHeader(progress, count))}
+			if ranked.add(r.Candidates) {
+				update.Lines, update.Replace = opts.instancePickerLines(ranked.items), true
+			}
+			select {
+			case feed <- update:
+			case <-quit:
+				return
+			}
+		}
+	}()
+
+	p := newBuiltinPicker(opts.instancePickerLines(initial), "Select EC2 instance > ", builtinInstanceHeader(scanProgressHeader(progress, len(initial))), false)
+	p.actions = true
+	selected, ok, err := runBuiltinPicker(p, feed)
+	if err != nil || !ok {
+		return nil, pickerConnect, err
+	}
+	if p.action == pickerBackToAccounts || p.action == pickerBackToRoles {
+		return nil, p.action, nil
+	}
+	if len(selected) == 0 {
+		return nil, pickerConnect, nil
+	}
+	if selected[0] == fzfBackOption {
+		return nil, pickerBack, nil
+	}
+	mu.Lock()
+	chosen, found := lookup[selected[0]]
+	mu.Unlock()
+	if !found {
+		return nil, pickerConnect, fmt.Errorf("selected value not found in lookup")
+	}
+	return &chosen, p.action, nil
+}
+
+// builtinInstanceHeader puts the action keys on the status row, since the
+// built-in picker has a one-line header.
+func builtinInstanceHeader(status string) string {
+	return strings.ReplaceAll(fzfInstanceHeader(status, false), "\n", " | ")
+}
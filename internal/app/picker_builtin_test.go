@@ -0,0 +1,137 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestFuzzyScoreMatchesSubsequence(t *testing.T) {
+	if _, ok := fuzzyScore("prod-payments | 111 | Admin", "ppay"); !ok {
+		t.Fatal("expected subsequence match")
+	}
+	if _, ok := fuzzyScore("staging | 222", "prod"); ok {
+		t.Fatal("expected no match")
+	}
+	if _, ok := fuzzyScore("Prod", "prod"); !ok {
+		t.Fatal("expected lower-case pattern to match case-insensitively")
+	}
+	if _, ok := fuzzyScore("prod", "Prod"); ok {
+		t.Fatal("expected upper-case pattern to match case-sensitively")
+	}
+	contiguous, _ := fuzzyScore("web-api", "web")
+	scattered, _ := fuzzyScore("w-e-b", "web")
+	if contiguous <= scattered {
+		t.Fatalf("expected contiguous match to score higher: %d <= %d", contiguous, scattered)
+	}
+}
+
+func TestBuiltinPickerFiltersAndSelects(t *testing.T) {
+	p := newBuiltinPicker([]string{fzfBackOption, "alpha | 111\taccount:111", "beta | 222\taccount:222"}, "> ", "", false)
+	for _, r := range "beta" {
+		p.handleKey(pickerKey{kind: keyRune, r: r}, 10)
+	}
+	if len(p.matches) != 1 {
+		t.Fatalf("expected one match, got %d", len(p.matches))
+	}
+	done, accepted := p.handleKey(pickerKey{kind: keyEnter}, 10)
+	if !done || !accepted {
+		t.Fatal("expected enter to accept")
+	}
+	if got := p.selection(); !reflect.DeepEqual(got, []string{"beta | 222\taccount:222"}) {
+		t.Fatalf("unexpected selection: %v", got)
+	}
+}
+
+func TestBuiltinPickerDoesNotMatchHiddenPreviewKey(t *testing.T) {
+	p := newBuiltinPicker([]string{"alpha\taccount:999"}, "> ", "", false)
+	for _, r := range "999" {
+		p.handleKey(pickerKey{kind: keyRune, r: r}, 10)
+	}
+	if len(p.matches) != 0 {
+		t.Fatal("expected hidden preview key to be ignored when matching")
+	}
+}
+
+func TestBuiltinPickerMultiSelect(t *testing.T) {
+	p := newBuiltinPicker([]string{"one", "two", "three"}, "> ", "", true)
+	p.handleKey(pickerKey{kind: keyTab}, 10)
+	p.handleKey(pickerKey{kind: keyDown}, 10)
+	p.handleKey(pickerKey{kind: keyTab}, 10)
+	p.handleKey(pickerKey{kind: keyEnter}, 10)
+	if got := p.selection(); !reflect.DeepEqual(got, []string{"one", "three"}) {
+		t.Fatalf("unexpected multi selection: %v", got)
+	}
+}
+
+func TestBuiltinPickerReplaceKeepsCursorOnItem(t *testing.T) {
+	p := newBuiltinPicker([]string{"back", "a", "b"}, "> ", "", false)
+	p.cursor = 2
+	p.replace([]string{"back", "late", "a", "b"})
+	if got := p.selection(); len(got) != 1 || got[0] != "b" {
+		t.Fatalf("expected the cursor to stay on b, got %v", got)
+	}
+}
+
+func TestBuiltinPickerCancel(t *testing.T) {
+	p := newBuiltinPicker([]string{"one"}, "> ", "", false)
+	done, accepted := p.handleKey(pickerKey{kind: keyCancel}, 10)
+	if !done || accepted {
+		t.Fatalf("expected cancel to close without accepting, done=%t accepted=%t", done, accepted)
+	}
+}
+
+func TestBuiltinPickerIgnoresEnterWithoutMatches(t *testing.T) {
+	p := newBuiltinPicker([]string{"one"}, "> ", "", false)
+	p.handleKey(pickerKey{kind: keyRune, r: 'z'}, 10)
+	if done, _ := p.handleKey(pickerKey{kind: keyEnter}, 10); done {
+		t.Fatal("expected enter with no matches to keep the picker open")
+	}
+}
+
+func TestBuiltinPickerActionKeys(t *testing.T) {
+	cases := []struct {
+		key  pickerKeyKind
+		want pickerAction
+	}{
+		{keyCtrlP, pickerPortForward},
+		{keyAltA, pickerBackToAccounts},
+		{keyAltR, pickerBackToRoles},
+	}
+	for _, tc := range cases {
+		p := newBuiltinPicker([]string{"one", "two"}, "> ", "", false)
+		p.actions = true
+		done, accepted := p.handleKey(pickerKey{kind: tc.key}, 10)
+		if !done || !accepted || p.action != tc.want {
+			t.Fatalf("key %d: expected action %d, got done=%t accepted=%t action=%d", tc.key, tc.want, done, accepted, p.action)
+		}
+	}
+
+	p := newBuiltinPicker([]string{"one", "two"}, "> ", "", false)
+	p.cursor = 1
+	if done, _ := p.handleKey(pickerKey{kind: keyCtrlP}, 10); done || p.cursor != 0 {
+		t.Fatalf("expected ctrl-p to move up outside the instance picker, done=%t cursor=%d", done, p.cursor)
+	}
+}
+
+func TestDecodePickerKeys(t *testing.T) {
+	got := decodePickerKeys([]byte("a\x1b[A\x1b[B\r\x7f\x10\x1ba\x1br\x1b"))
+	want := []pickerKeyKind{keyRune, keyUp, keyDown, keyEnter, keyBackspace, keyCtrlP, keyAltA, keyAltR, keyCancel}
+	if len(got) != len(want) {
+		t.Fatalf("expected %d keys, got %+v", len(want), got)
+	}
+	for i, k := range got {
+		if k.kind != want[i] {
+			t.Fatalf("key %d: expected kind %d, got %d", i, want[i], k.kind)
+		}
+	}
+}
+
+func TestResolvePickerFallsBackToBuiltinWithoutFZF(t *testing.T) {
+	t.Setenv("PATH", t.TempDir())
+	if got := resolvePicker(Options{Picker: "auto"}); got != "builtin" {
+		t.Fatalf("expected builtin fallback, got %q", got)
+	}
+	if got := resolvePicker(Options{Picker: "fzf"}); got != "fzf" {
+		t.Fatalf("expected explicit fzf to be kept, got %q", got)
+	}
+}
@@ -0,0 +1,164 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"strings"
+)
+
+const portForwardDocument = "AWS-StartPortForwardingSession"
+
+type userConfigPolicy struct {
+	AllowAccounts    []string `yaml:"allow_accounts"`
+	DenyAccounts     []string `yaml:"deny_accounts"`
+	DenyRoles        []string `yaml:"deny_roles"`
+	ReadOnly         *bool    `yaml:"read_only"`
+	AllowedDocuments []string `yaml:"allowed_documents"`
+}
+
+// accessPolicy hides accounts and roles before any picker is shown and, in
+// read-only mode, limits which SSM documents sessions may use. It comes from
+// config files only, so the system file can lock it.
+type accessPolicy struct {
+	allowAccounts    []string
+	denyAccounts     []string
+	denyRoles        []string
+	readOnly         bool
+	allowedDocuments []string
+}
+
+func parsePolicy(p userConfigPolicy) (accessPolicy, error) {
+	globs := func(key string, values []string) ([]string, error) {
+		var out []string
+		for _, v := range values {
+			v = strings.TrimSpace(v)
+			if _, err := path.Match(strings.ToLower(v), ""); err != nil || v == "" {
+				return nil, fmt.Errorf("invalid config value policy.%s: %q is not a glob", key, v)
+			}
+			out = append(out, v)
+		}
+		return out, nil
+	}
+	var out accessPolicy
+	var err error
+	if out.allowAccounts, err = globs("allow_accounts", p.AllowAccounts); err != nil {
+		return accessPolicy{}, err
+	}
+	if out.denyAccounts, err = globs("deny_accounts", p.DenyAccounts); err != nil {
+		return accessPolicy{}, err
+	}
+	if out.denyRoles, err = globs("deny_roles", p.DenyRoles); err != nil {
+		return accessPolicy{}, err
+	}
+	if out.allowedDocuments, err = globs("allowed_documents", p.AllowedDocuments); err != nil {
+		return accessPolicy{}, err
+	}
+	out.readOnly = p.ReadOnly != nil && *p.ReadOnly
+	return out, nil
+}
+
+// config turns the policy back into its config form, for handing it to a
+// child process.
+func (p accessPolicy) config() userConfigPolicy {
+	out := userConfigPolicy{
+		AllowAccounts:    p.allowAccounts,
+		DenyAccounts:     p.denyAccounts,
+		DenyRoles:        p.denyRoles,
+		AllowedDocuments: p.allowedDocuments,
+	}
+	if p.readOnly {
+		readOnly := true
+		out.ReadOnly = &readOnly
+	}
+	return out
+}
+
+func (p accessPolicy) allowsAccount(accountID, accountName string) bool {
+	for _, match := range p.denyAccounts {
+		if accountMatches(match, accountID, accountName) {
+			return false
+		}
+	}
+	if len(p.allowAccounts) == 0 {
+		return true
+	}
+	for _, match := range p.allowAccounts {
+		if accountMatches(match, accountID, accountName) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p accessPolicy) allowsRole(roleName string) bool {
+	for _, match := range p.denyRoles {
+		if ok, _ := path.Match(strings.ToLower(match), strings.ToLower(roleName)); ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (p accessPolicy) allowsTarget(t roleTarget) bool {
+	return p.allowsAccount(t.AccountID, t.AccountName) && p.allowsRole(t.RoleName)
+}
+
+func (p accessPolicy) filterAccounts(accounts []ssoAccountsResponse) []ssoAccountsResponse {
+	var out []ssoAccountsResponse
+	for _, a := range accounts {
+		if len(a.AccountList) == 0 {
+			continue
+		}
+		if acct := a.AccountList[0]; p.allowsAccount(acct.AccountID, acct.AccountName) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+func (p accessPolicy) filterTargets(targets []roleTarget) []roleTarget {
+	var out []roleTarget
+	for _, t := range targets {
+		if p.allowsTarget(t) {
+			out = append(out, t)
+		}
+	}
+	return out
+}
+
+func (p accessPolicy) allowsDocument(document string) bool {
+	for _, match := range p.allowedDocuments {
+		if ok, _ := path.Match(match, document); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// sessionDocument returns the SSM document a session should use. Outside
+// read-only mode that is whatever was configured; in read-only mode it must
+// be allow-listed, and an unset document falls back to the first allowed
+// one so the default shell is never used.
+func (p accessPolicy) sessionDocument(document string) (string, error) {
+	if !p.readOnly {
+		return document, nil
+	}
+	if document == "" {
+		if len(p.allowedDocuments) == 0 || strings.ContainsAny(p.allowedDocuments[0], "*?[") {
+			return "", errors.New("read-only mode: set --document to one of policy.allowed_documents")
+		}
+		return p.allowedDocuments[0], nil
+	}
+	if !p.allowsDocument(document) {
+		return "", fmt.Errorf("read-only mode: session document %q is not in policy.allowed_documents (%s)", document, strings.Join(p.allowedDocuments, ", "))
+	}
+	return document, nil
+}
+
+func (p accessPolicy) checkPortForward() error {
+	if p.readOnly && !p.allowsDocument(portForwardDocument) {
+		return fmt.Errorf("read-only mode: port forwarding needs %s in policy.allowed_documents", portForwardDocument)
+	}
+	return nil
+}
@@ -0,0 +1,57 @@
+package app
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPolicyAllowAndDenyAccounts(t *testing.T) {
+	policy, err := parsePolicy(userConfigPolicy{
+		AllowAccounts: []string{"prod-*", "dev-*", "123456789012"},
+		DenyAccounts:  []string{"*-sandbox"},
+	})
+	if err != nil {
+		t.Fatalf("parsePolicy: %v", err)
+	}
+	accounts := []ssoAccountsResponse{
+		testAccount("1", "Prod-Payments"),
+		testAccount("2", "dev-sandbox"),
+		testAccount("3", "staging"),
+		testAccount("123456789012", "shared"),
+	}
+	var names []string
+	for _, a := range policy.filterAccounts(accounts) {
+		names = append(names, a.AccountList[0].AccountName)
+	}
+	if strings.Join(names, ",") != "Prod-Payments,shared" {
+		t.Fatalf("expected allowed accounts minus denied ones, got %v", names)
+	}
+
+	if _, err := parsePolicy(userConfigPolicy{DenyRoles: []string{"Admin["}}); err == nil || !strings.Contains(err.Error(), "policy.deny_roles") {
+		t.Fatalf("expected an invalid glob error, got %v", err)
+	}
+}
+
+func TestPolicyReadOnlySessionDocuments(t *testing.T) {
+	readOnly := true
+	policy, err := parsePolicy(userConfigPolicy{ReadOnly: &readOnly, AllowedDocuments: []string{"SessionManager-ReadOnly", "Team-*"}})
+	if err != nil {
+		t.Fatalf("parsePolicy: %v", err)
+	}
+	if doc, err := policy.sessionDocument(""); err != nil || doc != "SessionManager-ReadOnly" {
+		t.Fatalf("expected the first allowed document by default, got %q, %v", doc, err)
+	}
+	if doc, err := policy.sessionDocument("Team-Logs"); err != nil || doc != "Team-Logs" {
+		t.Fatalf("expected a glob-allowed document, got %q, %v", doc, err)
+	}
+	if _, err := policy.sessionDocument("AWS-StartInteractiveCommand"); err == nil {
+		t.Fatal("expected a document outside the allow list to be refused")
+	}
+	if err := policy.checkPortForward(); err == nil {
+		t.Fatal("expected port forwarding to be refused in read-only mode")
+	}
+
+	if doc, err := (accessPolicy{}).sessionDocument("Anything"); err != nil || doc != "Anything" {
+		t.Fatalf("expected documents to pass through outside read-only mode, got %q, %v", doc, err)
+	}
+}
@@ -0,0 +1,256 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// previewCommandFor is the shell command fzf runs for the highlighted line;
+// it is empty when previews are unavailable (for example with the cache off).
+func previewCommandFor(opts Options) string {
+	if !opts.CacheEnabled || strings.TrimSpace(opts.CacheDir) == "" {
+		return ""
+	}
+	exe, err := os.Executable()
+	if err != nil {
+		return ""
+	}
+	return fmt.Sprintf("%s __preview --profile %s --cache-dir %s {2}", shellQuote(exe), shellQuote(opts.Profile), shellQuote(opts.CacheDir))
+}
+
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+func previewKeyAccount(accountID string) string {
+	return "account:" + accountID
+}
+
+func previewKeyRole(t roleTarget) string {
+	return "role:" + t.AccountID + ":" + t.RoleName
+}
+
+func previewKeyInstance(c instanceCandidate) string {
+	return "instance:" + c.AccountID + ":" + c.RoleName + ":" + c.Region + ":" + c.InstanceID
+}
+
+func Preview(opts Options, key string) error {
+	if err := configureAtRest(opts); err != nil {
+		return err
+	}
+	return renderPreview(os.Stdout, opts, key)
+}
+
+func renderPreview(w io.Writer, opts Options, key string) error {
+	opts.CacheEnabled = true
+	store := newCacheStore(opts)
+
+	parts := strings.Split(strings.TrimSpace(key), ":")
+	switch {
+	case len(parts) == 2 && parts[0] == "account":
+		return previewAccount(w, opts, store, parts[1])
+	case len(parts) == 3 && parts[0] == "role":
+		return previewRole(w, opts, store, parts[1], parts[2])
+	case len(parts) == 5 && parts[0] == "instance":
+		return previewInstance(w, opts, store, parts[1], parts[2], parts[3], parts[4])
+	case strings.TrimSpace(key) == "":
+		return nil
+	default:
+		return fmt.Errorf("unknown preview key %q", key)
+	}
+}
+
+// previewAccount, like previewInstance, reads only the entries it needs:
+// the account list, the account's roles and their instance lists.
+func previewAccount(w io.Writer, opts Options, store *cacheStore, accountID string) error {
+	ssoRegion := previewSSORegion(opts.Profile)
+	var accounts []ssoAccountsResponse
+	readCachedPayload(store, opts.Profile, cacheKeyAccounts(opts.Profile, ssoRegion), &accounts)
+	for _, a := range accounts {
+		if len(a.AccountList) == 0 || a.AccountList[0].AccountID != accountID {
+			continue
+		}
+		acct := a.AccountList[0]
+		fmt.Fprintf(w, "Account:    %s (%s)\n", acct.AccountName, acct.AccountID)
+		fmt.Fprintf(w, "Email:      %s\n", acct.EmailAddress)
+		break
+	}
+	fmt.Fprintf(w, "Last used:  %s\n", lastUsedSummary(opts, accountID, ""))
+	var targets []roleTarget
+	readCachedPayload(store, opts.Profile, cacheKeyRoles(opts.Profile, ssoRegion, accountID), &targets)
+	roles := make([]string, 0, len(targets))
+	for _, t := range targets {
+		roles = append(roles, t.RoleName)
+	}
+	writeInstanceCounts(w, opts, store, accountID, roles)
+	return nil
+}
+
+func previewRole(w io.Writer, opts Options, store *cacheStore, accountID, roleName string) error {
+	fmt.Fprintf(w, "Account:    %s\n", accountID)
+	fmt.Fprintf(w, "Role:       %s\n", roleName)
+	fmt.Fprintf(w, "Last used:  %s\n", lastUsedSummary(opts, accountID, roleName))
+	writeInstanceCounts(w, opts, store, accountID, []string{roleName})
+	return nil
+}
+
+// previewSSORegion is the SSO region account and role entries were cached
+// under, resolved from the profile the same way Run does.
+func previewSSORegion(profile string) string {
+	cfg, _ := readProfileConfig(profile)
+	return resolveSSORegion(cfg)
+}
+
+// readCachedPayload decodes one cache entry into out and reports whether it
+// was there.
+func readCachedPayload(store *cacheStore, profile, key string, out any) bool {
+	env, err := store.readEnvelope(profile, key)
+	if err != nil || env == nil {
+		return false
+	}
+	return json.Unmarshal(env.Payload, out) == nil
+}
+
+// previewInstance reads only the two cache entries (running and all states)
+// the instance can be in, since it runs for every highlighted line.
+func previewInstance(w io.Writer, opts Options, store *cacheStore, accountID, roleName, region, instanceID string) error {
+	var found *instanceCandidate
+	var foundAt time.Time
+	for _, runningOnly := range []bool{false, true} {
+		env, err := store.readEnvelope(opts.Profile, cacheKeyInstances(opts.Profile, accountID, roleName, region, runningOnly))
+		if err != nil || env == nil {
+			continue
+		}
+		var cands []instanceCandidate
+		if err := json.Unmarshal(env.Payload, &cands); err != nil {
+			continue
+		}
+		for i := range cands {
+			if cands[i].InstanceID == instanceID && (found == nil || env.CreatedAt.After(foundAt)) {
+				found = &cands[i]
+				foundAt = env.CreatedAt
+			}
+		}
+	}
+	if found == nil {
+		return errors.New("no cached details for this instance")
+	}
+
+	c := found
+	fmt.Fprintf(w, "Instance:        %s (%s)\n", c.InstanceID, c.Name)
+	fmt.Fprintf(w, "Account:         %s (%s)\n", c.AccountName, c.AccountID)
+	fmt.Fprintf(w, "Role:            %s\n", c.RoleName)
+	fmt.Fprintf(w, "Region:          %s (%s)\n", c.Region, regionDisplayName(c.Region))
+	fmt.Fprintf(w, "AZ:              %s\n", orDash(c.AvailabilityZone))
+	fmt.Fprintf(w, "Type:            %s\n", orDash(c.InstanceType))
+	fmt.Fprintf(w, "State:           %s\n", orDash(c.State))
+	fmt.Fprintf(w, "Platform:        %s\n", orDash(c.Platform))
+	fmt.Fprintf(w, "Launch time:     %s\n", orDash(c.LaunchTime))
+	fmt.Fprintf(w, "Private IP:      %s\n", orDash(c.PrivateIP))
+	fmt.Fprintf(w, "IAM profile:     %s\n", orDash(c.IAMInstanceProfile))
+	fmt.Fprintf(w, "SSM ping:        %s\n", orDash(c.PingStatus))
+	fmt.Fprintf(w, "Security groups: %s\n", orDash(strings.Join(c.SecurityGroups, ", ")))
+	fmt.Fprintf(w, "Cached:          %s ago\n", time.Since(foundAt).Round(time.Second))
+	if len(c.Tags) > 0 {
+		keys := make([]string, 0, len(c.Tags))
+		for k := range c.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		fmt.Fprintln(w, "Tags:")
+		for _, k := range keys {
+			fmt.Fprintf(w, "  %s = %s\n", k, c.Tags[k])
+		}
+	}
+	return nil
+}
+
+// writeInstanceCounts looks up the instance lists of each role in every
+// known region, so the preview never has to read the whole cache.
+func writeInstanceCounts(w io.Writer, opts Options, store *cacheStore, accountID string, roles []string) {
+	regions := make([]string, 0, len(awsRegionNames))
+	for region := range awsRegionNames {
+		regions = append(regions, region)
+	}
+	var lines []string
+	total := 0
+	for _, role := range roles {
+		for _, region := range regions {
+			for _, runningOnly := range []bool{false, true} {
+				env, err := store.readEnvelope(opts.Profile, cacheKeyInstances(opts.Profile, accountID, role, region, runningOnly))
+				if err != nil || env == nil {
+					continue
+				}
+				var cands []instanceCandidate
+				if err := json.Unmarshal(env.Payload, &cands); err != nil {
+					continue
+				}
+				scope := "all states"
+				if runningOnly {
+					scope = "running"
+				}
+				lines = append(lines, fmt.Sprintf("  %s / %s: %d (%s, %s ago)", role, region, len(cands), scope, time.Since(env.CreatedAt).Round(time.Second)))
+				total += len(cands)
+			}
+		}
+	}
+	if len(lines) == 0 {
+		fmt.Fprintln(w, "Cached instances: none")
+		return
+	}
+	sort.Strings(lines)
+	fmt.Fprintf(w, "Cached instances: %d\n", total)
+	for _, line := range lines {
+		fmt.Fprintln(w, line)
+	}
+}
+
+// lastUsedSummary describes the most recent connection to the account (or
+// account and role) from the connection history, with its use count.
+func lastUsedSummary(opts Options, accountID, roleName string) string {
+	recent, err := loadRecentTargets(opts.CacheDir)
+	if err != nil {
+		return "unknown"
+	}
+	p, ok := recent.Profiles[opts.Profile]
+	if !ok {
+		return "never"
+	}
+	scope, when := recentScope{}, ""
+	for _, h := range p.History {
+		if h.Scope.AccountID == accountID && (roleName == "" || h.Scope.RoleName == roleName) {
+			scope, when = h.Scope, h.LastUsedAt
+			break
+		}
+	}
+	if when == "" && p.LastScope.AccountID == accountID && (roleName == "" || p.LastScope.RoleName == roleName) {
+		scope, when = p.LastScope, p.UpdatedAt
+	}
+	if when == "" {
+		return "never"
+	}
+	usageKey := usageKeyAccount(accountID)
+	if roleName != "" {
+		usageKey = usageKeyRole(accountID, roleName)
+	}
+	switch count := p.Usage[usageKey].Count; {
+	case count == 1:
+		return fmt.Sprintf("%s (%s, %s; 1 connection)", when, scope.RoleName, scope.Region)
+	case count > 1:
+		return fmt.Sprintf("%s (%s, %s; %d connections)", when, scope.RoleName, scope.Region, count)
+	}
+	return fmt.Sprintf("%s (%s, %s)", when, scope.RoleName, scope.Region)
+}
+
+func orDash(value string) string {
+	if strings.TrimSpace(value) == "" {
+		return "-"
+	}
+	return value
+}
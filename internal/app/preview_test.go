@@ -0,0 +1,111 @@
+package app
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRenderPreviewInstanceFromCache(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	cand := instanceCandidate{
+		DisplayLine:      "line",
+		ProfileName:      "swamp-1",
+		Region:           "eu-west-1",
+		InstanceID:       "i-abc",
+		AccountID:        "111",
+		AccountName:      "acct",
+		RoleName:         "Admin",
+		Name:             "web-1",
+		InstanceType:     "t3.micro",
+		AvailabilityZone: "eu-west-1a",
+		SecurityGroups:   []string{"sg-1 (web)"},
+		PingStatus:       "Online",
+		Tags:             map[string]string{"Team": "payments", "Name": "web-1"},
+	}
+	key := cacheKeyInstances(opts.Profile, "111", "Admin", "eu-west-1", true)
+	if err := opts.cacheStore.writeJSON(opts.Profile, key, time.Minute, []instanceCandidate{cand}); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := renderPreview(&buf, opts, previewKeyInstance(cand)); err != nil {
+		t.Fatalf("renderPreview failed: %v", err)
+	}
+	out := buf.String()
+	for _, want := range []string{"t3.micro", "eu-west-1a", "sg-1 (web)", "Online", "Team = payments"} {
+		if !strings.Contains(out, want) {
+			t.Fatalf("expected preview to contain %q, got:\n%s", want, out)
+		}
+	}
+}
+
+func TestRenderPreviewAccountCountsCachedInstances(t *testing.T) {
+	t.Setenv("HOME", t.TempDir())
+	opts := newTestCacheOptions(t, "balanced")
+	if err := opts.cacheStore.writeJSON(opts.Profile, cacheKeyAccounts(opts.Profile, "us-east-1"), time.Minute, []ssoAccountsResponse{testAccount("111", "acct")}); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+	if err := opts.cacheStore.writeJSON(opts.Profile, cacheKeyRoles(opts.Profile, "us-east-1", "111"), time.Minute, []roleTarget{{AccountID: "111", RoleName: "Admin"}}); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+	key := cacheKeyInstances(opts.Profile, "111", "Admin", "us-east-1", true)
+	if err := opts.cacheStore.writeJSON(opts.Profile, key, time.Minute, []instanceCandidate{{InstanceID: "i-1"}, {InstanceID: "i-2"}}); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+	other := cacheKeyInstances(opts.Profile, "222", "Admin", "us-east-1", true)
+	if err := opts.cacheStore.writeJSON(opts.Profile, other, time.Minute, []instanceCandidate{{InstanceID: "i-3"}}); err != nil {
+		t.Fatalf("writeJSON failed: %v", err)
+	}
+
+	var buf bytes.Buffer
+	if err := renderPreview(&buf, opts, previewKeyAccount("111")); err != nil {
+		t.Fatalf("renderPreview failed: %v", err)
+	}
+	out := buf.String()
+	if !strings.Contains(out, "acct (111)") || !strings.Contains(out, "Cached instances: 2") || !strings.Contains(out, "Admin / us-east-1: 2") {
+		t.Fatalf("unexpected account preview:\n%s", out)
+	}
+}
+
+func TestRenderPreviewRoleUsesHistoryAndUsage(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	for _, scope := range []recentScope{
+		{AccountID: "111", RoleName: "Admin", Region: "eu-west-1"},
+		{AccountID: "111", RoleName: "Admin", Region: "eu-west-1"},
+		{AccountID: "222", RoleName: "ReadOnly", Region: "us-east-1"},
+	} {
+		if err := saveRecentTargets(opts.CacheDir, opts.Profile, scope, recentInstance{InstanceID: "i-" + scope.AccountID}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := renderPreview(&buf, opts, previewKeyRole(roleTarget{AccountID: "111", RoleName: "Admin"})); err != nil {
+		t.Fatalf("renderPreview failed: %v", err)
+	}
+	if !strings.Contains(buf.String(), "(Admin, eu-west-1; 2 connections)") {
+		t.Fatalf("expected the last use from history, got:\n%s", buf.String())
+	}
+}
+
+func TestRenderPreviewRejectsUnknownKey(t *testing.T) {
+	opts := newTestCacheOptions(t, "balanced")
+	var buf bytes.Buffer
+	if err := renderPreview(&buf, opts, "bogus:1"); err == nil {
+		t.Fatal("expected unknown key error")
+	}
+}
+
+func TestFZFPreviewArgsOnlyWhenPreviewEnabled(t *testing.T) {
+	if got := fzfKeyedLine("line", "account:1"); got != "line\taccount:1" {
+		t.Fatalf("expected keyed line, got %q", got)
+	}
+	if got := strings.Join(Options{}.fzfPreviewArgs(), " "); strings.Contains(got, "--preview") {
+		t.Fatalf("expected no preview args without preview command, got %q", got)
+	}
+	if got := strings.Join(Options{previewCommand: "swamp __preview {2}"}.fzfPreviewArgs(), " "); !strings.Contains(got, "--preview swamp __preview {2}") {
+		t.Fatalf("expected preview args, got %q", got)
+	}
+}
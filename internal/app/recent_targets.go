@@ -1,7 +1,9 @@
 package app
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
@@ -9,7 +11,12 @@ import (
 	"time"
 )
 
-const recentTargetsFileName = "recent_targets.json"
+const (
+	recentTargetsFileName = "recent_targets.json"
+	recentTargetsVersion  = 2
+	recentHistoryLimit    = 50
+	recentUsageLimit      = 500
+)
 
 type recentTargetsFile struct {
 	Version  int                          `json:"version"`
@@ -20,6 +27,19 @@ type recentProfileData struct {
 	LastScope    recentScope    `json:"last_scope"`
 	LastInstance recentInstance `json:"last_instance"`
 	UpdatedAt    string         `json:"updated_at"`
+
+	// History holds past connections, most recent first.
+	History []recentHistoryEntry `json:"history,omitempty"`
+	// Usage counts connections per account, role, region and instance
+	// (see usageKeyAccount and friends) for frecency ordering.
+	Usage map[string]usageStat `json:"usage,omitempty"`
+}
+
+type recentHistoryEntry struct {
+	Scope      recentScope    `json:"scope"`
+	Instance   recentInstance `json:"instance"`
+	LastUsedAt string         `json:"last_used_at"`
+	UseCount   int            `json:"use_count"`
 }
 
 type recentScope struct {
@@ -41,43 +61,81 @@ func loadRecentTargets(cacheDir string) (recentTargetsFile, error) {
 	data, err := os.ReadFile(path)
 	if err != nil {
 		if os.IsNotExist(err) {
-			return recentTargetsFile{Version: 1, Profiles: map[string]recentProfileData{}}, nil
+			return newRecentTargetsFile(), nil
 		}
 		return recentTargetsFile{}, err
 	}
+	if data, err = openAtRest(data); err != nil {
+		return recentTargetsFile{}, fmt.Errorf("read %s: %w", path, err)
+	}
 	var out recentTargetsFile
 	if err := json.Unmarshal(data, &out); err != nil {
-		return recentTargetsFile{Version: 1, Profiles: map[string]recentProfileData{}}, nil
-	}
-	if out.Version == 0 {
-		out.Version = 1
+		return newRecentTargetsFile(), nil
 	}
 	if out.Profiles == nil {
 		out.Profiles = map[string]recentProfileData{}
 	}
+	if out.Version < recentTargetsVersion {
+		// Version 1 only kept the last target; seed history with it.
+		for name, p := range out.Profiles {
+			if len(p.History) == 0 && p.LastInstance.InstanceID != "" {
+				p.History = []recentHistoryEntry{{Scope: p.LastScope, Instance: p.LastInstance, LastUsedAt: p.UpdatedAt, UseCount: 1}}
+				out.Profiles[name] = p
+			}
+		}
+		out.Version = recentTargetsVersion
+	}
+	for name, p := range out.Profiles {
+		if p.Usage == nil && len(p.History) > 0 {
+			// Files written before usage tracking: rebuild it from history.
+			for i := len(p.History) - 1; i >= 0; i-- {
+				h := p.History[i]
+				for n := 0; n < h.UseCount; n++ {
+					p.Usage = recordUsage(p.Usage, h.Scope, h.Instance, h.LastUsedAt)
+				}
+			}
+			out.Profiles[name] = p
+		}
+	}
 	return out, nil
 }
 
+func newRecentTargetsFile() recentTargetsFile {
+	return recentTargetsFile{Version: recentTargetsVersion, Profiles: map[string]recentProfileData{}}
+}
+
 func saveRecentTargets(cacheDir, profile string, scope recentScope, inst recentInstance) error {
 	if strings.TrimSpace(cacheDir) == "" || strings.TrimSpace(profile) == "" {
 		return nil
 	}
+	// Several swamp processes (one per terminal tab) can finish at once;
+	// the lock keeps one from dropping the other's history update.
+	return withFileLock(filepath.Join(cacheDir, recentTargetsFileName), func() error {
+		return updateRecentTargets(cacheDir, profile, scope, inst)
+	})
+}
+
+func updateRecentTargets(cacheDir, profile string, scope recentScope, inst recentInstance) error {
 	all, err := loadRecentTargets(cacheDir)
 	if err != nil {
 		return err
 	}
-	if err := os.MkdirAll(cacheDir, 0o755); err != nil {
-		return err
-	}
+	now := time.Now().UTC().Format(time.RFC3339)
+	prev := all.Profiles[profile]
 	all.Profiles[profile] = recentProfileData{
 		LastScope:    scope,
 		LastInstance: inst,
-		UpdatedAt:    time.Now().UTC().Format(time.RFC3339),
+		UpdatedAt:    now,
+		History:      recordHistory(prev.History, scope, inst, now),
+		Usage:        recordUsage(prev.Usage, scope, inst, now),
 	}
 	content, err := json.MarshalIndent(all, "", "  ")
 	if err != nil {
 		return err
 	}
+	if content, err = sealAtRest(content); err != nil {
+		return err
+	}
 	tmp, err := os.CreateTemp(cacheDir, "recent-targets-*.tmp")
 	if err != nil {
 		return err
@@ -100,6 +158,46 @@ func saveRecentTargets(cacheDir, profile string, scope recentScope, inst recentI
 	return nil
 }
 
+// recordHistory moves the connection to the front of the history, bumping
+// its use count, and drops the oldest entries beyond recentHistoryLimit.
+func recordHistory(history []recentHistoryEntry, scope recentScope, inst recentInstance, now string) []recentHistoryEntry {
+	entry := recentHistoryEntry{Scope: scope, Instance: inst, LastUsedAt: now, UseCount: 1}
+	out := make([]recentHistoryEntry, 0, len(history)+1)
+	out = append(out, entry)
+	for _, h := range history {
+		if h.sameTarget(entry) {
+			out[0].UseCount += h.UseCount
+			continue
+		}
+		out = append(out, h)
+	}
+	if len(out) > recentHistoryLimit {
+		out = out[:recentHistoryLimit]
+	}
+	return out
+}
+
+func (h recentHistoryEntry) sameTarget(other recentHistoryEntry) bool {
+	return h.Scope.AccountID == other.Scope.AccountID &&
+		h.Scope.RoleName == other.Scope.RoleName &&
+		h.Instance.Region == other.Instance.Region &&
+		h.Instance.InstanceID == other.Instance.InstanceID
+}
+
+func (f recentTargetsFile) history(profile string) []recentHistoryEntry {
+	var out []recentHistoryEntry
+	for _, h := range f.Profiles[profile].History {
+		if strings.TrimSpace(h.Scope.AccountID) == "" ||
+			strings.TrimSpace(h.Scope.RoleName) == "" ||
+			strings.TrimSpace(h.Instance.InstanceID) == "" ||
+			strings.TrimSpace(h.Instance.Region) == "" {
+			continue
+		}
+		out = append(out, h)
+	}
+	return out
+}
+
 func (f recentTargetsFile) getLastScope(profile string) (recentScope, bool) {
 	p, ok := f.Profiles[profile]
 	if !ok {
@@ -125,27 +223,96 @@ func (f recentTargetsFile) getLastInstance(profile string) (recentScope, recentI
 	return p.LastScope, p.LastInstance, true
 }
 
-func tryLastConnection(opts Options, cfg profileConfig, recent recentTargetsFile, ssoRegion string) (bool, error) {
-	scope, inst, ok := recent.getLastInstance(opts.Profile)
-	if !ok {
-		fmt.Printf("No recent target found for profile %q; continuing interactively.\n", opts.Profile)
+func tryLastConnection(ctx context.Context, opts Options, cfg profileConfig, recent recentTargetsFile, ssoRegion string) (bool, error) {
+	n := opts.LastN
+	if n < 1 {
+		n = 1
+	}
+	history := recent.history(opts.Profile)
+	if len(history) < n {
+		if n == 1 {
+			fmt.Printf("No recent target found for profile %q; continuing interactively.\n", opts.Profile)
+		} else {
+			fmt.Printf("Only %d recent targets found for profile %q; continuing interactively.\n", len(history), opts.Profile)
+		}
 		return false, nil
 	}
+	entry := history[n-1]
+	return connectSavedTarget(ctx, opts, cfg, entry.Scope, entry.Instance, ssoRegion)
+}
+
+// selectHistoryEntry shows past connections for the profile, most recent
+// first, and returns the one the user picked.
+func selectHistoryEntry(opts Options, recent recentTargetsFile) (*recentHistoryEntry, error) {
+	history := recent.history(opts.Profile)
+	if len(history) == 0 {
+		fmt.Printf("No connection history for profile %q.\n", opts.Profile)
+		return nil, nil
+	}
+	lookup := make(map[string]recentHistoryEntry, len(history))
+	lines := make([]string, 0, len(history))
+	for _, h := range history {
+		line := historyLine(h)
+		lines = append(lines, line)
+		lookup[line] = h
+	}
+	selected, ok, err := opts.pickLine(lines, "Select past connection > ", false)
+	if err != nil || !ok {
+		return nil, err
+	}
+	chosen, found := lookup[selected]
+	if !found {
+		return nil, fmt.Errorf("selected connection not found")
+	}
+	return &chosen, nil
+}
+
+func historyLine(h recentHistoryEntry) string {
+	when := h.LastUsedAt
+	if t, err := time.Parse(time.RFC3339, h.LastUsedAt); err == nil {
+		when = t.Local().Format("2006-01-02 15:04")
+	}
+	target := h.Instance.DisplayLine
+	if target == "" {
+		target = h.Instance.InstanceID
+	}
+	return fmt.Sprintf("%s | %s | %s | %s | %s | used %dx", when, favoriteAccount(h.Scope), h.Scope.RoleName, h.Instance.Region, target, h.UseCount)
+}
+
+// connectSavedTarget checks that a saved instance is still reachable in
+// its scope and connects to it. It returns false when the caller should
+// continue interactively.
+func connectSavedTarget(ctx context.Context, opts Options, cfg profileConfig, scope recentScope, inst recentInstance, ssoRegion string) (bool, error) {
 	target := roleTarget{
 		AccountID:   scope.AccountID,
 		AccountName: scope.AccountName,
 		RoleName:    scope.RoleName,
 	}
+	if !opts.policy.allowsTarget(target) {
+		fmt.Printf("Saved target %s/%s is not allowed by policy; continuing interactively.\n", target.AccountID, target.RoleName)
+		return false, nil
+	}
+	opts = opts.forTarget(target)
+	// A document the policy refuses is a configuration error, not a sign
+	// that the saved target is gone.
+	if _, err := opts.policy.sessionDocument(opts.Document); err != nil {
+		return false, err
+	}
 	tmpConfigPath, profileNames, err := buildTempAWSConfigFn(cfg, []roleTarget{target})
 	if err != nil {
 		return false, fmt.Errorf("failed to build temporary AWS config: %w", err)
 	}
 	defer func() {
-		_ = removeFileFn(tmpConfigPath)
+		opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
 	}()
 
-	regions, err := discoverRegionsFn(opts, cfg, []roleTarget{target}, tmpConfigPath, profileNames, ssoRegion)
+	discoveryCtx, cancel := opts.discoveryContext(ctx)
+	regions, err := discoverRegionsFn(discoveryCtx, opts, cfg, []roleTarget{target}, tmpConfigPath, profileNames, ssoRegion)
+	cancel()
 	if err != nil {
+		if ctx.Err() != nil {
+			return false, context.Cause(ctx)
+		}
 		fmt.Printf("Saved target no longer available (%v); continuing interactively.\n", err)
 		return false, nil
 	}
@@ -154,7 +321,14 @@ func tryLastConnection(opts Options, cfg profileConfig, recent recentTargetsFile
 		return false, nil
 	}
 
-	candidates := scanAllInstancesFn(opts, tmpConfigPath, []roleTarget{target}, profileNames, []string{inst.Region}, opts.Workers, !opts.IncludeStopped)
+	candidates, err := scanAllInstancesFn(ctx, opts, tmpConfigPath, []roleTarget{target}, profileNames, []string{inst.Region}, opts.Workers, !opts.IncludeStopped)
+	if err != nil {
+		if ctx.Err() != nil {
+			return false, context.Cause(ctx)
+		}
+		fmt.Printf("Saved target scan did not finish (%v); continuing interactively.\n", err)
+		return false, nil
+	}
 	var selected *instanceCandidate
 	for i := range candidates {
 		if candidates[i].InstanceID == inst.InstanceID {
@@ -167,18 +341,35 @@ func tryLastConnection(opts Options, cfg profileConfig, recent recentTargetsFile
 		return false, nil
 	}
 
-	fmt.Printf("Starting SSM session to %s in %s (profile %s)\n", selected.InstanceID, selected.Region, selected.ProfileName)
-	if err := startSSMSessionFn(tmpConfigPath, selected.ProfileName, selected.Region, selected.InstanceID); err != nil {
+	if err := startSavedSession(opts, tmpConfigPath, scope, *selected); err != nil {
+		if errors.Is(err, errNotConfirmed) {
+			return false, err
+		}
 		fmt.Printf("Saved target connection failed (%v); continuing interactively.\n", err)
 		return false, nil
 	}
+	return true, nil
+}
+
+func startSavedSession(opts Options, tmpConfigPath string, scope recentScope, selected instanceCandidate) error {
+	document, err := opts.policy.sessionDocument(opts.Document)
+	if err != nil {
+		return err
+	}
+	opts.announceTarget(selected, "Starting SSM session to %s in %s (profile %s)", selected.InstanceID, selected.Region, selected.ProfileName)
+	if err := confirmProtectedFn(opts, selected); err != nil {
+		return err
+	}
+	if err := startSSMSessionFn(tmpConfigPath, selected.ProfileName, selected.Region, selected.InstanceID, document); err != nil {
+		return err
+	}
 	_ = saveRecentTargets(opts.CacheDir, opts.Profile, scope, recentInstance{
 		InstanceID:  selected.InstanceID,
 		Region:      selected.Region,
 		ProfileName: selected.ProfileName,
 		DisplayLine: selected.DisplayLine,
 	})
-	return true, nil
+	return nil
 }
 
 func containsString(items []string, needle string) bool {
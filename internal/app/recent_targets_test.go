@@ -1,6 +1,11 @@
 package app
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 )
 
@@ -41,3 +46,134 @@ func TestRecentTargetsRoundTrip(t *testing.T) {
 		t.Fatalf("unexpected instance: %+v", gotInst)
 	}
 }
+
+func TestSaveRecentTargetsRecordsHistory(t *testing.T) {
+	dir := t.TempDir()
+	web := recentInstance{InstanceID: "i-web", Region: "us-east-1"}
+	db := recentInstance{InstanceID: "i-db", Region: "us-east-1"}
+	scope := recentScope{AccountID: "123456789012", RoleName: "Admin", Region: "us-east-1"}
+	for _, inst := range []recentInstance{web, db, web} {
+		if err := saveRecentTargets(dir, "p", scope, inst); err != nil {
+			t.Fatalf("saveRecentTargets: %v", err)
+		}
+	}
+
+	got, err := loadRecentTargets(dir)
+	if err != nil {
+		t.Fatalf("loadRecentTargets: %v", err)
+	}
+	history := got.history("p")
+	if len(history) != 2 {
+		t.Fatalf("expected 2 history entries, got %+v", history)
+	}
+	if history[0].Instance.InstanceID != "i-web" || history[0].UseCount != 2 {
+		t.Fatalf("expected i-web first with 2 uses, got %+v", history[0])
+	}
+	if history[1].Instance.InstanceID != "i-db" || history[1].UseCount != 1 {
+		t.Fatalf("expected i-db second with 1 use, got %+v", history[1])
+	}
+}
+
+func TestRecordHistoryIsBounded(t *testing.T) {
+	var history []recentHistoryEntry
+	for i := 0; i < recentHistoryLimit+5; i++ {
+		history = recordHistory(history, recentScope{AccountID: "123456789012", RoleName: "Admin"}, recentInstance{InstanceID: fmt.Sprintf("i-%d", i), Region: "us-east-1"}, "now")
+	}
+	if len(history) != recentHistoryLimit {
+		t.Fatalf("expected %d entries, got %d", recentHistoryLimit, len(history))
+	}
+	if history[0].Instance.InstanceID != fmt.Sprintf("i-%d", recentHistoryLimit+4) {
+		t.Fatalf("expected newest entry first, got %+v", history[0])
+	}
+}
+
+func TestLoadRecentTargetsSeedsHistoryFromVersion1(t *testing.T) {
+	dir := t.TempDir()
+	raw := `{"version":1,"profiles":{"p":{"last_scope":{"account_id":"123456789012","role_name":"Admin","region":"us-east-1"},"last_instance":{"instance_id":"i-1","region":"us-east-1"},"updated_at":"2026-01-01T00:00:00Z"}}}`
+	if err := os.WriteFile(filepath.Join(dir, recentTargetsFileName), []byte(raw), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := loadRecentTargets(dir)
+	if err != nil {
+		t.Fatalf("loadRecentTargets: %v", err)
+	}
+	history := got.history("p")
+	if got.Version != recentTargetsVersion || len(history) != 1 || history[0].Instance.InstanceID != "i-1" {
+		t.Fatalf("expected history seeded from last instance, got %+v", got)
+	}
+}
+
+func TestTryLastConnectionUsesNthHistoryEntry(t *testing.T) {
+	installRunTestSeams(t)
+
+	recent := newRecentTargetsFile()
+	scope := recentScope{AccountID: "123456789012", RoleName: "Admin", Region: "us-east-1"}
+	recent.Profiles["p"] = recentProfileData{History: []recentHistoryEntry{
+		{Scope: scope, Instance: recentInstance{InstanceID: "i-newest", Region: "us-east-1"}, UseCount: 1},
+		{Scope: scope, Instance: recentInstance{InstanceID: "i-older", Region: "us-east-1"}, UseCount: 4},
+	}}
+	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
+		return "/tmp/mock-config.ini", map[string]string{targetKey(targets[0]): "swamp-1"}, nil
+	}
+	discoverRegionsFn = func(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+		return []string{"us-east-1"}, nil
+	}
+	scanAllInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) ([]instanceCandidate, error) {
+		return []instanceCandidate{
+			{InstanceID: "i-newest", Region: "us-east-1", ProfileName: "swamp-1"},
+			{InstanceID: "i-older", Region: "us-east-1", ProfileName: "swamp-1"},
+		}, nil
+	}
+	var started string
+	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID, document string) error {
+		started = instanceID
+		return nil
+	}
+	removeFileFn = func(path string) error { return nil }
+
+	ok, err := tryLastConnection(context.Background(), Options{Profile: "p", LastN: 2, Workers: 1, CacheDir: t.TempDir()}, profileConfig{}, recent, "us-east-1")
+	if err != nil || !ok {
+		t.Fatalf("expected connection, got ok=%v err=%v", ok, err)
+	}
+	if started != "i-older" {
+		t.Fatalf("expected second most recent target, got %q", started)
+	}
+
+	ok, err = tryLastConnection(context.Background(), Options{Profile: "p", LastN: 3}, profileConfig{}, recent, "us-east-1")
+	if err != nil || ok {
+		t.Fatalf("expected fallback when history is too short, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestSelectHistoryEntryReturnsPickedConnection(t *testing.T) {
+	orig := pickLineFn
+	defer func() { pickLineFn = orig }()
+
+	recent := newRecentTargetsFile()
+	scope := recentScope{AccountID: "123456789012", AccountName: "prod", RoleName: "Admin"}
+	recent.Profiles["p"] = recentProfileData{History: []recentHistoryEntry{
+		{Scope: scope, Instance: recentInstance{InstanceID: "i-1", Region: "us-east-1", DisplayLine: "web"}, LastUsedAt: "2026-01-01T00:00:00Z", UseCount: 3},
+		{Scope: scope, Instance: recentInstance{InstanceID: "i-2", Region: "us-east-1", DisplayLine: "db"}, LastUsedAt: "2026-01-01T00:00:00Z", UseCount: 1},
+	}}
+	pickLineFn = func(_ Options, lines []string, prompt string, preview bool) (string, bool, error) {
+		if len(lines) != 2 || !strings.Contains(lines[0], "prod (123456789012)") || !strings.HasSuffix(lines[0], "used 3x") {
+			t.Fatalf("unexpected history lines %q", lines)
+		}
+		return lines[1], true, nil
+	}
+	entry, err := selectHistoryEntry(Options{Profile: "p"}, recent)
+	if err != nil || entry == nil || entry.Instance.InstanceID != "i-2" {
+		t.Fatalf("expected i-2, got %+v (err=%v)", entry, err)
+	}
+}
+
+func TestConnectSavedTargetReturnsPolicyDocumentError(t *testing.T) {
+	installRunTestSeams(t)
+
+	opts := Options{Document: "AWS-StartInteractiveCommand", policy: accessPolicy{readOnly: true, allowedDocuments: []string{"ReadOnly-Shell"}}}
+	scope := recentScope{AccountID: "111111111111", AccountName: "acct", RoleName: "Admin", Region: "us-east-1"}
+	ok, err := connectSavedTarget(context.Background(), opts, profileConfig{}, scope, recentInstance{InstanceID: "i-1", Region: "us-east-1"}, "us-east-1")
+	if ok || err == nil || !strings.Contains(err.Error(), "policy.allowed_documents") {
+		t.Fatalf("expected the policy error instead of an interactive fallback, got ok=%t err=%v", ok, err)
+	}
+}
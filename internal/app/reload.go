@@ -0,0 +1,299 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+)
+
+// swampExecutable is the binary fzf calls back into for in-picker actions;
+// it is empty when those bindings are unavailable (for example with the
+// builtin picker).
+var swampExecutable string
+
+// reloadSpec is everything the hidden __scan command needs to rerun the
+// instance scan the picker was opened with. It lives in a temp file for
+// the lifetime of one picker.
+type reloadSpec struct {
+	Path string `json:"-"`
+
+	Profile           string            `json:"profile"`
+	CacheEnabled      bool              `json:"cache_enabled"`
+	CacheDir          string            `json:"cache_dir"`
+	CacheMode         string            `json:"cache_mode"`
+	CacheTTLInstances time.Duration     `json:"cache_ttl_instances"`
+	Workers           int               `json:"workers"`
+	Timeout           time.Duration     `json:"timeout"`
+	CallTimeout       time.Duration     `json:"call_timeout"`
+	TmpConfigPath     string            `json:"tmp_config_path"`
+	Targets           []roleTarget      `json:"targets"`
+	ProfileNames      map[string]string `json:"profile_names"`
+	Regions           []string          `json:"regions"`
+	RunningOnly       bool              `json:"running_only"`
+	SSMStatus         bool              `json:"ssm_status"`
+	Filter            string            `json:"filter"`
+	ResultsPath       string            `json:"results_path"`
+	Listen            *fzfListener      `json:"listen,omitempty"`
+
+	Environments []accountEnvironment `json:"environments"`
+	Favorites    []favoritePin        `json:"favorites"`
+	// TargetSettings holds each target's account overrides as resolved
+	// when the picker was opened, keyed by targetKey.
+	TargetSettings map[string]reloadTargetSettings `json:"target_settings"`
+	Policy         userConfigPolicy                `json:"policy"`
+}
+
+type reloadTargetSettings struct {
+	IncludeStopped    bool          `json:"include_stopped"`
+	CacheTTLInstances time.Duration `json:"cache_ttl_instances"`
+}
+
+func writeReloadSpec(opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, runningOnly bool) (*reloadSpec, error) {
+	results, err := os.CreateTemp("", "swamp-scan-results-*.json")
+	if err != nil {
+		return nil, err
+	}
+	results.Close()
+	specFile, err := os.CreateTemp("", "swamp-scan-*.json")
+	if err != nil {
+		_ = os.Remove(results.Name())
+		return nil, err
+	}
+	specFile.Close()
+
+	settings := make(map[string]reloadTargetSettings, len(targets))
+	for _, target := range targets {
+		targetOpts := opts.forTarget(target)
+		settings[targetKey(target)] = reloadTargetSettings{
+			IncludeStopped:    targetOpts.IncludeStopped,
+			CacheTTLInstances: targetOpts.CacheTTLInstances,
+		}
+	}
+	spec := &reloadSpec{
+		Path:              specFile.Name(),
+		Profile:           opts.Profile,
+		CacheEnabled:      opts.CacheEnabled,
+		CacheDir:          opts.CacheDir,
+		CacheMode:         opts.CacheMode,
+		CacheTTLInstances: opts.CacheTTLInstances,
+		Workers:           opts.Workers,
+		Timeout:           opts.Timeout,
+		CallTimeout:       opts.CallTimeout,
+		TmpConfigPath:     tmpConfigPath,
+		Targets:           targets,
+		ProfileNames:      profileNames,
+		Regions:           regions,
+		RunningOnly:       runningOnly,
+		SSMStatus:         opts.SSMStatus,
+		Filter:            opts.Filter,
+		ResultsPath:       results.Name(),
+		Environments:      opts.environments,
+		Favorites:         opts.pins,
+		TargetSettings:    settings,
+		Policy:            opts.policy.config(),
+	}
+	if err := spec.save(); err != nil {
+		spec.remove()
+		return nil, err
+	}
+	return spec, nil
+}
+
+func loadReloadSpec(path string) (*reloadSpec, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var spec reloadSpec
+	if err := json.Unmarshal(raw, &spec); err != nil {
+		return nil, fmt.Errorf("parse scan spec: %w", err)
+	}
+	spec.Path = path
+	return &spec, nil
+}
+
+func (s *reloadSpec) save() error {
+	raw, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.Path, raw, 0o600)
+}
+
+func (s *reloadSpec) remove() {
+	_ = os.Remove(s.Path)
+	_ = os.Remove(s.ResultsPath)
+}
+
+// reloaded reports whether fzf has replaced the streamed list with the
+// output of __scan.
+func (s *reloadSpec) reloaded() bool {
+	info, err := os.Stat(s.ResultsPath)
+	return err == nil && info.Size() > 0
+}
+
+func (s *reloadSpec) results() []instanceCandidate {
+	raw, err := os.ReadFile(s.ResultsPath)
+	if err != nil || len(raw) == 0 {
+		return nil
+	}
+	var cands []instanceCandidate
+	if err := json.Unmarshal(raw, &cands); err != nil {
+		return nil
+	}
+	return cands
+}
+
+func (s *reloadSpec) options() Options {
+	opts := Options{
+		Profile:           s.Profile,
+		Workers:           s.Workers,
+		CacheEnabled:      s.CacheEnabled,
+		CacheDir:          s.CacheDir,
+		CacheMode:         s.CacheMode,
+		CacheTTLInstances: s.CacheTTLInstances,
+		Timeout:           s.Timeout,
+		CallTimeout:       s.CallTimeout,
+		SSMStatus:         s.SSMStatus,
+		Filter:            s.Filter,
+		environments:      s.Environments,
+		pins:              s.Favorites,
+	}
+	// The filter and policy were validated when the picker was opened.
+	opts.filter, _ = parseFilter(s.Filter)
+	opts.policy, _ = parsePolicy(s.Policy)
+	for _, target := range s.Targets {
+		settings, ok := s.TargetSettings[targetKey(target)]
+		if !ok {
+			continue
+		}
+		includeStopped := settings.IncludeStopped
+		opts.accountOverrides = append(opts.accountOverrides, accountOverride{
+			match:          target.AccountID,
+			includeStopped: &includeStopped,
+			ttlInstances:   settings.CacheTTLInstances,
+		})
+	}
+	return opts
+}
+
+func ReloadScan(ctx context.Context, specPath string, fresh, toggleStopped bool) error {
+	if err := configureAtRest(Options{}); err != nil {
+		return err
+	}
+	return reloadScan(ctx, os.Stdout, specPath, fresh, toggleStopped)
+}
+
+// reloadScan reruns the picker's scan and prints fzf lines for reload().
+// Results are also written next to the spec so the parent process can
+// resolve whatever line the user ends up selecting.
+func reloadScan(ctx context.Context, w io.Writer, specPath string, fresh, toggleStopped bool) error {
+	spec, err := loadReloadSpec(specPath)
+	if err != nil {
+		return err
+	}
+	if toggleStopped {
+		spec.RunningOnly = !spec.RunningOnly
+		if err := spec.save(); err != nil {
+			return err
+		}
+	}
+
+	opts := spec.options()
+	if recent, err := loadRecentTargets(spec.CacheDir); err == nil {
+		opts.usage = recent.frecency(spec.Profile, time.Now())
+	}
+	if fresh {
+		opts.CacheMode = string(cacheModeFresh)
+	}
+	opts.cacheStore = newCacheStore(opts)
+	opts.cacheStore.ctx = ctx
+	defer func() { _ = opts.cacheStore.flushStats() }()
+	defer opts.cacheStore.waitForRefreshes(io.Discard, refreshGracePeriod)
+
+	scanCtx, cancel := opts.discoveryContext(ctx)
+	cands, scanErr := scanAllInstancesFn(scanCtx, opts, spec.TmpConfigPath, spec.Targets, spec.ProfileNames, spec.Regions, opts.Workers, spec.RunningOnly)
+	cancel()
+	sort.Slice(cands, func(i, j int) bool {
+		return cands[i].DisplayLine < cands[j].DisplayLine
+	})
+	cands = opts.rankInstances(cands)
+
+	raw, err := json.Marshal(cands)
+	if err != nil {
+		return err
+	}
+	if err := os.WriteFile(spec.ResultsPath, raw, 0o600); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, fzfBackOption)
+	for _, c := range cands {
+		fmt.Fprintln(w, opts.instanceLine(c))
+	}
+	if spec.Listen != nil {
+		postFZFAction(spec.Listen, "change-header:"+fzfInstanceHeader(reloadHeader(spec, fresh, len(cands), scanErr), true))
+	}
+	if scanErr != nil && len(cands) == 0 {
+		return describeContextError(opts, scanErr)
+	}
+	return nil
+}
+
+func reloadHeader(spec *reloadSpec, fresh bool, found int, scanErr error) string {
+	states := "running only"
+	if !spec.RunningOnly {
+		states = "all states"
+	}
+	source := "cache allowed"
+	if fresh {
+		source = "fresh scan"
+	}
+	if scanErr != nil {
+		return fmt.Sprintf("Rescan stopped: %v; %d instances (%s, %s)", scanErr, found, states, source)
+	}
+	return fmt.Sprintf("Rescan complete; %d instances (%s, %s)", found, states, source)
+}
+
+func CopyInstanceID(key string) error {
+	parts := strings.Split(strings.TrimSpace(key), ":")
+	if len(parts) != 5 || parts[0] != "instance" || parts[4] == "" {
+		return fmt.Errorf("not an instance key: %q", key)
+	}
+	name, args, err := clipboardCommand()
+	if err != nil {
+		return err
+	}
+	cmd := exec.Command(name, args...)
+	cmd.Stdin = strings.NewReader(parts[4])
+	return cmd.Run()
+}
+
+var lookPathFn = exec.LookPath
+
+func clipboardCommand() (string, []string, error) {
+	if runtime.GOOS == "darwin" {
+		return "pbcopy", nil, nil
+	}
+	candidates := [][]string{
+		{"xclip", "-selection", "clipboard"},
+		{"xsel", "--clipboard", "--input"},
+	}
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		candidates = append([][]string{{"wl-copy"}}, candidates...)
+	}
+	for _, c := range candidates {
+		if _, err := lookPathFn(c[0]); err == nil {
+			return c[0], c[1:], nil
+		}
+	}
+	return "", nil, errors.New("no clipboard tool found (install wl-copy, xclip or xsel)")
+}
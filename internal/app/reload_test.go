@@ -0,0 +1,140 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReloadScanToggleStoppedPersistsAndWritesResults(t *testing.T) {
+	origScan := scanAllInstancesFn
+	defer func() { scanAllInstancesFn = origScan }()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	target := roleTarget{AccountID: "111111111111", AccountName: "acct", RoleName: "Admin"}
+	spec, err := writeReloadSpec(Options{Profile: "dev", Workers: 2}, "/tmp/mock-config.ini", []roleTarget{target}, map[string]string{targetKey(target): "swamp-1"}, []string{"us-east-1"}, true)
+	if err != nil {
+		t.Fatalf("writeReloadSpec: %v", err)
+	}
+	defer spec.remove()
+
+	var gotRunningOnly bool
+	scanAllInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) ([]instanceCandidate, error) {
+		gotRunningOnly = runningOnly
+		if opts.CacheMode != "fresh" {
+			t.Fatalf("expected fresh cache mode, got %q", opts.CacheMode)
+		}
+		return []instanceCandidate{
+			{DisplayLine: "b", ProfileName: "swamp-1", Region: "us-east-1", InstanceID: "i-2", AccountID: target.AccountID, RoleName: target.RoleName},
+			{DisplayLine: "a", ProfileName: "swamp-1", Region: "us-east-1", InstanceID: "i-1", AccountID: target.AccountID, RoleName: target.RoleName},
+		}, nil
+	}
+
+	var out bytes.Buffer
+	if err := reloadScan(context.Background(), &out, spec.Path, true, true); err != nil {
+		t.Fatalf("reloadScan: %v", err)
+	}
+	if gotRunningOnly {
+		t.Fatal("expected toggle to include stopped instances")
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 3 || lines[0] != fzfBackOption || !strings.HasPrefix(lines[1], "a\t") {
+		t.Fatalf("unexpected reload output %q", lines)
+	}
+
+	reloaded, err := loadReloadSpec(spec.Path)
+	if err != nil {
+		t.Fatalf("loadReloadSpec: %v", err)
+	}
+	if reloaded.RunningOnly {
+		t.Fatal("expected toggled state to be saved in the spec")
+	}
+	results := reloaded.results()
+	if len(results) != 2 || (Options{}).instanceLine(results[0]) != lines[1] {
+		t.Fatalf("expected results sidecar to match printed lines, got %+v", results)
+	}
+	if !reloaded.reloaded() {
+		t.Fatal("expected spec to report a reload")
+	}
+}
+
+func TestReloadScanKeepsAccountOverridesAndPolicy(t *testing.T) {
+	origScan := scanAllInstancesFn
+	defer func() { scanAllInstancesFn = origScan }()
+	t.Setenv("TMPDIR", t.TempDir())
+
+	includeStopped := true
+	opts := Options{
+		Profile:           "dev",
+		Workers:           2,
+		CacheTTLInstances: time.Minute,
+		accountOverrides:  []accountOverride{{match: "prod-*", includeStopped: &includeStopped, ttlInstances: time.Hour}},
+		policy:            accessPolicy{denyRoles: []string{"Admin*"}, readOnly: true},
+	}
+	prod := roleTarget{AccountID: "111111111111", AccountName: "prod-payments", RoleName: "ReadOnly"}
+	dev := roleTarget{AccountID: "222222222222", AccountName: "dev", RoleName: "ReadOnly"}
+	spec, err := writeReloadSpec(opts, "/tmp/mock-config.ini", []roleTarget{prod, dev}, nil, []string{"us-east-1"}, true)
+	if err != nil {
+		t.Fatalf("writeReloadSpec: %v", err)
+	}
+	defer spec.remove()
+
+	scanAllInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) ([]instanceCandidate, error) {
+		if got := opts.forTarget(prod); got.CacheTTLInstances != time.Hour || !got.IncludeStopped {
+			t.Fatalf("expected prod override in the rescan, got ttl=%s include_stopped=%t", got.CacheTTLInstances, got.IncludeStopped)
+		}
+		if got := opts.forTarget(dev); got.CacheTTLInstances != time.Minute || got.IncludeStopped {
+			t.Fatalf("expected defaults for dev in the rescan, got ttl=%s include_stopped=%t", got.CacheTTLInstances, got.IncludeStopped)
+		}
+		if !opts.policy.readOnly || opts.policy.allowsRole("AdminAccess") {
+			t.Fatalf("expected the policy in the rescan, got %+v", opts.policy)
+		}
+		return nil, nil
+	}
+	if err := reloadScan(context.Background(), io.Discard, spec.Path, false, false); err != nil {
+		t.Fatalf("reloadScan: %v", err)
+	}
+}
+
+func TestParseFZFExpectOutput(t *testing.T) {
+	key, line := parseFZFExpectOutput("ctrl-p\nweb | i-1\tinstance:1:r:us-east-1:i-1\n")
+	if key != "ctrl-p" || line != "web | i-1\tinstance:1:r:us-east-1:i-1" {
+		t.Fatalf("unexpected parse: %q %q", key, line)
+	}
+	key, line = parseFZFExpectOutput("\nweb\n")
+	if key != "" || line != "web" {
+		t.Fatalf("expected enter with selection, got %q %q", key, line)
+	}
+}
+
+func TestFZFInstanceHeaderListsBindings(t *testing.T) {
+	got := fzfInstanceHeader("Scanning", true)
+	for _, want := range []string{"Scanning\n", "ctrl-r", "ctrl-s", "ctrl-y", "ctrl-p", "alt-a", "alt-r"} {
+		if !strings.Contains(got, want) {
+			t.Fatalf("expected header to contain %q, got %q", want, got)
+		}
+	}
+	if strings.Contains(fzfInstanceHeader("Scanning", false), "ctrl-r") {
+		t.Fatal("expected reload bindings to be omitted without a reload spec")
+	}
+}
+
+func TestPromptPortForwardDefaultsLocalPort(t *testing.T) {
+	var out bytes.Buffer
+	remote, local, err := promptPortForward(strings.NewReader("8080\n\n"), &out)
+	if err != nil || remote != 8080 || local != 8080 {
+		t.Fatalf("expected 8080->8080, got %d->%d (err=%v)", remote, local, err)
+	}
+	if _, _, err := promptPortForward(strings.NewReader("99999\n"), &out); err == nil {
+		t.Fatal("expected invalid port error")
+	}
+}
+
+func TestCopyInstanceIDRejectsNonInstanceKeys(t *testing.T) {
+	if err := CopyInstanceID("account:111111111111"); err == nil {
+		t.Fatal("expected error for non-instance key")
+	}
+}
@@ -1,26 +1,34 @@
 package app
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"os"
 	"sort"
 	"strings"
+	"time"
 )
 
 var (
 	selectAccountFn       = selectAccountWithFZF
 	discoverRoleTargetsFn = discoverRoleTargets
-	selectRoleTargetFn    = selectRoleTargetWithFZF
+	selectRoleTargetsFn   = selectRoleTargetsWithFZF
 	buildTempAWSConfigFn  = buildTemporaryAWSConfig
 	discoverRegionsFn     = discoverRegions
-	selectRegionFn        = selectRegionWithFZF
+	selectRegionsFn       = selectRegionsWithFZF
 	scanAllInstancesFn    = scanAllInstances
+	streamInstancesFn     = streamAllInstances
 	pickInstanceFn        = pickWithFZF
+	pickLineFn            = pickLineWithFZF
+	pickLinesFn           = pickLinesWithFZF
 	startSSMSessionFn     = startSSMSession
+	startPortForwardFn    = startPortForwardSession
+	promptPortForwardFn   = func() (int, int, error) { return promptPortForward(os.Stdin, os.Stdout) }
 	removeFileFn          = os.Remove
 )
 
-func Run(opts Options) error {
+func Run(ctx context.Context, opts Options) error {
 	resolvedOpts, cfg, err := resolveRuntimeOptions(opts)
 	if err != nil {
 		return err
@@ -36,10 +44,25 @@ func Run(opts Options) error {
 	if err := validateOptionsWithSource(resolvedOpts); err != nil {
 		return err
 	}
-	if err := validateDependencies(); err != nil {
+	if err := configureAtRest(resolvedOpts); err != nil {
+		return err
+	}
+	picker := resolvePicker(resolvedOpts)
+	if err := validateDependencies(picker); err != nil {
 		return err
 	}
 	resolvedOpts.cacheStore = newCacheStore(resolvedOpts)
+	resolvedOpts.cacheStore.ctx = ctx
+	defer func() { _ = resolvedOpts.cacheStore.flushStats() }()
+	defer resolvedOpts.cacheStore.waitForRefreshes(os.Stdout, refreshGracePeriod)
+	resolvedOpts.budget = newDiscoveryBudget(resolvedOpts.Timeout)
+	resolvedOpts.Picker = picker
+	if picker != "builtin" {
+		resolvedOpts.previewCommand = previewCommandFor(resolvedOpts)
+		if exe, err := os.Executable(); err == nil {
+			swampExecutable = exe
+		}
+	}
 	if resolvedOpts.CacheClear {
 		if err := resolvedOpts.cacheStore.clear(); err != nil {
 			return fmt.Errorf("failed to clear cache: %w", err)
@@ -52,8 +75,11 @@ func Run(opts Options) error {
 	}
 
 	fmt.Printf("Checking SSO session for profile %q...\n", resolvedOpts.Profile)
-	accessToken, err := ensureSSOLoginAndGetToken(resolvedOpts.Profile, cfg.SSOStartURL)
+	accessToken, err := ensureSSOLoginAndGetToken(ctx, resolvedOpts.progressOut(), resolvedOpts.Profile, cfg.SSOStartURL)
 	if err != nil {
+		if ctx.Err() != nil {
+			return describeContextError(resolvedOpts, context.Cause(ctx))
+		}
 		return fmt.Errorf("failed to authenticate profile %q: %w", resolvedOpts.Profile, err)
 	}
 	ssoRegion := resolveSSORegion(cfg)
@@ -61,13 +87,51 @@ func Run(opts Options) error {
 	recent, recentErr := loadRecentTargets(resolvedOpts.CacheDir)
 	if recentErr != nil {
 		fmt.Printf("warning: failed to load recent targets: %v\n", recentErr)
-		recent = recentTargetsFile{Version: 1, Profiles: map[string]recentProfileData{}}
+		recent = newRecentTargetsFile()
+	}
+	resolvedOpts.usage = recent.frecency(resolvedOpts.Profile, time.Now())
+
+	if fav := resolvedOpts.favorite; fav != nil {
+		ok, err := connectFavorite(ctx, resolvedOpts, cfg, *fav, ssoRegion)
+		if err != nil {
+			return describeContextError(resolvedOpts, err)
+		}
+		if ok {
+			return nil
+		}
+		resolvedOpts.AccountFilter = fav.Scope.AccountID
+		resolvedOpts.RoleFilter = fav.Scope.RoleName
+		resolvedOpts.RoleFromPreferred = false
+		resolvedOpts.RegionsArg = fav.region()
+		resolvedOpts.setScopeSource("favorite")
+	}
+
+	if resolvedOpts.History {
+		entry, err := selectHistoryEntry(resolvedOpts, recent)
+		if err != nil {
+			return fmt.Errorf("history selection failed: %w", err)
+		}
+		if entry == nil {
+			return nil
+		}
+		ok, err := connectSavedTarget(ctx, resolvedOpts, cfg, entry.Scope, entry.Instance, ssoRegion)
+		if err != nil {
+			return describeContextError(resolvedOpts, err)
+		}
+		if ok {
+			return nil
+		}
+		resolvedOpts.AccountFilter = entry.Scope.AccountID
+		resolvedOpts.RoleFilter = entry.Scope.RoleName
+		resolvedOpts.RoleFromPreferred = false
+		resolvedOpts.RegionsArg = entry.Instance.Region
+		resolvedOpts.setScopeSource("history")
 	}
 
 	if resolvedOpts.Last {
-		ok, err := tryLastConnection(resolvedOpts, cfg, recent, ssoRegion)
+		ok, err := tryLastConnection(ctx, resolvedOpts, cfg, recent, ssoRegion)
 		if err != nil {
-			return err
+			return describeContextError(resolvedOpts, err)
 		}
 		if ok {
 			return nil
@@ -79,20 +143,26 @@ func Run(opts Options) error {
 			resolvedOpts.AccountFilter = scope.AccountID
 			resolvedOpts.RoleFilter = scope.RoleName
 			resolvedOpts.RegionsArg = scope.Region
+			resolvedOpts.setScopeSource("resume")
 			fmt.Printf("Resuming last scope: account=%s role=%s region=%s\n", scope.AccountID, scope.RoleName, scope.Region)
 		} else {
 			fmt.Printf("No recent scope found for profile %q; continuing interactively.\n", resolvedOpts.Profile)
 		}
 	}
 
-	accounts, err := discoverAccounts(resolvedOpts, ssoRegion, accessToken)
+	discoveryCtx, cancel := resolvedOpts.discoveryContext(ctx)
+	accounts, err := discoverAccounts(discoveryCtx, resolvedOpts, ssoRegion, accessToken)
+	cancel()
 	if err != nil {
-		return err
+		return describeContextError(resolvedOpts, err)
 	}
 	if len(accounts) == 0 {
 		return nil
 	}
-	return runInteractiveScope(resolvedOpts, cfg, ssoRegion, accessToken, accounts)
+	if err := runInteractiveScope(ctx, resolvedOpts, cfg, ssoRegion, accessToken, accounts); err != nil {
+		return describeContextError(resolvedOpts, err)
+	}
+	return nil
 }
 
 func resolveRuntimeOptions(opts Options) (Options, profileConfig, error) {
@@ -101,10 +171,10 @@ func resolveRuntimeOptions(opts Options) (Options, profileConfig, error) {
 		opts.ConfigPath = configPath
 		return opts, profileConfig{}, nil
 	}
-	if err := ensureDefaultConfigFile(configPath); err != nil {
+	if err := ensureDefaultConfigFile(opts.progressOut(), configPath); err != nil {
 		return Options{}, profileConfig{}, err
 	}
-	cfgFile, err := loadUserConfig(configPath)
+	cfgFile, err := loadLayeredConfig(configPath)
 	if err != nil {
 		return Options{}, profileConfig{}, err
 	}
@@ -113,12 +183,22 @@ func resolveRuntimeOptions(opts Options) (Options, profileConfig, error) {
 		return Options{}, profileConfig{}, err
 	}
 	merged.ConfigPath = configPath
+	if strings.TrimSpace(merged.Favorite) != "" {
+		if err := applyFavorite(&merged); err != nil {
+			return Options{}, profileConfig{}, err
+		}
+	}
 	if merged.Last {
 		merged.Resume = false
 	}
 	if merged.WriteConfigExample || merged.PrintEffectiveConfig || strings.TrimSpace(merged.Profile) == "" {
 		return merged, profileConfig{}, nil
 	}
+	// Fail before discovery; per-account documents are checked again at
+	// connect time.
+	if _, err := merged.policy.sessionDocument(merged.Document); err != nil {
+		return Options{}, profileConfig{}, err
+	}
 	profileCfg, err := readProfileConfig(merged.Profile)
 	if err != nil {
 		return Options{}, profileConfig{}, fmt.Errorf("failed to read profile config: %w", err)
@@ -126,7 +206,16 @@ func resolveRuntimeOptions(opts Options) (Options, profileConfig, error) {
 	return merged, profileCfg, nil
 }
 
-func runInteractiveScope(opts Options, cfg profileConfig, ssoRegion, accessToken string, accounts []ssoAccountsResponse) error {
+func runInteractiveScope(ctx context.Context, opts Options, cfg profileConfig, ssoRegion, accessToken string, accounts []ssoAccountsResponse) error {
+	favs := favoritesFile{}
+	if opts.ConfigPath != "" {
+		loaded, err := loadFavorites(opts.ConfigPath)
+		if err != nil {
+			fmt.Printf("warning: failed to load favorites: %v\n", err)
+		} else {
+			favs = loaded
+		}
+	}
 	for {
 		var selectedAccount *ssoAccountsResponse
 		var err error
@@ -136,7 +225,7 @@ func runInteractiveScope(opts Options, cfg profileConfig, ssoRegion, accessToken
 				fmt.Printf("Auto-selected only available account: %s\n", selectedAccount.AccountList[0].AccountID)
 			}
 		} else {
-			selectedAccount, err = selectAccountFn(accounts)
+			selectedAccount, err = selectAccountFn(opts, accounts)
 		}
 		if err != nil {
 			return fmt.Errorf("account selection failed: %w", err)
@@ -145,8 +234,16 @@ func runInteractiveScope(opts Options, cfg profileConfig, ssoRegion, accessToken
 			fmt.Println("No account selected.")
 			return nil
 		}
+		// Everything below runs with the selected account's `accounts:`
+		// overrides applied.
+		opts := opts
+		if len(selectedAccount.AccountList) > 0 {
+			opts = opts.forAccount(selectedAccount.AccountList[0].AccountID, selectedAccount.AccountList[0].AccountName)
+		}
 
-		targets, err := discoverRoleTargetsFn(opts, []ssoAccountsResponse{*selectedAccount}, ssoRegion, accessToken)
+		discoveryCtx, cancel := opts.discoveryContext(ctx)
+		targets, err := discoverRoleTargetsFn(discoveryCtx, opts, []ssoAccountsResponse{*selectedAccount}, ssoRegion, accessToken)
+		cancel()
 		if err != nil {
 			return err
 		}
@@ -155,13 +252,13 @@ func runInteractiveScope(opts Options, cfg profileConfig, ssoRegion, accessToken
 		}
 
 		for {
-			var selectedTarget *roleTarget
+			var selectedTargets []roleTarget
 			var backToAccounts bool
 			if !opts.NoAutoSelect && len(targets) == 1 {
-				selectedTarget = &targets[0]
-				fmt.Printf("Auto-selected only available role: %s\n", selectedTarget.RoleName)
+				selectedTargets = targets[:1]
+				fmt.Printf("Auto-selected only available role: %s\n", targets[0].RoleName)
 			} else {
-				selectedTarget, backToAccounts, err = selectRoleTargetFn(targets)
+				selectedTargets, backToAccounts, err = selectRoleTargetsFn(opts, targets)
 			}
 			if err != nil {
 				return fmt.Errorf("role selection failed: %w", err)
@@ -169,24 +266,25 @@ func runInteractiveScope(opts Options, cfg profileConfig, ssoRegion, accessToken
 			if backToAccounts {
 				break
 			}
-			if selectedTarget == nil {
+			if len(selectedTargets) == 0 {
 				fmt.Println("No role selected.")
 				return nil
 			}
 
-			selectedTargets := []roleTarget{*selectedTarget}
 			tmpConfigPath, profileNames, err := buildTempAWSConfigFn(cfg, selectedTargets)
 			if err != nil {
 				return fmt.Errorf("failed to build temporary AWS config: %w", err)
 			}
 
-			regions, err := discoverRegionsFn(opts, cfg, selectedTargets, tmpConfigPath, profileNames, ssoRegion)
+			discoveryCtx, cancel := opts.discoveryContext(ctx)
+			regions, err := discoverRegionsFn(discoveryCtx, opts, cfg, selectedTargets, tmpConfigPath, profileNames, ssoRegion)
+			cancel()
 			if err != nil {
-				_ = removeFileFn(tmpConfigPath)
+				opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
 				return err
 			}
 			if len(regions) == 0 {
-				_ = removeFileFn(tmpConfigPath)
+				opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
 				continue
 			}
 			fmt.Printf("Scanning %d regions\n", len(regions))
@@ -197,100 +295,184 @@ func runInteractiveScope(opts Options, cfg profileConfig, ssoRegion, accessToken
 			backToRoles := false
 			for {
 				regionsToScan := regions
-				selectedRegion := ""
 				if !opts.SkipRegionSelect {
+					var selectedRegions []string
 					var back bool
 					if !opts.NoAutoSelect && len(regions) == 1 {
-						selectedRegion = regions[0]
-						fmt.Printf("Auto-selected only available region: %s\n", selectedRegion)
+						selectedRegions = regions
+						fmt.Printf("Auto-selected only available region: %s\n", regions[0])
 					} else {
-						selectedRegion, back, err = selectRegionFn(regions)
+						selectedRegions, back, err = selectRegionsFn(opts, regions)
 					}
 					if err != nil {
-						_ = removeFileFn(tmpConfigPath)
+						opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
 						return fmt.Errorf("region selection failed: %w", err)
 					}
 					if back {
 						backToRoles = true
 						break
 					}
-					if selectedRegion == "" {
+					if len(selectedRegions) == 0 {
 						fmt.Println("No region selected.")
-						_ = removeFileFn(tmpConfigPath)
+						opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
 						return nil
 					}
-					regionsToScan = []string{selectedRegion}
+					regionsToScan = selectedRegions
 				}
 
-				candidates := scanAllInstancesFn(opts, tmpConfigPath, selectedTargets, profileNames, regionsToScan, opts.Workers, !opts.IncludeStopped)
-				if len(candidates) == 0 {
+				scanCtx, cancelScan := context.WithCancel(ctx)
+				updates := streamInstancesFn(scanCtx, opts, tmpConfigPath, selectedTargets, profileNames, regionsToScan, opts.Workers, !opts.IncludeStopped)
+				wantBeforePicker := 2
+				if opts.NoAutoSelect {
+					wantBeforePicker = 1
+				}
+				candidates, progress, complete := bufferScanResults(updates, wantBeforePicker)
+				if ctx.Err() != nil || (errors.Is(progress.Err, errDiscoveryTimeout) && len(candidates) == 0) {
+					cancelScan()
+					opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
+					if ctx.Err() != nil {
+						return context.Cause(ctx)
+					}
+					return progress.Err
+				}
+				if complete && len(candidates) == 0 {
+					cancelScan()
 					if opts.SkipRegionSelect {
 						fmt.Println("No EC2 instances found across discovered regions.")
 						backToRoles = true
 						break
 					}
-					fmt.Printf("No EC2 instances found in %s.\n", selectedRegion)
+					fmt.Printf("No EC2 instances found in %s.\n", strings.Join(regionsToScan, ", "))
 					continue
 				}
-				sort.Slice(candidates, func(i, j int) bool {
-					return candidates[i].DisplayLine < candidates[j].DisplayLine
-				})
+
+				opts.pins = favoritePinsFor(favs, opts.Profile, selectedTargets, regionsToScan)
+				candidates = opts.rankInstances(candidates)
 
 				var selected *instanceCandidate
-				var backToRegions bool
-				if !opts.NoAutoSelect && len(candidates) == 1 {
+				action := pickerConnect
+				if complete && !opts.NoAutoSelect && len(candidates) == 1 {
 					selected = &candidates[0]
 					fmt.Printf("Auto-selected only available instance: %s\n", selected.InstanceID)
 				} else {
-					selected, backToRegions, err = pickInstanceFn(candidates)
+					var reload *reloadSpec
+					if swampExecutable != "" {
+						reload, err = writeReloadSpec(opts, tmpConfigPath, selectedTargets, profileNames, regionsToScan, !opts.IncludeStopped)
+						if err != nil {
+							fmt.Printf("warning: in-picker rescans disabled: %v\n", err)
+						}
+					}
+					selected, action, err = opts.pickInstance(candidates, progress, updates, reload)
+					if reload != nil {
+						// ctrl-s sticks for the rest of the session.
+						if spec, loadErr := loadReloadSpec(reload.Path); loadErr == nil {
+							opts.IncludeStopped = !spec.RunningOnly
+						}
+						reload.remove()
+					}
 				}
+				cancelScan()
 				if err != nil {
-					_ = removeFileFn(tmpConfigPath)
+					opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
 					return fmt.Errorf("selection failed: %w", err)
 				}
-				if backToRegions {
-					if opts.SkipRegionSelect {
-						backToRoles = true
-						break
-					}
+				if action == pickerBack && !opts.SkipRegionSelect {
 					continue
 				}
+				if action == pickerBack || action == pickerBackToRoles {
+					backToRoles = true
+					break
+				}
+				if action == pickerBackToAccounts {
+					backToAccounts = true
+					break
+				}
 				if selected == nil {
 					fmt.Println("No instance selected.")
-					_ = removeFileFn(tmpConfigPath)
+					opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
 					return nil
 				}
 
-				fmt.Printf("Starting SSM session to %s in %s (profile %s)\n", selected.InstanceID, selected.Region, selected.ProfileName)
-				if err := startSSMSessionFn(tmpConfigPath, selected.ProfileName, selected.Region, selected.InstanceID); err != nil {
-					_ = removeFileFn(tmpConfigPath)
-					return fmt.Errorf("ssm session failed: %w", err)
-				}
-				if len(selectedTargets) > 0 {
-					scope := recentScope{
-						AccountID:   selectedTargets[0].AccountID,
-						AccountName: selectedTargets[0].AccountName,
-						RoleName:    selectedTargets[0].RoleName,
-						Region:      selected.Region,
+				if action == pickerPortForward {
+					if err := opts.policy.checkPortForward(); err != nil {
+						opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
+						return err
+					}
+					remotePort, localPort, err := promptPortForwardFn()
+					if err != nil {
+						opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
+						return fmt.Errorf("port forwarding setup failed: %w", err)
+					}
+					opts.announceTarget(*selected, "Forwarding localhost:%d to %s:%d in %s (profile %s)", localPort, selected.InstanceID, remotePort, selected.Region, selected.ProfileName)
+					if err := confirmProtectedFn(opts, *selected); err != nil {
+						opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
+						return err
+					}
+					if err := startPortForwardFn(tmpConfigPath, selected.ProfileName, selected.Region, selected.InstanceID, remotePort, localPort); err != nil {
+						opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
+						return fmt.Errorf("ssm port forwarding failed: %w", err)
+					}
+				} else {
+					document, err := opts.policy.sessionDocument(opts.Document)
+					if err != nil {
+						opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
+						return err
 					}
-					inst := recentInstance{
-						InstanceID:  selected.InstanceID,
-						Region:      selected.Region,
-						ProfileName: selected.ProfileName,
-						DisplayLine: selected.DisplayLine,
+					opts.announceTarget(*selected, "Starting SSM session to %s in %s (profile %s)", selected.InstanceID, selected.Region, selected.ProfileName)
+					if err := confirmProtectedFn(opts, *selected); err != nil {
+						opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
+						return err
 					}
-					if err := saveRecentTargets(opts.CacheDir, opts.Profile, scope, inst); err != nil {
-						fmt.Printf("warning: failed to save recent target: %v\n", err)
+					if err := startSSMSessionFn(tmpConfigPath, selected.ProfileName, selected.Region, selected.InstanceID, document); err != nil {
+						opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
+						return fmt.Errorf("ssm session failed: %w", err)
 					}
 				}
-				_ = removeFileFn(tmpConfigPath)
+				scope := recentScope{
+					AccountID:   selected.AccountID,
+					AccountName: selected.AccountName,
+					RoleName:    selected.RoleName,
+					Region:      selected.Region,
+				}
+				inst := recentInstance{
+					InstanceID:  selected.InstanceID,
+					Region:      selected.Region,
+					ProfileName: selected.ProfileName,
+					DisplayLine: selected.DisplayLine,
+				}
+				if err := saveRecentTargets(opts.CacheDir, opts.Profile, scope, inst); err != nil {
+					fmt.Printf("warning: failed to save recent target: %v\n", err)
+				}
+				opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
 				return nil
 			}
 
-			_ = removeFileFn(tmpConfigPath)
+			opts.cacheStore.removeAfterRefreshes(tmpConfigPath)
+			if backToAccounts {
+				break
+			}
 			if backToRoles {
 				continue
 			}
 		}
 	}
 }
+
+// bufferScanResults reads scan results until want candidates have arrived or
+// the scan finishes, so the picker only opens once there is a real choice.
+func bufferScanResults(updates <-chan scanResult, want int) ([]instanceCandidate, scanResult, bool) {
+	var candidates []instanceCandidate
+	var progress scanResult
+	for len(candidates) < want {
+		r, ok := <-updates
+		if !ok {
+			sort.Slice(candidates, func(i, j int) bool {
+				return candidates[i].DisplayLine < candidates[j].DisplayLine
+			})
+			return candidates, progress, true
+		}
+		candidates = append(candidates, r.Candidates...)
+		progress = scanResult{Done: r.Done, Total: r.Total, Stale: r.Stale, Err: r.Err}
+	}
+	return candidates, progress, false
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"context"
 	"fmt"
 	"testing"
 )
@@ -10,60 +11,82 @@ func installRunTestSeams(t *testing.T) {
 
 	origSelectAccountFn := selectAccountFn
 	origDiscoverRoleTargetsFn := discoverRoleTargetsFn
-	origSelectRoleTargetFn := selectRoleTargetFn
+	origSelectRoleTargetsFn := selectRoleTargetsFn
 	origBuildTempAWSConfigFn := buildTempAWSConfigFn
 	origDiscoverRegionsFn := discoverRegionsFn
-	origSelectRegionFn := selectRegionFn
+	origSelectRegionsFn := selectRegionsFn
 	origScanAllInstancesFn := scanAllInstancesFn
+	origStreamInstancesFn := streamInstancesFn
 	origPickInstanceFn := pickInstanceFn
 	origStartSSMSessionFn := startSSMSessionFn
+	origStartPortForwardFn := startPortForwardFn
+	origPromptPortForwardFn := promptPortForwardFn
 	origRemoveFileFn := removeFileFn
 
 	t.Cleanup(func() {
 		selectAccountFn = origSelectAccountFn
 		discoverRoleTargetsFn = origDiscoverRoleTargetsFn
-		selectRoleTargetFn = origSelectRoleTargetFn
+		selectRoleTargetsFn = origSelectRoleTargetsFn
 		buildTempAWSConfigFn = origBuildTempAWSConfigFn
 		discoverRegionsFn = origDiscoverRegionsFn
-		selectRegionFn = origSelectRegionFn
+		selectRegionsFn = origSelectRegionsFn
 		scanAllInstancesFn = origScanAllInstancesFn
+		streamInstancesFn = origStreamInstancesFn
 		pickInstanceFn = origPickInstanceFn
 		startSSMSessionFn = origStartSSMSessionFn
+		startPortForwardFn = origStartPortForwardFn
+		promptPortForwardFn = origPromptPortForwardFn
 		removeFileFn = origRemoveFileFn
 	})
 
-	selectAccountFn = func(accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
+	selectAccountFn = func(_ Options, accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
 		panic("unexpected selectAccountFn call")
 	}
-	discoverRoleTargetsFn = func(opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
+	discoverRoleTargetsFn = func(ctx context.Context, opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
 		panic("unexpected discoverRoleTargetsFn call")
 	}
-	selectRoleTargetFn = func(targets []roleTarget) (*roleTarget, bool, error) {
-		panic("unexpected selectRoleTargetFn call")
+	selectRoleTargetsFn = func(_ Options, targets []roleTarget) ([]roleTarget, bool, error) {
+		panic("unexpected selectRoleTargetsFn call")
 	}
 	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
 		panic("unexpected buildTempAWSConfigFn call")
 	}
-	discoverRegionsFn = func(opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+	discoverRegionsFn = func(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
 		panic("unexpected discoverRegionsFn call")
 	}
-	selectRegionFn = func(regions []string) (string, bool, error) {
-		panic("unexpected selectRegionFn call")
+	selectRegionsFn = func(_ Options, regions []string) ([]string, bool, error) {
+		panic("unexpected selectRegionsFn call")
 	}
-	scanAllInstancesFn = func(opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) []instanceCandidate {
+	scanAllInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) ([]instanceCandidate, error) {
 		panic("unexpected scanAllInstancesFn call")
 	}
-	pickInstanceFn = func(candidates []instanceCandidate) (*instanceCandidate, bool, error) {
+	streamInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) <-chan scanResult {
+		panic("unexpected streamInstancesFn call")
+	}
+	pickInstanceFn = func(_ Options, candidates []instanceCandidate, progress scanResult, updates <-chan scanResult, reload *reloadSpec) (*instanceCandidate, pickerAction, error) {
 		panic("unexpected pickInstanceFn call")
 	}
-	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID string) error {
+	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID, document string) error {
 		panic("unexpected startSSMSessionFn call")
 	}
+	startPortForwardFn = func(tmpConfigPath, profile, region, instanceID string, remotePort, localPort int) error {
+		panic("unexpected startPortForwardFn call")
+	}
+	promptPortForwardFn = func() (int, int, error) {
+		panic("unexpected promptPortForwardFn call")
+	}
 	removeFileFn = func(path string) error {
 		panic("unexpected removeFileFn call")
 	}
 }
 
+func testScanStream(candidates ...instanceCandidate) <-chan scanResult {
+	out := make(chan scanResult, 1)
+	out <- scanResult{Candidates: candidates, Done: 1, Total: 1}
+	close(out)
+	return out
+}
+
 func TestRunInteractiveScopeInstanceBackReturnsToRegionPicker(t *testing.T) {
 	installRunTestSeams(t)
 
@@ -73,39 +96,39 @@ func TestRunInteractiveScopeInstanceBackReturnsToRegionPicker(t *testing.T) {
 	profileNames := map[string]string{targetKey(target): "swamp-1"}
 	candidate := instanceCandidate{DisplayLine: "line-1", ProfileName: "swamp-1", Region: "us-east-1", InstanceID: "i-123"}
 
-	selectAccountFn = func(accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
+	selectAccountFn = func(_ Options, accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
 		return &account, nil
 	}
-	discoverRoleTargetsFn = func(opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
+	discoverRoleTargetsFn = func(ctx context.Context, opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
 		return []roleTarget{target}, nil
 	}
-	selectRoleTargetFn = func(targets []roleTarget) (*roleTarget, bool, error) {
-		return &target, false, nil
+	selectRoleTargetsFn = func(_ Options, targets []roleTarget) ([]roleTarget, bool, error) {
+		return []roleTarget{target}, false, nil
 	}
 	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
 		return mockConfigPath, profileNames, nil
 	}
-	discoverRegionsFn = func(opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+	discoverRegionsFn = func(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
 		return []string{"us-east-1"}, nil
 	}
 	regionCalls := 0
-	selectRegionFn = func(regions []string) (string, bool, error) {
+	selectRegionsFn = func(_ Options, regions []string) ([]string, bool, error) {
 		regionCalls++
-		return "us-east-1", false, nil
+		return []string{"us-east-1"}, false, nil
 	}
-	scanAllInstancesFn = func(opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) []instanceCandidate {
-		return []instanceCandidate{candidate}
+	streamInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) <-chan scanResult {
+		return testScanStream(candidate)
 	}
 	pickCalls := 0
-	pickInstanceFn = func(candidates []instanceCandidate) (*instanceCandidate, bool, error) {
+	pickInstanceFn = func(_ Options, candidates []instanceCandidate, progress scanResult, updates <-chan scanResult, reload *reloadSpec) (*instanceCandidate, pickerAction, error) {
 		pickCalls++
 		if pickCalls == 1 {
-			return nil, true, nil
+			return nil, pickerBack, nil
 		}
-		return &candidate, false, nil
+		return &candidate, pickerConnect, nil
 	}
 	startCalls := 0
-	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID string) error {
+	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID, document string) error {
 		startCalls++
 		return nil
 	}
@@ -115,7 +138,7 @@ func TestRunInteractiveScopeInstanceBackReturnsToRegionPicker(t *testing.T) {
 		return nil
 	}
 
-	err := runInteractiveScope(Options{Workers: 1, NoAutoSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
+	err := runInteractiveScope(context.Background(), Options{Workers: 1, NoAutoSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
 	if err != nil {
 		t.Fatalf("runInteractiveScope returned error: %v", err)
 	}
@@ -138,33 +161,33 @@ func TestRunInteractiveScopeRegionBackReturnsToRolePicker(t *testing.T) {
 	mockConfigPath := "/tmp/mock-config.ini"
 	profileNames := map[string]string{targetKey(target): "swamp-1"}
 
-	selectAccountFn = func(accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
+	selectAccountFn = func(_ Options, accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
 		return &account, nil
 	}
-	discoverRoleTargetsFn = func(opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
+	discoverRoleTargetsFn = func(ctx context.Context, opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
 		return []roleTarget{target}, nil
 	}
 	roleCalls := 0
-	selectRoleTargetFn = func(targets []roleTarget) (*roleTarget, bool, error) {
+	selectRoleTargetsFn = func(_ Options, targets []roleTarget) ([]roleTarget, bool, error) {
 		roleCalls++
 		if roleCalls == 1 {
-			return &target, false, nil
+			return []roleTarget{target}, false, nil
 		}
 		return nil, false, nil
 	}
 	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
 		return mockConfigPath, profileNames, nil
 	}
-	discoverRegionsFn = func(opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+	discoverRegionsFn = func(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
 		return []string{"us-east-1"}, nil
 	}
 	regionCalls := 0
-	selectRegionFn = func(regions []string) (string, bool, error) {
+	selectRegionsFn = func(_ Options, regions []string) ([]string, bool, error) {
 		regionCalls++
-		return "", true, nil
+		return nil, true, nil
 	}
 	startCalls := 0
-	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID string) error {
+	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID, document string) error {
 		startCalls++
 		return nil
 	}
@@ -174,7 +197,7 @@ func TestRunInteractiveScopeRegionBackReturnsToRolePicker(t *testing.T) {
 		return nil
 	}
 
-	err := runInteractiveScope(Options{Workers: 1, NoAutoSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
+	err := runInteractiveScope(context.Background(), Options{Workers: 1, NoAutoSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
 	if err != nil {
 		t.Fatalf("runInteractiveScope returned error: %v", err)
 	}
@@ -201,29 +224,29 @@ func TestRunInteractiveScopeSuccessfulSelectionUsesMockConfigPath(t *testing.T)
 	profileNames := map[string]string{targetKey(target): "swamp-1"}
 	selected := instanceCandidate{DisplayLine: "line-1", ProfileName: "swamp-1", Region: "us-east-1", InstanceID: "i-abc"}
 
-	selectAccountFn = func(accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
+	selectAccountFn = func(_ Options, accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
 		return &account, nil
 	}
-	discoverRoleTargetsFn = func(opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
+	discoverRoleTargetsFn = func(ctx context.Context, opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
 		return []roleTarget{target}, nil
 	}
-	selectRoleTargetFn = func(targets []roleTarget) (*roleTarget, bool, error) {
-		return &target, false, nil
+	selectRoleTargetsFn = func(_ Options, targets []roleTarget) ([]roleTarget, bool, error) {
+		return []roleTarget{target}, false, nil
 	}
 	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
 		return mockConfigPath, profileNames, nil
 	}
-	discoverRegionsFn = func(opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+	discoverRegionsFn = func(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
 		return []string{"us-east-1"}, nil
 	}
-	selectRegionFn = func(regions []string) (string, bool, error) {
-		return "us-east-1", false, nil
+	selectRegionsFn = func(_ Options, regions []string) ([]string, bool, error) {
+		return []string{"us-east-1"}, false, nil
 	}
-	scanAllInstancesFn = func(opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) []instanceCandidate {
-		return []instanceCandidate{selected}
+	streamInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) <-chan scanResult {
+		return testScanStream(selected)
 	}
-	pickInstanceFn = func(candidates []instanceCandidate) (*instanceCandidate, bool, error) {
-		return &selected, false, nil
+	pickInstanceFn = func(_ Options, candidates []instanceCandidate, progress scanResult, updates <-chan scanResult, reload *reloadSpec) (*instanceCandidate, pickerAction, error) {
+		return &selected, pickerConnect, nil
 	}
 	events := []string{}
 	captured := struct {
@@ -232,7 +255,7 @@ func TestRunInteractiveScopeSuccessfulSelectionUsesMockConfigPath(t *testing.T)
 		region        string
 		instanceID    string
 	}{}
-	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID string) error {
+	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID, document string) error {
 		events = append(events, "start")
 		captured.tmpConfigPath = tmpConfigPath
 		captured.profile = profile
@@ -248,7 +271,7 @@ func TestRunInteractiveScopeSuccessfulSelectionUsesMockConfigPath(t *testing.T)
 		return nil
 	}
 
-	err := runInteractiveScope(Options{Workers: 1, NoAutoSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
+	err := runInteractiveScope(context.Background(), Options{Workers: 1, NoAutoSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
 	if err != nil {
 		t.Fatalf("runInteractiveScope returned error: %v", err)
 	}
@@ -269,32 +292,32 @@ func TestRunInteractiveScopeNoInstanceSelectedCleansUpAndExits(t *testing.T) {
 	profileNames := map[string]string{targetKey(target): "swamp-1"}
 	candidate := instanceCandidate{DisplayLine: "line-1", ProfileName: "swamp-1", Region: "us-east-1", InstanceID: "i-123"}
 
-	selectAccountFn = func(accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
+	selectAccountFn = func(_ Options, accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
 		return &account, nil
 	}
-	discoverRoleTargetsFn = func(opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
+	discoverRoleTargetsFn = func(ctx context.Context, opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
 		return []roleTarget{target}, nil
 	}
-	selectRoleTargetFn = func(targets []roleTarget) (*roleTarget, bool, error) {
-		return &target, false, nil
+	selectRoleTargetsFn = func(_ Options, targets []roleTarget) ([]roleTarget, bool, error) {
+		return []roleTarget{target}, false, nil
 	}
 	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
 		return mockConfigPath, profileNames, nil
 	}
-	discoverRegionsFn = func(opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+	discoverRegionsFn = func(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
 		return []string{"us-east-1"}, nil
 	}
-	selectRegionFn = func(regions []string) (string, bool, error) {
-		return "us-east-1", false, nil
+	selectRegionsFn = func(_ Options, regions []string) ([]string, bool, error) {
+		return []string{"us-east-1"}, false, nil
 	}
-	scanAllInstancesFn = func(opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) []instanceCandidate {
-		return []instanceCandidate{candidate}
+	streamInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) <-chan scanResult {
+		return testScanStream(candidate)
 	}
-	pickInstanceFn = func(candidates []instanceCandidate) (*instanceCandidate, bool, error) {
-		return nil, false, nil
+	pickInstanceFn = func(_ Options, candidates []instanceCandidate, progress scanResult, updates <-chan scanResult, reload *reloadSpec) (*instanceCandidate, pickerAction, error) {
+		return nil, pickerConnect, nil
 	}
 	startCalls := 0
-	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID string) error {
+	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID, document string) error {
 		startCalls++
 		return nil
 	}
@@ -304,7 +327,7 @@ func TestRunInteractiveScopeNoInstanceSelectedCleansUpAndExits(t *testing.T) {
 		return nil
 	}
 
-	err := runInteractiveScope(Options{Workers: 1, NoAutoSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
+	err := runInteractiveScope(context.Background(), Options{Workers: 1, NoAutoSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
 	if err != nil {
 		t.Fatalf("runInteractiveScope returned error: %v", err)
 	}
@@ -325,39 +348,39 @@ func TestRunInteractiveScopeNoInstancesLoopsToRegionSelection(t *testing.T) {
 	profileNames := map[string]string{targetKey(target): "swamp-1"}
 	selected := instanceCandidate{DisplayLine: "line-1", ProfileName: "swamp-1", Region: "us-east-1", InstanceID: "i-xyz"}
 
-	selectAccountFn = func(accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
+	selectAccountFn = func(_ Options, accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
 		return &account, nil
 	}
-	discoverRoleTargetsFn = func(opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
+	discoverRoleTargetsFn = func(ctx context.Context, opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
 		return []roleTarget{target}, nil
 	}
-	selectRoleTargetFn = func(targets []roleTarget) (*roleTarget, bool, error) {
-		return &target, false, nil
+	selectRoleTargetsFn = func(_ Options, targets []roleTarget) ([]roleTarget, bool, error) {
+		return []roleTarget{target}, false, nil
 	}
 	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
 		return mockConfigPath, profileNames, nil
 	}
-	discoverRegionsFn = func(opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+	discoverRegionsFn = func(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
 		return []string{"us-east-1"}, nil
 	}
 	regionCalls := 0
-	selectRegionFn = func(regions []string) (string, bool, error) {
+	selectRegionsFn = func(_ Options, regions []string) ([]string, bool, error) {
 		regionCalls++
-		return "us-east-1", false, nil
+		return []string{"us-east-1"}, false, nil
 	}
 	scanCalls := 0
-	scanAllInstancesFn = func(opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) []instanceCandidate {
+	streamInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) <-chan scanResult {
 		scanCalls++
 		if scanCalls == 1 {
-			return nil
+			return testScanStream()
 		}
-		return []instanceCandidate{selected}
+		return testScanStream(selected)
 	}
-	pickInstanceFn = func(candidates []instanceCandidate) (*instanceCandidate, bool, error) {
-		return &selected, false, nil
+	pickInstanceFn = func(_ Options, candidates []instanceCandidate, progress scanResult, updates <-chan scanResult, reload *reloadSpec) (*instanceCandidate, pickerAction, error) {
+		return &selected, pickerConnect, nil
 	}
 	startCalls := 0
-	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID string) error {
+	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID, document string) error {
 		startCalls++
 		return nil
 	}
@@ -367,7 +390,7 @@ func TestRunInteractiveScopeNoInstancesLoopsToRegionSelection(t *testing.T) {
 		return nil
 	}
 
-	err := runInteractiveScope(Options{Workers: 1, NoAutoSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
+	err := runInteractiveScope(context.Background(), Options{Workers: 1, NoAutoSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
 	if err != nil {
 		t.Fatalf("runInteractiveScope returned error: %v", err)
 	}
@@ -394,36 +417,36 @@ func TestRunInteractiveScopeSkipRegionSelectScansAllRegions(t *testing.T) {
 	profileNames := map[string]string{targetKey(target): "swamp-1"}
 	selected := instanceCandidate{DisplayLine: "line-1", ProfileName: "swamp-1", Region: "us-west-2", InstanceID: "i-xyz"}
 
-	selectAccountFn = func(accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
+	selectAccountFn = func(_ Options, accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
 		return &account, nil
 	}
-	discoverRoleTargetsFn = func(opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
+	discoverRoleTargetsFn = func(ctx context.Context, opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
 		return []roleTarget{target}, nil
 	}
-	selectRoleTargetFn = func(targets []roleTarget) (*roleTarget, bool, error) {
-		return &target, false, nil
+	selectRoleTargetsFn = func(_ Options, targets []roleTarget) ([]roleTarget, bool, error) {
+		return []roleTarget{target}, false, nil
 	}
 	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
 		return mockConfigPath, profileNames, nil
 	}
-	discoverRegionsFn = func(opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+	discoverRegionsFn = func(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
 		return []string{"us-east-1", "us-west-2"}, nil
 	}
 	regionPickerCalls := 0
-	selectRegionFn = func(regions []string) (string, bool, error) {
+	selectRegionsFn = func(_ Options, regions []string) ([]string, bool, error) {
 		regionPickerCalls++
-		return "", false, nil
+		return nil, false, nil
 	}
 	var scannedRegions []string
-	scanAllInstancesFn = func(opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) []instanceCandidate {
+	streamInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) <-chan scanResult {
 		scannedRegions = append([]string{}, regions...)
-		return []instanceCandidate{selected}
+		return testScanStream(selected)
 	}
-	pickInstanceFn = func(candidates []instanceCandidate) (*instanceCandidate, bool, error) {
-		return &selected, false, nil
+	pickInstanceFn = func(_ Options, candidates []instanceCandidate, progress scanResult, updates <-chan scanResult, reload *reloadSpec) (*instanceCandidate, pickerAction, error) {
+		return &selected, pickerConnect, nil
 	}
 	startCalls := 0
-	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID string) error {
+	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID, document string) error {
 		startCalls++
 		return nil
 	}
@@ -433,7 +456,7 @@ func TestRunInteractiveScopeSkipRegionSelectScansAllRegions(t *testing.T) {
 		return nil
 	}
 
-	err := runInteractiveScope(Options{Workers: 1, NoAutoSelect: true, SkipRegionSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
+	err := runInteractiveScope(context.Background(), Options{Workers: 1, NoAutoSelect: true, SkipRegionSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
 	if err != nil {
 		t.Fatalf("runInteractiveScope returned error: %v", err)
 	}
@@ -450,3 +473,217 @@ func TestRunInteractiveScopeSkipRegionSelectScansAllRegions(t *testing.T) {
 		t.Fatalf("expected one temp config cleanup, got %d", removeCalls)
 	}
 }
+
+func TestRunInteractiveScopeAltAJumpsBackToAccountPicker(t *testing.T) {
+	installRunTestSeams(t)
+
+	account := testAccount("111111111111", "acct")
+	target := roleTarget{AccountID: "111111111111", AccountName: "acct", RoleName: "Admin"}
+	candidate := instanceCandidate{DisplayLine: "line-1", ProfileName: "swamp-1", Region: "us-east-1", InstanceID: "i-123"}
+
+	accountCalls := 0
+	selectAccountFn = func(_ Options, accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
+		accountCalls++
+		if accountCalls == 2 {
+			return nil, nil
+		}
+		return &account, nil
+	}
+	discoverRoleTargetsFn = func(ctx context.Context, opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
+		return []roleTarget{target}, nil
+	}
+	selectRoleTargetsFn = func(_ Options, targets []roleTarget) ([]roleTarget, bool, error) {
+		return []roleTarget{target}, false, nil
+	}
+	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
+		return "/tmp/mock-config.ini", map[string]string{targetKey(target): "swamp-1"}, nil
+	}
+	discoverRegionsFn = func(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+		return []string{"us-east-1"}, nil
+	}
+	selectRegionsFn = func(_ Options, regions []string) ([]string, bool, error) {
+		return []string{"us-east-1"}, false, nil
+	}
+	streamInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) <-chan scanResult {
+		return testScanStream(candidate)
+	}
+	pickInstanceFn = func(_ Options, candidates []instanceCandidate, progress scanResult, updates <-chan scanResult, reload *reloadSpec) (*instanceCandidate, pickerAction, error) {
+		return nil, pickerBackToAccounts, nil
+	}
+	removeCalls := 0
+	removeFileFn = func(path string) error {
+		removeCalls++
+		return nil
+	}
+
+	err := runInteractiveScope(context.Background(), Options{Workers: 1, NoAutoSelect: true}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
+	if err != nil {
+		t.Fatalf("runInteractiveScope returned error: %v", err)
+	}
+	if accountCalls != 2 {
+		t.Fatalf("expected account picker to be shown twice, got %d", accountCalls)
+	}
+	if removeCalls != 1 {
+		t.Fatalf("expected temp config cleanup once, got %d", removeCalls)
+	}
+}
+
+func TestRunInteractiveScopePortForwardUsesPromptedPorts(t *testing.T) {
+	installRunTestSeams(t)
+
+	account := testAccount("111111111111", "acct")
+	target := roleTarget{AccountID: "111111111111", AccountName: "acct", RoleName: "Admin"}
+	selected := instanceCandidate{DisplayLine: "line-1", ProfileName: "swamp-1", Region: "us-east-1", InstanceID: "i-123"}
+
+	selectAccountFn = func(_ Options, accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
+		return &account, nil
+	}
+	discoverRoleTargetsFn = func(ctx context.Context, opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
+		return []roleTarget{target}, nil
+	}
+	selectRoleTargetsFn = func(_ Options, targets []roleTarget) ([]roleTarget, bool, error) {
+		return []roleTarget{target}, false, nil
+	}
+	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
+		return "/tmp/mock-config.ini", map[string]string{targetKey(target): "swamp-1"}, nil
+	}
+	discoverRegionsFn = func(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+		return []string{"us-east-1"}, nil
+	}
+	selectRegionsFn = func(_ Options, regions []string) ([]string, bool, error) {
+		return []string{"us-east-1"}, false, nil
+	}
+	streamInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) <-chan scanResult {
+		return testScanStream(selected)
+	}
+	pickInstanceFn = func(_ Options, candidates []instanceCandidate, progress scanResult, updates <-chan scanResult, reload *reloadSpec) (*instanceCandidate, pickerAction, error) {
+		return &selected, pickerPortForward, nil
+	}
+	promptPortForwardFn = func() (int, int, error) {
+		return 5432, 15432, nil
+	}
+	var gotRemote, gotLocal int
+	startPortForwardFn = func(tmpConfigPath, profile, region, instanceID string, remotePort, localPort int) error {
+		gotRemote, gotLocal = remotePort, localPort
+		return nil
+	}
+	removeFileFn = func(path string) error {
+		return nil
+	}
+
+	err := runInteractiveScope(context.Background(), Options{Workers: 1, NoAutoSelect: true, CacheDir: t.TempDir()}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account})
+	if err != nil {
+		t.Fatalf("runInteractiveScope returned error: %v", err)
+	}
+	if gotRemote != 5432 || gotLocal != 15432 {
+		t.Fatalf("expected ports 5432->15432, got %d->%d", gotRemote, gotLocal)
+	}
+}
+
+func TestBufferScanResultsStopsOnceEnoughCandidatesArrive(t *testing.T) {
+	updates := make(chan scanResult, 3)
+	updates <- scanResult{Candidates: []instanceCandidate{{DisplayLine: "b"}}, Done: 1, Total: 3}
+	updates <- scanResult{Candidates: []instanceCandidate{{DisplayLine: "a"}}, Done: 2, Total: 3}
+	updates <- scanResult{Candidates: []instanceCandidate{{DisplayLine: "c"}}, Done: 3, Total: 3}
+	close(updates)
+
+	got, progress, complete := bufferScanResults(updates, 2)
+	if complete {
+		t.Fatal("expected buffering to stop before the scan finished")
+	}
+	if len(got) != 2 || progress.Done != 2 || progress.Total != 3 {
+		t.Fatalf("unexpected buffered state: candidates=%v progress=%+v", got, progress)
+	}
+	if r := <-updates; len(r.Candidates) != 1 || r.Candidates[0].DisplayLine != "c" {
+		t.Fatalf("expected remaining result to stay on the stream, got %+v", r)
+	}
+}
+
+func TestBufferScanResultsSortsCompleteScan(t *testing.T) {
+	updates := make(chan scanResult, 2)
+	updates <- scanResult{Candidates: []instanceCandidate{{DisplayLine: "b"}}, Done: 1, Total: 2}
+	updates <- scanResult{Done: 2, Total: 2}
+	close(updates)
+
+	got, _, complete := bufferScanResults(updates, 2)
+	if !complete || len(got) != 1 || got[0].DisplayLine != "b" {
+		t.Fatalf("expected complete scan with one candidate, got complete=%t candidates=%v", complete, got)
+	}
+}
+
+func TestStreamAllInstancesReportsProgressPerJob(t *testing.T) {
+	orig := queryInstancesFetcher
+	defer func() { queryInstancesFetcher = orig }()
+	queryInstancesFetcher = func(ctx context.Context, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+		if region == "us-west-2" {
+			return nil, nil
+		}
+		return []instanceCandidate{{InstanceID: "i-" + region, DisplayLine: region}}, nil
+	}
+
+	target := roleTarget{AccountID: "111", RoleName: "Admin"}
+	regions := []string{"us-east-1", "us-west-2", "eu-west-1"}
+	var results []scanResult
+	for r := range streamAllInstances(context.Background(), Options{Profile: "p"}, "", []roleTarget{target}, map[string]string{targetKey(target): "swamp-1"}, regions, 2, true) {
+		results = append(results, r)
+	}
+	if len(results) != 3 {
+		t.Fatalf("expected one result per job, got %d", len(results))
+	}
+	found := 0
+	for i, r := range results {
+		if r.Done != i+1 || r.Total != 3 || r.Err != nil {
+			t.Fatalf("unexpected progress at %d: %+v", i, r)
+		}
+		found += len(r.Candidates)
+	}
+	if found != 2 {
+		t.Fatalf("expected two candidates overall, got %d", found)
+	}
+}
+
+func TestRunInteractiveScopeRecordsTheSelectedInstancesRole(t *testing.T) {
+	installRunTestSeams(t)
+
+	account := testAccount("111111111111", "acct")
+	admin := roleTarget{AccountID: "111111111111", AccountName: "acct", RoleName: "Admin"}
+	readOnly := roleTarget{AccountID: "111111111111", AccountName: "acct", RoleName: "ReadOnly"}
+	candidate := instanceCandidate{DisplayLine: "line-1", ProfileName: "swamp-2", Region: "us-east-1", InstanceID: "i-123", AccountID: readOnly.AccountID, AccountName: readOnly.AccountName, RoleName: readOnly.RoleName}
+
+	selectAccountFn = func(_ Options, accounts []ssoAccountsResponse) (*ssoAccountsResponse, error) {
+		return &account, nil
+	}
+	discoverRoleTargetsFn = func(ctx context.Context, opts Options, accounts []ssoAccountsResponse, ssoRegion, accessToken string) ([]roleTarget, error) {
+		return []roleTarget{admin, readOnly}, nil
+	}
+	selectRoleTargetsFn = func(_ Options, targets []roleTarget) ([]roleTarget, bool, error) {
+		return targets, false, nil
+	}
+	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
+		return "/tmp/mock-config.ini", map[string]string{targetKey(admin): "swamp-1", targetKey(readOnly): "swamp-2"}, nil
+	}
+	discoverRegionsFn = func(ctx context.Context, opts Options, cfg profileConfig, targets []roleTarget, tmpConfigPath string, profileNames map[string]string, ssoRegion string) ([]string, error) {
+		return []string{"us-east-1"}, nil
+	}
+	streamInstancesFn = func(ctx context.Context, opts Options, tmpConfigPath string, targets []roleTarget, profileNames map[string]string, regions []string, workers int, runningOnly bool) <-chan scanResult {
+		return testScanStream(candidate)
+	}
+	startSSMSessionFn = func(tmpConfigPath, profile, region, instanceID, document string) error {
+		return nil
+	}
+	removeFileFn = func(path string) error {
+		return nil
+	}
+
+	dir := t.TempDir()
+	if err := runInteractiveScope(context.Background(), Options{Profile: "dev", Workers: 1, CacheDir: dir}, profileConfig{}, "us-east-1", "token", []ssoAccountsResponse{account}); err != nil {
+		t.Fatalf("runInteractiveScope returned error: %v", err)
+	}
+	recent, err := loadRecentTargets(dir)
+	if err != nil {
+		t.Fatalf("loadRecentTargets: %v", err)
+	}
+	if got := recent.Profiles["dev"].LastScope; got.RoleName != "ReadOnly" || got.AccountID != readOnly.AccountID || got.AccountName != "acct" {
+		t.Fatalf("expected the selected instance's role in the history, got %+v", got)
+	}
+}
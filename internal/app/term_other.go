@@ -0,0 +1,19 @@
+//go:build !linux && !darwin && !freebsd && !netbsd && !openbsd
+
+package app
+
+import "errors"
+
+type rawTerminal struct{}
+
+func openRawTerminal() (*rawTerminal, error) {
+	return nil, errors.New("the built-in picker is not supported on this platform")
+}
+
+func (t *rawTerminal) read(buf []byte) (int, error) { return 0, errors.New("unsupported") }
+
+func (t *rawTerminal) write(p []byte) (int, error) { return 0, errors.New("unsupported") }
+
+func (t *rawTerminal) size() (int, int) { return 80, 24 }
+
+func (t *rawTerminal) restore() error { return nil }
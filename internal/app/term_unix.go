@@ -0,0 +1,80 @@
+//go:build linux || darwin || freebsd || netbsd || openbsd
+
+package app
+
+import (
+	"errors"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+type rawTerminal struct {
+	tty  *os.File
+	fd   int
+	orig syscall.Termios
+}
+
+func openRawTerminal() (*rawTerminal, error) {
+	tty, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	fd := int(tty.Fd())
+	var orig syscall.Termios
+	if err := termiosIoctl(fd, ioctlGetTermios, &orig); err != nil {
+		tty.Close()
+		return nil, err
+	}
+	raw := orig
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON | syscall.ISTRIP | syscall.INLCR | syscall.IGNCR
+	raw.Lflag &^= syscall.ECHO | syscall.ICANON | syscall.ISIG | syscall.IEXTEN
+	// Reads return after at most 100ms so the key reader can notice the
+	// picker closing without stealing keystrokes meant for the next process.
+	raw.Cc[syscall.VMIN] = 0
+	raw.Cc[syscall.VTIME] = 1
+	if err := termiosIoctl(fd, ioctlSetTermios, &raw); err != nil {
+		tty.Close()
+		return nil, err
+	}
+	return &rawTerminal{tty: tty, fd: fd, orig: orig}, nil
+}
+
+func (t *rawTerminal) read(buf []byte) (int, error) {
+	n, err := syscall.Read(t.fd, buf)
+	if errors.Is(err, syscall.EINTR) || errors.Is(err, syscall.EAGAIN) {
+		return 0, nil
+	}
+	return n, err
+}
+
+func (t *rawTerminal) write(p []byte) (int, error) {
+	return t.tty.Write(p)
+}
+
+func (t *rawTerminal) size() (int, int) {
+	var ws struct {
+		Row, Col, X, Y uint16
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(t.fd), uintptr(syscall.TIOCGWINSZ), uintptr(unsafe.Pointer(&ws)))
+	if errno != 0 || ws.Col == 0 || ws.Row == 0 {
+		return 80, 24
+	}
+	return int(ws.Col), int(ws.Row)
+}
+
+func (t *rawTerminal) restore() error {
+	err := termiosIoctl(t.fd, ioctlSetTermios, &t.orig)
+	if closeErr := t.tty.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+
+func termiosIoctl(fd int, req uintptr, t *syscall.Termios) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd), req, uintptr(unsafe.Pointer(t)))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	errDiscoveryTimeout = errors.New("discovery timeout exceeded")
+	errCallTimeout      = errors.New("aws call timeout exceeded")
+)
+
+// discoveryBudget tracks how much of --timeout is left across discovery
+// steps; time spent waiting on pickers is not charged against it.
+type discoveryBudget struct {
+	mu        sync.Mutex
+	remaining time.Duration
+}
+
+func newDiscoveryBudget(total time.Duration) *discoveryBudget {
+	if total <= 0 {
+		return nil
+	}
+	return &discoveryBudget{remaining: total}
+}
+
+func (b *discoveryBudget) take() time.Duration {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.remaining
+}
+
+func (b *discoveryBudget) charge(elapsed time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.remaining -= elapsed
+}
+
+func (o Options) discoveryContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.Timeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	if o.budget == nil {
+		return context.WithTimeoutCause(ctx, o.Timeout, errDiscoveryTimeout)
+	}
+	started := time.Now()
+	stepCtx, cancel := context.WithTimeoutCause(ctx, o.budget.take(), errDiscoveryTimeout)
+	var once sync.Once
+	return stepCtx, func() {
+		once.Do(func() {
+			o.budget.charge(time.Since(started))
+			cancel()
+		})
+	}
+}
+
+func (o Options) callContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if o.CallTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeoutCause(ctx, o.CallTimeout, errCallTimeout)
+}
+
+func describeContextError(opts Options, err error) error {
+	switch {
+	case errors.Is(err, context.Canceled):
+		return errors.New("interrupted")
+	case errors.Is(err, errDiscoveryTimeout):
+		return fmt.Errorf("discovery timed out after %s (source=%s)", opts.Timeout, sourceOf(opts, "timeout"))
+	default:
+		return err
+	}
+}
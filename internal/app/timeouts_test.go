@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDiscoveryContextChargesSharedBudget(t *testing.T) {
+	opts := Options{Timeout: 50 * time.Millisecond}
+	opts.budget = newDiscoveryBudget(opts.Timeout)
+
+	ctx, cancel := opts.discoveryContext(context.Background())
+	<-ctx.Done()
+	cancel()
+	if !errors.Is(context.Cause(ctx), errDiscoveryTimeout) {
+		t.Fatalf("expected discovery timeout cause, got %v", context.Cause(ctx))
+	}
+
+	next, cancelNext := opts.discoveryContext(context.Background())
+	defer cancelNext()
+	select {
+	case <-next.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected exhausted budget to expire the next step immediately")
+	}
+}
+
+func TestScanAllInstancesStopsWhenCancelled(t *testing.T) {
+	orig := queryInstancesFetcher
+	defer func() { queryInstancesFetcher = orig }()
+	calls := 0
+	queryInstancesFetcher = func(ctx context.Context, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+		calls++
+		return []instanceCandidate{{InstanceID: "i-1"}}, nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	target := roleTarget{AccountID: "111", RoleName: "Admin"}
+	got, err := scanAllInstances(ctx, Options{Profile: "p"}, "", []roleTarget{target}, map[string]string{targetKey(target): "swamp-1"}, []string{"us-east-1", "us-west-2"}, 1, true)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected cancellation error, got %v", err)
+	}
+	if len(got) != 0 || calls != 0 {
+		t.Fatalf("expected no queries after cancellation, got calls=%d candidates=%v", calls, got)
+	}
+}
+
+func TestDescribeContextErrorReportsTimeoutSource(t *testing.T) {
+	opts := Options{Timeout: 30 * time.Second, ValueSource: map[string]string{"timeout": "flag"}}
+	err := describeContextError(opts, errDiscoveryTimeout)
+	if err == nil || !strings.Contains(err.Error(), "30s") || !strings.Contains(err.Error(), "source=flag") {
+		t.Fatalf("unexpected timeout error: %v", err)
+	}
+	if err := describeContextError(opts, context.Canceled); err == nil || err.Error() != "interrupted" {
+		t.Fatalf("expected interrupted error, got %v", err)
+	}
+}
@@ -1,6 +1,9 @@
 package app
 
-import "time"
+import (
+	"io"
+	"time"
+)
 
 type ssoAccountsResponse struct {
 	AccountList []struct {
@@ -20,11 +23,23 @@ type ec2DescribeInstancesResponse struct {
 	Reservations []struct {
 		Instances []struct {
 			InstanceID      string `json:"InstanceId"`
+			InstanceType    string `json:"InstanceType"`
 			PrivateIP       string `json:"PrivateIpAddress"`
 			PlatformDetails string `json:"PlatformDetails"`
+			LaunchTime      string `json:"LaunchTime"`
 			State           struct {
 				Name string `json:"Name"`
 			} `json:"State"`
+			Placement struct {
+				AvailabilityZone string `json:"AvailabilityZone"`
+			} `json:"Placement"`
+			SecurityGroups []struct {
+				GroupID   string `json:"GroupId"`
+				GroupName string `json:"GroupName"`
+			} `json:"SecurityGroups"`
+			IAMInstanceProfile struct {
+				Arn string `json:"Arn"`
+			} `json:"IamInstanceProfile"`
 			Tags []struct {
 				Key   string `json:"Key"`
 				Value string `json:"Value"`
@@ -33,6 +48,13 @@ type ec2DescribeInstancesResponse struct {
 	} `json:"Reservations"`
 }
 
+type ssmInstanceInformationResponse struct {
+	InstanceInformationList []struct {
+		InstanceID string `json:"InstanceId"`
+		PingStatus string `json:"PingStatus"`
+	} `json:"InstanceInformationList"`
+}
+
 type ec2DescribeRegionsResponse struct {
 	Regions []struct {
 		RegionName string `json:"RegionName"`
@@ -66,12 +88,43 @@ type instanceCandidate struct {
 	ProfileName string
 	Region      string
 	InstanceID  string
+
+	AccountID          string
+	AccountName        string
+	RoleName           string
+	Name               string
+	PrivateIP          string
+	State              string
+	Platform           string
+	InstanceType       string
+	AvailabilityZone   string
+	LaunchTime         string
+	SecurityGroups     []string
+	IAMInstanceProfile string
+	PingStatus         string
+	Tags               map[string]string
 }
 
 type scanResult struct {
 	Candidates []instanceCandidate
+	Done       int
+	Total      int
+	// Stale counts scanned scopes served from stale cache while they refresh.
+	Stale int
+	Err   error
 }
 
+// pickerAction is what the user asked for when leaving the instance picker.
+type pickerAction int
+
+const (
+	pickerConnect pickerAction = iota
+	pickerBack
+	pickerBackToAccounts
+	pickerBackToRoles
+	pickerPortForward
+)
+
 type Options struct {
 	Profile              string
 	Workers              int
@@ -82,9 +135,17 @@ type Options struct {
 	AllRegions           bool
 	SkipRegionSelect     bool
 	IncludeStopped       bool
+	SSMStatus            bool
 	Resume               bool
 	Last                 bool
+	LastN                int
+	History              bool
+	Favorite             string
+	Preset               string
+	Document             string
+	Filter               string
 	NoAutoSelect         bool
+	Picker               string
 	ConfigPath           string
 	WriteConfigExample   bool
 	PrintEffectiveConfig bool
@@ -97,6 +158,21 @@ type Options struct {
 	CacheTTLInstances    time.Duration
 	CacheMode            string
 	CacheClear           bool
+	CacheEncrypt         bool
+	CacheKeyCommand      string
+	Timeout              time.Duration
+	CallTimeout          time.Duration
 	ValueSource          map[string]string
 	cacheStore           *cacheStore
+	budget               *discoveryBudget
+	favorite             *favoriteTarget
+	accountOverrides     []accountOverride
+	configFiles          []string
+	environments         []accountEnvironment
+	policy               accessPolicy
+	filter               filterExpr
+	progress             io.Writer
+	previewCommand       string
+	usage                map[string]float64
+	pins                 []favoritePin
 }
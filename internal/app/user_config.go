@@ -3,23 +3,34 @@ package app
 import (
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
-
-	"gopkg.in/yaml.v3"
 )
 
 const defaultConfigRelPath = ".config/swamp/config.yaml"
 
 type UserConfig struct {
-	Profile       string          `yaml:"profile"`
-	PreferredRole string          `yaml:"preferred_role"`
-	Cache         userConfigCache `yaml:"cache"`
-	Discovery     userConfigDisc  `yaml:"discovery"`
-	UX            userConfigUX    `yaml:"ux"`
+	Profile       string                      `yaml:"profile"`
+	PreferredRole string                      `yaml:"preferred_role"`
+	Cache         userConfigCache             `yaml:"cache"`
+	Discovery     userConfigDisc              `yaml:"discovery"`
+	UX            userConfigUX                `yaml:"ux"`
+	Presets       map[string]userConfigPreset `yaml:"presets"`
+	Accounts      userConfigAccounts          `yaml:"accounts"`
+	Environments  userConfigEnvironments      `yaml:"environments"`
+	Policy        userConfigPolicy            `yaml:"policy"`
+	Include       []string                    `yaml:"include"`
+	Locked        []string                    `yaml:"locked"`
+
+	// origins maps dotted keys to the file that supplied them when the
+	// config was merged from several files.
+	origins map[string]string
+	files   []string
 }
 
 type userConfigCache struct {
@@ -30,6 +41,8 @@ type userConfigCache struct {
 	TTLRoles     string `yaml:"ttl_roles"`
 	TTLRegions   string `yaml:"ttl_regions"`
 	TTLInstances string `yaml:"ttl_instances"`
+	Encrypt      *bool  `yaml:"encrypt"`
+	KeyCommand   string `yaml:"key_command"`
 }
 
 type userConfigDisc struct {
@@ -37,18 +50,38 @@ type userConfigDisc struct {
 	Regions        []string `yaml:"regions"`
 	AllRegions     *bool    `yaml:"all_regions"`
 	IncludeStopped *bool    `yaml:"include_stopped"`
+	SSMStatus      *bool    `yaml:"ssm_status"`
+	Timeout        string   `yaml:"timeout"`
+	CallTimeout    string   `yaml:"call_timeout"`
+	Filter         string   `yaml:"filter"`
 }
 
 type userConfigUX struct {
-	AutoSelectSingle *bool `yaml:"auto_select_single"`
-	ResumeByDefault  *bool `yaml:"resume_by_default"`
-	SkipRegionSelect *bool `yaml:"skip_region_select"`
+	AutoSelectSingle *bool  `yaml:"auto_select_single"`
+	ResumeByDefault  *bool  `yaml:"resume_by_default"`
+	SkipRegionSelect *bool  `yaml:"skip_region_select"`
+	Picker           string `yaml:"picker"`
+}
+
+// userConfigPreset is a named set of run options selected with --preset NAME
+// or `swamp NAME`.
+type userConfigPreset struct {
+	Profile        string   `yaml:"profile"`
+	Account        string   `yaml:"account"`
+	Role           string   `yaml:"role"`
+	Regions        []string `yaml:"regions"`
+	IncludeStopped *bool    `yaml:"include_stopped"`
+	Document       string   `yaml:"document"`
+	Filter         string   `yaml:"filter"`
 }
 
 func resolveConfigPath(cliPath string) string {
 	if strings.TrimSpace(cliPath) != "" {
 		return expandTilde(cliPath)
 	}
+	if env := strings.TrimSpace(os.Getenv(configPathEnv)); env != "" {
+		return expandTilde(env)
+	}
 	home, err := os.UserHomeDir()
 	if err != nil || strings.TrimSpace(home) == "" {
 		return defaultConfigRelPath
@@ -56,32 +89,7 @@ func resolveConfigPath(cliPath string) string {
 	return filepath.Join(home, defaultConfigRelPath)
 }
 
-func loadUserConfig(path string) (UserConfig, error) {
-	data, err := os.ReadFile(path)
-	if err != nil {
-		if os.IsNotExist(err) {
-			return UserConfig{}, nil
-		}
-		return UserConfig{}, fmt.Errorf("read config file %q: %w", path, err)
-	}
-	if len(strings.TrimSpace(string(data))) == 0 {
-		return UserConfig{}, nil
-	}
-
-	var root map[string]any
-	if err := yaml.Unmarshal(data, &root); err != nil {
-		return UserConfig{}, fmt.Errorf("parse config file %q: %w", path, err)
-	}
-	warnUnknownConfigKeys(root)
-
-	var cfg UserConfig
-	if err := yaml.Unmarshal(data, &cfg); err != nil {
-		return UserConfig{}, fmt.Errorf("decode config file %q: %w", path, err)
-	}
-	return cfg, nil
-}
-
-func ensureDefaultConfigFile(path string) error {
+func ensureDefaultConfigFile(w io.Writer, path string) error {
 	target := resolveConfigPath(path)
 	if _, err := os.Stat(target); err == nil {
 		return nil
@@ -89,14 +97,14 @@ func ensureDefaultConfigFile(path string) error {
 		return fmt.Errorf("stat config file %q: %w", target, err)
 	}
 
-	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+	if err := mkdirPrivate(filepath.Dir(target)); err != nil {
 		return fmt.Errorf("create config directory: %w", err)
 	}
 	content := defaultConfigContent()
-	if err := os.WriteFile(target, []byte(content), 0o644); err != nil {
+	if err := os.WriteFile(target, []byte(content), 0o600); err != nil {
 		return fmt.Errorf("write default config file %q: %w", target, err)
 	}
-	fmt.Printf("Created default config at %s\n", target)
+	fmt.Fprintf(w, "Created default config at %s\n", target)
 	return nil
 }
 
@@ -111,6 +119,7 @@ func mergeOptions(cli Options, cfg UserConfig) (Options, error) {
 		"all-regions":         "built-in",
 		"skip-region-select":  "built-in",
 		"include-stopped":     "built-in",
+		"ssm-status":          "built-in",
 		"cache":               "built-in",
 		"cache-dir":           "built-in",
 		"cache-mode":          "built-in",
@@ -118,9 +127,16 @@ func mergeOptions(cli Options, cfg UserConfig) (Options, error) {
 		"cache-ttl-roles":     "built-in",
 		"cache-ttl-regions":   "built-in",
 		"cache-ttl-instances": "built-in",
+		"cache-encrypt":       "built-in",
+		"timeout":             "built-in",
+		"call-timeout":        "built-in",
 		"resume":              "built-in",
 		"last":                "built-in",
 		"no-auto-select":      "built-in",
+		"picker":              "built-in",
+		"preset":              "built-in",
+		"document":            "built-in",
+		"filter":              "built-in",
 	}
 
 	setFromConfig := func(name string) bool {
@@ -134,44 +150,55 @@ func mergeOptions(cli Options, cfg UserConfig) (Options, error) {
 
 	if setFromConfig("profile") && strings.TrimSpace(cfg.Profile) != "" {
 		out.Profile = strings.TrimSpace(cfg.Profile)
-		sources["profile"] = "config"
+		sources["profile"] = cfg.source("profile", "config")
 	}
 	if setFromConfig("role") && strings.TrimSpace(cfg.PreferredRole) != "" {
 		out.RoleFilter = strings.TrimSpace(cfg.PreferredRole)
 		out.RoleFromPreferred = true
-		sources["role"] = "config(preferred_role)"
+		sources["role"] = cfg.source("preferred_role", "config(preferred_role)")
 	}
 	if setFromConfig("workers") && cfg.Discovery.Workers != nil {
 		out.Workers = *cfg.Discovery.Workers
-		sources["workers"] = "config"
+		sources["workers"] = cfg.source("discovery.workers", "config")
 	}
 	if setFromConfig("regions") && len(cfg.Discovery.Regions) > 0 {
 		out.RegionsArg = strings.Join(cfg.Discovery.Regions, ",")
-		sources["regions"] = "config"
+		sources["regions"] = cfg.source("discovery.regions", "config")
 	}
 	if setFromConfig("all-regions") && cfg.Discovery.AllRegions != nil {
 		out.AllRegions = *cfg.Discovery.AllRegions
-		sources["all-regions"] = "config"
+		sources["all-regions"] = cfg.source("discovery.all_regions", "config")
 	}
 	if setFromConfig("skip-region-select") && cfg.UX.SkipRegionSelect != nil {
 		out.SkipRegionSelect = *cfg.UX.SkipRegionSelect
-		sources["skip-region-select"] = "config(ux.skip_region_select)"
+		sources["skip-region-select"] = cfg.source("ux.skip_region_select", "config(ux.skip_region_select)")
 	}
 	if setFromConfig("include-stopped") && cfg.Discovery.IncludeStopped != nil {
 		out.IncludeStopped = *cfg.Discovery.IncludeStopped
-		sources["include-stopped"] = "config"
+		sources["include-stopped"] = cfg.source("discovery.include_stopped", "config")
+	}
+	if setFromConfig("ssm-status") && cfg.Discovery.SSMStatus != nil {
+		out.SSMStatus = *cfg.Discovery.SSMStatus
+		sources["ssm-status"] = cfg.source("discovery.ssm_status", "config")
 	}
 	if setFromConfig("cache") && cfg.Cache.Enabled != nil {
 		out.CacheEnabled = *cfg.Cache.Enabled
-		sources["cache"] = "config"
+		sources["cache"] = cfg.source("cache.enabled", "config")
 	}
 	if setFromConfig("cache-dir") && strings.TrimSpace(cfg.Cache.Dir) != "" {
 		out.CacheDir = expandTilde(strings.TrimSpace(cfg.Cache.Dir))
-		sources["cache-dir"] = "config"
+		sources["cache-dir"] = cfg.source("cache.dir", "config")
 	}
 	if setFromConfig("cache-mode") && strings.TrimSpace(cfg.Cache.Mode) != "" {
 		out.CacheMode = strings.TrimSpace(cfg.Cache.Mode)
-		sources["cache-mode"] = "config"
+		sources["cache-mode"] = cfg.source("cache.mode", "config")
+	}
+	if setFromConfig("cache-encrypt") && cfg.Cache.Encrypt != nil {
+		out.CacheEncrypt = *cfg.Cache.Encrypt
+		sources["cache-encrypt"] = cfg.source("cache.encrypt", "config")
+	}
+	if strings.TrimSpace(cfg.Cache.KeyCommand) != "" {
+		out.CacheKeyCommand = strings.TrimSpace(cfg.Cache.KeyCommand)
 	}
 
 	var err error
@@ -180,36 +207,77 @@ func mergeOptions(cli Options, cfg UserConfig) (Options, error) {
 		if err != nil {
 			return Options{}, err
 		}
-		sources["cache-ttl-accounts"] = "config"
+		sources["cache-ttl-accounts"] = cfg.source("cache.ttl_accounts", "config")
 	}
 	if setFromConfig("cache-ttl-roles") && strings.TrimSpace(cfg.Cache.TTLRoles) != "" {
 		out.CacheTTLRoles, err = parseConfigDuration("cache.ttl_roles", cfg.Cache.TTLRoles)
 		if err != nil {
 			return Options{}, err
 		}
-		sources["cache-ttl-roles"] = "config"
+		sources["cache-ttl-roles"] = cfg.source("cache.ttl_roles", "config")
 	}
 	if setFromConfig("cache-ttl-regions") && strings.TrimSpace(cfg.Cache.TTLRegions) != "" {
 		out.CacheTTLRegions, err = parseConfigDuration("cache.ttl_regions", cfg.Cache.TTLRegions)
 		if err != nil {
 			return Options{}, err
 		}
-		sources["cache-ttl-regions"] = "config"
+		sources["cache-ttl-regions"] = cfg.source("cache.ttl_regions", "config")
 	}
 	if setFromConfig("cache-ttl-instances") && strings.TrimSpace(cfg.Cache.TTLInstances) != "" {
 		out.CacheTTLInstances, err = parseConfigDuration("cache.ttl_instances", cfg.Cache.TTLInstances)
 		if err != nil {
 			return Options{}, err
 		}
-		sources["cache-ttl-instances"] = "config"
+		sources["cache-ttl-instances"] = cfg.source("cache.ttl_instances", "config")
+	}
+	if setFromConfig("timeout") && strings.TrimSpace(cfg.Discovery.Timeout) != "" {
+		out.Timeout, err = parseConfigDuration("discovery.timeout", cfg.Discovery.Timeout)
+		if err != nil {
+			return Options{}, err
+		}
+		sources["timeout"] = cfg.source("discovery.timeout", "config")
+	}
+	if setFromConfig("call-timeout") && strings.TrimSpace(cfg.Discovery.CallTimeout) != "" {
+		out.CallTimeout, err = parseConfigDuration("discovery.call_timeout", cfg.Discovery.CallTimeout)
+		if err != nil {
+			return Options{}, err
+		}
+		sources["call-timeout"] = cfg.source("discovery.call_timeout", "config")
+	}
+	if setFromConfig("filter") && strings.TrimSpace(cfg.Discovery.Filter) != "" {
+		out.Filter = strings.TrimSpace(cfg.Discovery.Filter)
+		sources["filter"] = cfg.source("discovery.filter", "config")
 	}
 	if setFromConfig("no-auto-select") && cfg.UX.AutoSelectSingle != nil {
 		out.NoAutoSelect = !*cfg.UX.AutoSelectSingle
-		sources["no-auto-select"] = "config(ux.auto_select_single)"
+		sources["no-auto-select"] = cfg.source("ux.auto_select_single", "config(ux.auto_select_single)")
+	}
+	if setFromConfig("picker") && strings.TrimSpace(cfg.UX.Picker) != "" {
+		out.Picker = strings.TrimSpace(cfg.UX.Picker)
+		sources["picker"] = cfg.source("ux.picker", "config(ux.picker)")
 	}
 	if setFromConfig("resume") && cfg.UX.ResumeByDefault != nil {
 		out.Resume = *cfg.UX.ResumeByDefault
-		sources["resume"] = "config(ux.resume_by_default)"
+		sources["resume"] = cfg.source("ux.resume_by_default", "config(ux.resume_by_default)")
+	}
+
+	out.accountOverrides, err = parseAccountOverrides(cfg.Accounts)
+	if err != nil {
+		return Options{}, err
+	}
+	out.environments, err = parseEnvironments(cfg.Environments)
+	if err != nil {
+		return Options{}, err
+	}
+	out.policy, err = parsePolicy(cfg.Policy)
+	if err != nil {
+		return Options{}, err
+	}
+	if err := applyEnv(&out, cli, sources); err != nil {
+		return Options{}, err
+	}
+	if err := applyPreset(&out, cli, cfg, sources); err != nil {
+		return Options{}, err
 	}
 
 	if cli.flagChanged("role") {
@@ -224,6 +292,7 @@ func mergeOptions(cli Options, cfg UserConfig) (Options, error) {
 	setFromFlag("all-regions", "all-regions")
 	setFromFlag("skip-region-select", "skip-region-select")
 	setFromFlag("include-stopped", "include-stopped")
+	setFromFlag("ssm-status", "ssm-status")
 	setFromFlag("cache", "cache")
 	setFromFlag("cache-dir", "cache-dir")
 	setFromFlag("cache-mode", "cache-mode")
@@ -231,40 +300,167 @@ func mergeOptions(cli Options, cfg UserConfig) (Options, error) {
 	setFromFlag("cache-ttl-roles", "cache-ttl-roles")
 	setFromFlag("cache-ttl-regions", "cache-ttl-regions")
 	setFromFlag("cache-ttl-instances", "cache-ttl-instances")
+	setFromFlag("cache-encrypt", "cache-encrypt")
+	setFromFlag("timeout", "timeout")
+	setFromFlag("call-timeout", "call-timeout")
 	setFromFlag("resume", "resume")
 	setFromFlag("last", "last")
 	setFromFlag("no-auto-select", "no-auto-select")
+	setFromFlag("picker", "picker")
+	setFromFlag("document", "document")
+	setFromFlag("filter", "filter")
+
+	out.filter, err = parseFilter(out.Filter)
+	if err != nil {
+		return Options{}, fmt.Errorf("%w (source=%s)", err, sources["filter"])
+	}
+	// state=stopped and friends only work if stopped instances are scanned.
+	if out.filter.wantsStopped() && !out.IncludeStopped && !cli.flagChanged("include-stopped") && !cfg.isLocked("discovery.include_stopped") {
+		out.IncludeStopped = true
+		sources["include-stopped"] = "filter"
+	}
+	if err := enforceConfigLocks(cfg, sources); err != nil {
+		return Options{}, err
+	}
 
 	out.ValueSource = sources
+	out.configFiles = cfg.files
 	return out, nil
 }
 
+// applyPreset layers the selected preset over config values; flags still
+// win because only unset flags are filled in.
+func applyPreset(out *Options, cli Options, cfg UserConfig, sources map[string]string) error {
+	name := strings.TrimSpace(out.Preset)
+	if name == "" {
+		return nil
+	}
+	preset, ok := cfg.Presets[name]
+	if !ok {
+		if len(cfg.Presets) == 0 {
+			return fmt.Errorf("unknown preset %q: no presets are defined in the config file (favorites are written as @alias)", name)
+		}
+		return fmt.Errorf("unknown preset %q (available: %s)", name, strings.Join(presetNames(cfg), ", "))
+	}
+	out.Preset = name
+	if sources["preset"] == "built-in" {
+		sources["preset"] = "flag"
+	}
+	source := "preset(" + name + ")"
+
+	if !cli.flagChanged("profile") && strings.TrimSpace(preset.Profile) != "" {
+		out.Profile = strings.TrimSpace(preset.Profile)
+		sources["profile"] = source
+	}
+	if !cli.flagChanged("account") && strings.TrimSpace(preset.Account) != "" {
+		out.AccountFilter = strings.TrimSpace(preset.Account)
+		sources["account"] = source
+	}
+	if !cli.flagChanged("role") && strings.TrimSpace(preset.Role) != "" {
+		out.RoleFilter = strings.TrimSpace(preset.Role)
+		out.RoleFromPreferred = false
+		sources["role"] = source
+	}
+	if !cli.flagChanged("regions") && len(preset.Regions) > 0 {
+		out.RegionsArg = strings.Join(preset.Regions, ",")
+		sources["regions"] = source
+	}
+	if !cli.flagChanged("include-stopped") && preset.IncludeStopped != nil {
+		out.IncludeStopped = *preset.IncludeStopped
+		sources["include-stopped"] = source
+	}
+	if !cli.flagChanged("filter") && strings.TrimSpace(preset.Filter) != "" {
+		out.Filter = strings.TrimSpace(preset.Filter)
+		sources["filter"] = source
+	}
+	if !cli.flagChanged("document") && strings.TrimSpace(preset.Document) != "" {
+		out.Document = strings.TrimSpace(preset.Document)
+		sources["document"] = source
+	}
+	return nil
+}
+
+func presetNames(cfg UserConfig) []string {
+	names := make([]string, 0, len(cfg.Presets))
+	for name := range cfg.Presets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
 func printEffectiveConfig(opts Options) {
-	fmt.Printf("profile: %s\n", opts.Profile)
-	fmt.Printf("role: %s\n", opts.RoleFilter)
-	fmt.Printf("workers: %d\n", opts.Workers)
-	fmt.Printf("regions: %s\n", opts.RegionsArg)
-	fmt.Printf("all_regions: %t\n", opts.AllRegions)
-	fmt.Printf("skip_region_select: %t\n", opts.SkipRegionSelect)
-	fmt.Printf("include_stopped: %t\n", opts.IncludeStopped)
-	fmt.Printf("resume: %t\n", opts.Resume)
-	fmt.Printf("last: %t\n", opts.Last)
-	fmt.Printf("no_auto_select: %t\n", opts.NoAutoSelect)
-	fmt.Printf("cache.enabled: %t\n", opts.CacheEnabled)
-	fmt.Printf("cache.dir: %s\n", opts.CacheDir)
-	fmt.Printf("cache.mode: %s\n", opts.CacheMode)
-	fmt.Printf("cache.ttl_accounts: %s\n", opts.CacheTTLAccounts)
-	fmt.Printf("cache.ttl_roles: %s\n", opts.CacheTTLRoles)
-	fmt.Printf("cache.ttl_regions: %s\n", opts.CacheTTLRegions)
-	fmt.Printf("cache.ttl_instances: %s\n", opts.CacheTTLInstances)
+	if len(opts.configFiles) > 0 {
+		fmt.Printf("config_files: %s\n", strings.Join(opts.configFiles, ", "))
+	}
+	line := func(name, sourceKey string, value any) {
+		fmt.Printf("%s: %v (source=%s)\n", name, value, sourceOf(opts, sourceKey))
+	}
+	if opts.Preset != "" {
+		line("preset", "preset", opts.Preset)
+	}
+	line("profile", "profile", opts.Profile)
+	line("account", "account", opts.AccountFilter)
+	line("role", "role", opts.RoleFilter)
+	line("workers", "workers", opts.Workers)
+	line("regions", "regions", opts.RegionsArg)
+	line("all_regions", "all-regions", opts.AllRegions)
+	line("skip_region_select", "skip-region-select", opts.SkipRegionSelect)
+	line("include_stopped", "include-stopped", opts.IncludeStopped)
+	line("discovery.ssm_status", "ssm-status", opts.SSMStatus)
+	line("resume", "resume", opts.Resume)
+	line("last", "last", opts.Last)
+	line("no_auto_select", "no-auto-select", opts.NoAutoSelect)
+	line("picker", "picker", opts.Picker)
+	line("document", "document", opts.Document)
+	line("filter", "filter", opts.Filter)
+	line("cache.enabled", "cache", opts.CacheEnabled)
+	line("cache.dir", "cache-dir", opts.CacheDir)
+	line("cache.mode", "cache-mode", opts.CacheMode)
+	line("cache.ttl_accounts", "cache-ttl-accounts", opts.CacheTTLAccounts)
+	line("cache.ttl_roles", "cache-ttl-roles", opts.CacheTTLRoles)
+	line("cache.ttl_regions", "cache-ttl-regions", opts.CacheTTLRegions)
+	line("cache.ttl_instances", "cache-ttl-instances", opts.CacheTTLInstances)
+	line("cache.encrypt", "cache-encrypt", opts.CacheEncrypt)
+	line("discovery.timeout", "timeout", opts.Timeout)
+	line("discovery.call_timeout", "call-timeout", opts.CallTimeout)
+	for _, o := range opts.accountOverrides {
+		fmt.Printf("accounts.%s: overrides for matching accounts\n", o.match)
+	}
+	policyLine := func(name string, values []string) {
+		if len(values) > 0 {
+			fmt.Printf("policy.%s: %s\n", name, strings.Join(values, ", "))
+		}
+	}
+	policyLine("allow_accounts", opts.policy.allowAccounts)
+	policyLine("deny_accounts", opts.policy.denyAccounts)
+	policyLine("deny_roles", opts.policy.denyRoles)
+	if opts.policy.readOnly {
+		fmt.Printf("policy.read_only: true (allowed_documents: %s)\n", strings.Join(opts.policy.allowedDocuments, ", "))
+	}
+	for _, env := range opts.environments {
+		fmt.Printf("environments.%s: %s (protected=%t)\n", env.Name, strings.Join(env.Accounts, ", "), env.Protected)
+	}
 }
 
 func configExample() string {
 	return renderConfigYAML("my-sso-profile", "AdministratorAccess")
 }
 
+// defaultConfigContent is the file created on first run. Every setting is
+// commented out so system and team config (and later built-in defaults) keep
+// applying until the user uncomments a key.
 func defaultConfigContent() string {
-	return renderConfigYAML("", "")
+	var b strings.Builder
+	b.WriteString("# swamp user config. Uncomment a key to override the built-in, system or\n")
+	b.WriteString("# team default shown; see `swamp --print-effective-config` for the effective values.\n\n")
+	for _, line := range strings.SplitAfter(renderConfigYAML("", ""), "\n") {
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "#") {
+			line = "# " + line
+		}
+		b.WriteString(line)
+	}
+	return b.String()
 }
 
 func renderConfigYAML(profile, preferredRole string) string {
@@ -279,17 +475,55 @@ cache:
   ttl_roles: 6h
   ttl_regions: 24h
   ttl_instances: 60s
+  encrypt: false
+  key_command: ""
 
 discovery:
   workers: 12
   regions: []
   all_regions: false
   include_stopped: false
+  ssm_status: false
+  timeout: 0s
+  call_timeout: 60s
 
 ux:
   auto_select_single: true
   resume_by_default: false
   skip_region_select: false
+  picker: auto
+
+# Named presets, selected with --preset NAME or "swamp NAME".
+# presets:
+#   prod-eu:
+#     profile: my-sso-profile
+#     account: prod
+#     role: ReadOnly
+#     regions: [eu-west-1, eu-central-1]
+#     include_stopped: false
+#     document: SessionManager-ProdShell
+
+# Per-account overrides, keyed by account ID or account-name glob.
+# The first matching entry applies once an account is selected.
+# accounts:
+#   "123456789012":
+#     regions: [eu-west-1]
+#   "prod-*":
+#     preferred_role: ReadOnly
+#     include_stopped: true
+#     ttl_instances: 5m
+#     document: SessionManager-ProdShell
+
+# Environment labels for pickers and the session banner. Protected accounts
+# ask you to type the account name (or confirm) before connecting.
+# environments:
+#   prod:
+#     accounts: ["123456789012", "prod-*"]
+#     color: red
+#     protected: true
+#   staging:
+#     accounts: ["staging-*"]
+#     color: yellow
 `, strconv.Quote(profile), strconv.Quote(preferredRole), strconv.Quote(defaultCacheDir()))
 }
 
@@ -299,13 +533,13 @@ func writeConfigExample(path string) error {
 		return nil
 	}
 	target := resolveConfigPath(path)
-	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
+	if err := mkdirPrivate(filepath.Dir(target)); err != nil {
 		return fmt.Errorf("create config directory: %w", err)
 	}
 	if _, err := os.Stat(target); err == nil {
 		return fmt.Errorf("config file already exists at %s", target)
 	}
-	if err := os.WriteFile(target, []byte(configExample()), 0o644); err != nil {
+	if err := os.WriteFile(target, []byte(configExample()), 0o600); err != nil {
 		return fmt.Errorf("write config file %q: %w", target, err)
 	}
 	fmt.Printf("Wrote example config to %s\n", target)
@@ -327,6 +561,12 @@ func warnUnknownConfigKeys(root map[string]any) {
 		"cache":          {},
 		"discovery":      {},
 		"ux":             {},
+		"presets":        {},
+		"accounts":       {},
+		"environments":   {},
+		"policy":         {},
+		"include":        {},
+		"locked":         {},
 	}
 	knownCache := map[string]struct{}{
 		"enabled":       {},
@@ -336,17 +576,53 @@ func warnUnknownConfigKeys(root map[string]any) {
 		"ttl_roles":     {},
 		"ttl_regions":   {},
 		"ttl_instances": {},
+		"encrypt":       {},
+		"key_command":   {},
 	}
 	knownDiscovery := map[string]struct{}{
 		"workers":         {},
 		"regions":         {},
 		"all_regions":     {},
 		"include_stopped": {},
+		"ssm_status":      {},
+		"timeout":         {},
+		"call_timeout":    {},
+		"filter":          {},
 	}
 	knownUX := map[string]struct{}{
 		"auto_select_single": {},
 		"resume_by_default":  {},
 		"skip_region_select": {},
+		"picker":             {},
+	}
+	knownPreset := map[string]struct{}{
+		"profile":         {},
+		"account":         {},
+		"role":            {},
+		"regions":         {},
+		"include_stopped": {},
+		"document":        {},
+		"filter":          {},
+	}
+	knownPolicy := map[string]struct{}{
+		"allow_accounts":    {},
+		"deny_accounts":     {},
+		"deny_roles":        {},
+		"read_only":         {},
+		"allowed_documents": {},
+	}
+	knownEnvironment := map[string]struct{}{
+		"accounts":  {},
+		"color":     {},
+		"protected": {},
+		"confirm":   {},
+	}
+	knownAccount := map[string]struct{}{
+		"regions":         {},
+		"preferred_role":  {},
+		"include_stopped": {},
+		"ttl_instances":   {},
+		"document":        {},
 	}
 
 	for k, v := range root {
@@ -361,6 +637,26 @@ func warnUnknownConfigKeys(root map[string]any) {
 			warnUnknownNested("discovery", v, knownDiscovery)
 		case "ux":
 			warnUnknownNested("ux", v, knownUX)
+		case "presets":
+			if presets, ok := v.(map[string]any); ok {
+				for name, preset := range presets {
+					warnUnknownNested("presets."+name, preset, knownPreset)
+				}
+			}
+		case "policy":
+			warnUnknownNested("policy", v, knownPolicy)
+		case "environments":
+			if environments, ok := v.(map[string]any); ok {
+				for name, env := range environments {
+					warnUnknownNested("environments."+name, env, knownEnvironment)
+				}
+			}
+		case "accounts":
+			if accounts, ok := v.(map[string]any); ok {
+				for match, account := range accounts {
+					warnUnknownNested("accounts."+match, account, knownAccount)
+				}
+			}
 		}
 	}
 }
@@ -421,6 +717,12 @@ func validateOptionsWithSource(opts Options) error {
 				sourceOf(opts, "cache-ttl-regions"),
 				sourceOf(opts, "cache-ttl-instances"),
 			)
+		case strings.Contains(msg, "--timeout"):
+			return fmt.Errorf("%s (source=%s)", msg, sourceOf(opts, "timeout"))
+		case strings.Contains(msg, "--call-timeout"):
+			return fmt.Errorf("%s (source=%s)", msg, sourceOf(opts, "call-timeout"))
+		case strings.Contains(msg, "--picker"):
+			return fmt.Errorf("%s (source=%s)", msg, sourceOf(opts, "picker"))
 		case strings.Contains(msg, "--cache-mode"):
 			return fmt.Errorf("%s (source=%s)", msg, sourceOf(opts, "cache-mode"))
 		case strings.Contains(msg, "--profile"):
@@ -438,3 +740,32 @@ func (o Options) flagChanged(name string) bool {
 	}
 	return o.FlagSet[name]
 }
+
+// resolveLocalOptions layers the config file under the flags of commands
+// that only touch local state (favorites, cache maintenance), so profile,
+// cache dir and cache key match what a normal run would use.
+func resolveLocalOptions(opts Options) (Options, error) {
+	merged, err := resolveLocalConfig(opts)
+	if err != nil {
+		return Options{}, err
+	}
+	if err := configureAtRest(merged); err != nil {
+		return Options{}, err
+	}
+	return merged, nil
+}
+
+// resolveLocalConfig is resolveLocalOptions without picking the cache key.
+func resolveLocalConfig(opts Options) (Options, error) {
+	configPath := resolveConfigPath(opts.ConfigPath)
+	cfgFile, err := loadLayeredConfig(configPath)
+	if err != nil {
+		return Options{}, err
+	}
+	merged, err := mergeOptions(opts, cfgFile)
+	if err != nil {
+		return Options{}, err
+	}
+	merged.ConfigPath = configPath
+	return merged, nil
+}
@@ -1,6 +1,7 @@
 package app
 
 import (
+	"io"
 	"os"
 	"path/filepath"
 	"strings"
@@ -9,7 +10,8 @@ import (
 )
 
 func TestLoadUserConfigMissingFileReturnsEmpty(t *testing.T) {
-	cfg, err := loadUserConfig(filepath.Join(t.TempDir(), "missing.yaml"))
+	isolateConfigLayers(t)
+	cfg, err := loadLayeredConfig(filepath.Join(t.TempDir(), "missing.yaml"))
 	if err != nil {
 		t.Fatalf("expected no error, got %v", err)
 	}
@@ -65,6 +67,49 @@ func TestMergeOptionsPrecedenceFlagsOverConfig(t *testing.T) {
 	}
 }
 
+func TestMergeOptionsPresetLayersBetweenConfigAndFlags(t *testing.T) {
+	cli := Options{
+		Preset:     "prod-eu",
+		RegionsArg: "us-east-1",
+		FlagSet:    map[string]bool{"regions": true},
+	}
+	cfg := UserConfig{
+		Profile:       "config-profile",
+		PreferredRole: "Admin",
+		Presets: map[string]userConfigPreset{
+			"prod-eu": {
+				Profile:  "prod-sso",
+				Account:  "prod",
+				Role:     "ReadOnly",
+				Regions:  []string{"eu-west-1"},
+				Document: "SessionManager-ProdShell",
+			},
+		},
+	}
+
+	got, err := mergeOptions(cli, cfg)
+	if err != nil {
+		t.Fatalf("mergeOptions failed: %v", err)
+	}
+	if got.Profile != "prod-sso" || got.AccountFilter != "prod" || got.Document != "SessionManager-ProdShell" {
+		t.Fatalf("expected preset values, got %+v", got)
+	}
+	if got.RoleFilter != "ReadOnly" || got.RoleFromPreferred {
+		t.Fatalf("expected preset role to replace preferred_role, got role=%q preferred=%t", got.RoleFilter, got.RoleFromPreferred)
+	}
+	if got.RegionsArg != "us-east-1" || sourceOf(got, "regions") != "flag" {
+		t.Fatalf("expected flag regions to win, got %q (source=%s)", got.RegionsArg, sourceOf(got, "regions"))
+	}
+	if src := sourceOf(got, "profile"); src != "preset(prod-eu)" {
+		t.Fatalf("expected preset source, got %q", src)
+	}
+
+	cli.Preset = "missing"
+	if _, err := mergeOptions(cli, cfg); err == nil || !strings.Contains(err.Error(), "prod-eu") {
+		t.Fatalf("expected unknown preset error listing presets, got %v", err)
+	}
+}
+
 func TestMergeOptionsSkipRegionSelectFromConfig(t *testing.T) {
 	cli := Options{
 		Workers: 1,
@@ -113,7 +158,7 @@ func TestWriteConfigExample(t *testing.T) {
 
 func TestEnsureDefaultConfigFileCreatesOnce(t *testing.T) {
 	path := filepath.Join(t.TempDir(), "config.yaml")
-	if err := ensureDefaultConfigFile(path); err != nil {
+	if err := ensureDefaultConfigFile(io.Discard, path); err != nil {
 		t.Fatalf("ensureDefaultConfigFile failed: %v", err)
 	}
 	first, err := os.ReadFile(path)
@@ -128,7 +173,7 @@ func TestEnsureDefaultConfigFileCreatesOnce(t *testing.T) {
 	if err := os.WriteFile(path, []byte("profile: keep\n"), 0o644); err != nil {
 		t.Fatalf("overwrite setup failed: %v", err)
 	}
-	if err := ensureDefaultConfigFile(path); err != nil {
+	if err := ensureDefaultConfigFile(io.Discard, path); err != nil {
 		t.Fatalf("ensureDefaultConfigFile second call failed: %v", err)
 	}
 	second, err := os.ReadFile(path)
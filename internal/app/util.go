@@ -1,6 +1,10 @@
 package app
 
-import "strings"
+import (
+	"io"
+	"os"
+	"strings"
+)
 
 func findTag(tags []struct {
 	Key   string `json:"Key"`
@@ -14,6 +18,15 @@ func findTag(tags []struct {
 	return ""
 }
 
+// progressOut is where login and discovery report progress: stdout for the
+// interactive flow, stderr for commands whose stdout carries data.
+func (o Options) progressOut() io.Writer {
+	if o.progress != nil {
+		return o.progress
+	}
+	return os.Stdout
+}
+
 func targetKey(t roleTarget) string {
 	return t.AccountID + "|" + t.RoleName
 }
@@ -0,0 +1,241 @@
+package app
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+var loadSSOAccessTokenFn = loadSSOAccessToken
+
+// warmSummary is what `swamp warm --quiet` prints as a single JSON line.
+type warmSummary struct {
+	Profile        string   `json:"profile"`
+	OK             bool     `json:"ok"`
+	Accounts       int      `json:"accounts"`
+	Roles          int      `json:"roles"`
+	Regions        int      `json:"regions"`
+	InstanceScopes int      `json:"instance_scopes"`
+	Instances      int      `json:"instances"`
+	Failures       []string `json:"failures,omitempty"`
+	DurationMS     int64    `json:"duration_ms"`
+}
+
+// Warm refreshes every cache entry the profile can see so the next
+// interactive run starts from cache. It never opens a browser for SSO
+// login, so it is safe to run from cron or launchd.
+func Warm(ctx context.Context, opts Options, withInstances, quiet bool) error {
+	resolved, cfg, err := resolveRuntimeOptions(opts)
+	if err != nil {
+		return err
+	}
+	if err := validateOptionsWithSource(resolved); err != nil {
+		return err
+	}
+	if err := configureAtRest(resolved); err != nil {
+		return err
+	}
+	if !resolved.CacheEnabled {
+		return fmt.Errorf("cache is disabled (source=%s); nothing to warm", sourceOf(resolved, "cache"))
+	}
+	if !cfg.SourceExists {
+		return fmt.Errorf("profile %q was not found in ~/.aws/config", resolved.Profile)
+	}
+	accessToken, err := loadSSOAccessTokenFn(cfg.SSOStartURL)
+	if err != nil {
+		return fmt.Errorf("no valid SSO session for profile %q (run `aws sso login --profile %s`): %w", resolved.Profile, resolved.Profile, err)
+	}
+
+	// Fresh mode skips cache reads so every entry is fetched and rewritten.
+	resolved.CacheMode = string(cacheModeFresh)
+	resolved.cacheStore = newCacheStore(resolved)
+	resolved.cacheStore.ctx = ctx
+	resolved.budget = newDiscoveryBudget(resolved.Timeout)
+
+	var log io.Writer = os.Stdout
+	if quiet {
+		log = io.Discard
+	}
+	summary := warmCache(ctx, resolved, cfg, accessToken, withInstances, log)
+	if quiet {
+		line, err := json.Marshal(summary)
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(line))
+	} else {
+		fmt.Printf("Warmed %d accounts, %d roles, %d regions", summary.Accounts, summary.Roles, summary.Regions)
+		if withInstances {
+			fmt.Printf(", %d instance lists (%d instances)", summary.InstanceScopes, summary.Instances)
+		}
+		fmt.Printf(" in %s\n", time.Duration(summary.DurationMS)*time.Millisecond)
+		for _, f := range summary.Failures {
+			fmt.Printf("  failed: %s\n", f)
+		}
+	}
+	if !summary.OK {
+		return fmt.Errorf("cache warm finished with %d failure(s)", len(summary.Failures))
+	}
+	return nil
+}
+
+// warmCache walks accounts, roles, regions and optionally instances,
+// writing each through the cache. Failures are collected rather than
+// aborting, so one broken account does not leave the rest cold.
+func warmCache(ctx context.Context, opts Options, cfg profileConfig, accessToken string, withInstances bool, log io.Writer) (summary warmSummary) {
+	started := time.Now()
+	summary.Profile = opts.Profile
+	var mu sync.Mutex
+	fail := func(format string, args ...any) {
+		mu.Lock()
+		defer mu.Unlock()
+		summary.Failures = append(summary.Failures, fmt.Sprintf(format, args...))
+	}
+	defer func() {
+		summary.OK = len(summary.Failures) == 0
+		summary.DurationMS = time.Since(started).Milliseconds()
+	}()
+
+	discoveryCtx, cancel := opts.discoveryContext(ctx)
+	defer cancel()
+	ssoRegion := resolveSSORegion(cfg)
+
+	fmt.Fprintln(log, "Warming accounts...")
+	accounts, err := listSSOAccountsCached(discoveryCtx, opts, ssoRegion, accessToken)
+	if err != nil {
+		fail("accounts: %v", describeContextError(opts, err))
+		return summary
+	}
+	accounts = opts.filter.filterAccounts(filterAccounts(opts.policy.filterAccounts(accounts), opts.AccountFilter))
+	summary.Accounts = len(accounts)
+
+	fmt.Fprintf(log, "Warming roles for %d accounts...\n", len(accounts))
+	var targets []roleTarget
+	forEachParallel(discoveryCtx, opts.Workers, len(accounts), func(i int) {
+		if len(accounts[i].AccountList) == 0 {
+			return
+		}
+		acct := accounts[i].AccountList[0]
+		roles, err := listRolesForAccountCached(discoveryCtx, opts, ssoRegion, accessToken, acct.AccountID, acct.AccountName)
+		if err != nil {
+			fail("roles for account %s: %v", acct.AccountID, err)
+			return
+		}
+		mu.Lock()
+		targets = append(targets, roles...)
+		mu.Unlock()
+	})
+	if discoveryCtx.Err() != nil {
+		fail("roles: %v", describeContextError(opts, context.Cause(discoveryCtx)))
+		return summary
+	}
+	summary.Roles = len(targets)
+	targets = opts.filter.filterTargets(filterRoleTargets(opts.policy.filterTargets(targets), opts.RoleFilter))
+	if len(targets) == 0 {
+		return summary
+	}
+
+	tmpConfigPath, profileNames, err := buildTempAWSConfigFn(cfg, targets)
+	if err != nil {
+		fail("temporary AWS config: %v", err)
+		return summary
+	}
+	defer func() { _ = removeFileFn(tmpConfigPath) }()
+
+	// Regions are resolved per account, with that account's overrides, the
+	// way an interactive run does once the account is picked; discovering
+	// through the account's first target fills the same cache entry.
+	type scope struct {
+		target   roleTarget
+		region   string
+		scanOpts Options
+	}
+	var scopes []scope
+	seenRegions := map[string]bool{}
+	fmt.Fprintln(log, "Warming regions...")
+	for _, acctID := range targetAccountIDs(targets) {
+		acctTargets := targetsInAccount(targets, acctID)
+		acctOpts := opts.forAccount(acctID, acctTargets[0].AccountName)
+		regions, err := discoverRegionsFn(discoveryCtx, acctOpts, cfg, acctTargets, tmpConfigPath, profileNames, ssoRegion)
+		if err != nil {
+			fail("regions for account %s: %v", acctID, describeContextError(opts, err))
+			continue
+		}
+		for _, r := range regions {
+			seenRegions[r] = true
+		}
+		for _, t := range acctTargets {
+			for _, r := range regions {
+				scopes = append(scopes, scope{target: t, region: r, scanOpts: acctOpts})
+			}
+		}
+	}
+	summary.Regions = len(seenRegions)
+	if !withInstances {
+		return summary
+	}
+
+	fmt.Fprintf(log, "Warming instances for %d account/role/region combinations...\n", len(scopes))
+	forEachParallel(discoveryCtx, opts.Workers, len(scopes), func(i int) {
+		s := scopes[i]
+		cands, err := queryInstancesCached(discoveryCtx, s.scanOpts, tmpConfigPath, s.target, profileNames[targetKey(s.target)], s.region, !s.scanOpts.IncludeStopped)
+		if err != nil {
+			fail("instances for %s/%s/%s: %v", s.target.AccountID, s.target.RoleName, s.region, err)
+			return
+		}
+		mu.Lock()
+		summary.InstanceScopes++
+		summary.Instances += len(cands)
+		mu.Unlock()
+	})
+	if discoveryCtx.Err() != nil {
+		fail("instances: %v", describeContextError(opts, context.Cause(discoveryCtx)))
+	}
+	return summary
+}
+
+// targetAccountIDs lists the accounts in targets in first-seen order.
+func targetAccountIDs(targets []roleTarget) []string {
+	var ids []string
+	seen := map[string]bool{}
+	for _, t := range targets {
+		if !seen[t.AccountID] {
+			seen[t.AccountID] = true
+			ids = append(ids, t.AccountID)
+		}
+	}
+	return ids
+}
+
+// forEachParallel calls fn for 0..n-1 on up to workers goroutines and
+// stops handing out work once ctx is done.
+func forEachParallel(ctx context.Context, workers, n int, fn func(i int)) {
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				fn(i)
+			}
+		}()
+	}
+feed:
+	for i := 0; i < n; i++ {
+		select {
+		case jobs <- i:
+		case <-ctx.Done():
+			break feed
+		}
+	}
+	close(jobs)
+	wg.Wait()
+}
@@ -0,0 +1,161 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func installWarmTestSeams(t *testing.T) {
+	t.Helper()
+	origAccounts := listSSOAccountsFetcher
+	origRoles := fetchRolesForAcctFetcher
+	origRegions := resolveRegionsFetcher
+	origInstances := queryInstancesFetcher
+	origBuild := buildTempAWSConfigFn
+	origRemove := removeFileFn
+	t.Cleanup(func() {
+		listSSOAccountsFetcher = origAccounts
+		fetchRolesForAcctFetcher = origRoles
+		resolveRegionsFetcher = origRegions
+		queryInstancesFetcher = origInstances
+		buildTempAWSConfigFn = origBuild
+		removeFileFn = origRemove
+	})
+
+	listSSOAccountsFetcher = func(ctx context.Context, profile, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
+		return []ssoAccountsResponse{testAccount("111", "prod"), testAccount("222", "dev")}, nil
+	}
+	fetchRolesForAcctFetcher = func(ctx context.Context, profile, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
+		return []roleTarget{{AccountID: accountID, AccountName: accountName, RoleName: "Admin"}}, nil
+	}
+	resolveRegionsFetcher = func(ctx context.Context, tmpConfigPath, profile, discoveryRegion, regionsArg string, includeAllRegions bool) ([]string, error) {
+		return []string{"eu-west-1", "us-east-1"}, nil
+	}
+	queryInstancesFetcher = func(ctx context.Context, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+		return []instanceCandidate{{InstanceID: "i-" + target.AccountID + "-" + region}}, nil
+	}
+	buildTempAWSConfigFn = func(base profileConfig, targets []roleTarget) (string, map[string]string, error) {
+		names := map[string]string{}
+		for i, tgt := range targets {
+			names[targetKey(tgt)] = fmt.Sprintf("swamp-%d", i+1)
+		}
+		return "/tmp/mock-config.ini", names, nil
+	}
+	removeFileFn = func(path string) error { return nil }
+}
+
+func TestWarmCacheFillsEveryLevel(t *testing.T) {
+	installWarmTestSeams(t)
+	opts := newTestCacheOptions(t, "fresh")
+	opts.Workers = 3
+
+	summary := warmCache(context.Background(), opts, profileConfig{}, "token", true, io.Discard)
+	if !summary.OK || summary.Accounts != 2 || summary.Roles != 2 || summary.Regions != 2 || summary.InstanceScopes != 4 || summary.Instances != 4 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+
+	// A balanced run afterwards is served entirely from cache.
+	balanced := opts
+	balanced.CacheMode = "balanced"
+	balanced.cacheStore = newCacheStore(balanced)
+	listSSOAccountsFetcher = func(ctx context.Context, profile, ssoRegion, accessToken string) ([]ssoAccountsResponse, error) {
+		t.Fatal("accounts should come from cache")
+		return nil, nil
+	}
+	if _, err := listSSOAccountsCached(context.Background(), balanced, "us-east-1", "token"); err != nil {
+		t.Fatal(err)
+	}
+	queryInstancesFetcher = func(ctx context.Context, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+		t.Fatal("instances should come from cache")
+		return nil, nil
+	}
+	cands, err := queryInstancesCached(context.Background(), balanced, "", roleTarget{AccountID: "222", RoleName: "Admin"}, "swamp-2", "eu-west-1", true)
+	if err != nil || len(cands) != 1 {
+		t.Fatalf("expected cached instances, got %+v (err=%v)", cands, err)
+	}
+	var regions []string
+	if status, _, _ := balanced.cacheStore.readJSON(balanced.Profile, cacheKeyRegions(balanced.Profile, "swamp-1", "us-east-1", false), &regions); status != cacheHitFresh {
+		t.Fatalf("expected regions under the interactive cache key, got %v", status)
+	}
+}
+
+func TestWarmCacheCollectsFailuresAndKeepsGoing(t *testing.T) {
+	installWarmTestSeams(t)
+	fetchRolesForAcctFetcher = func(ctx context.Context, profile, ssoRegion, accessToken, accountID, accountName string) ([]roleTarget, error) {
+		if accountID == "111" {
+			return nil, errors.New("access denied")
+		}
+		return []roleTarget{{AccountID: accountID, RoleName: "Admin"}}, nil
+	}
+	opts := newTestCacheOptions(t, "fresh")
+	opts.Workers = 2
+
+	summary := warmCache(context.Background(), opts, profileConfig{}, "token", false, io.Discard)
+	if summary.OK || len(summary.Failures) != 1 || summary.Roles != 1 || summary.Regions != 2 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	if summary.InstanceScopes != 0 {
+		t.Fatalf("instances should not be warmed without --instances, got %+v", summary)
+	}
+}
+
+func TestWarmCacheResolvesRegionsPerAccount(t *testing.T) {
+	installWarmTestSeams(t)
+	resolveRegionsFetcher = func(ctx context.Context, tmpConfigPath, profile, discoveryRegion, regionsArg string, includeAllRegions bool) ([]string, error) {
+		if regionsArg != "" {
+			return strings.Split(regionsArg, ","), nil
+		}
+		return []string{"eu-west-1", "us-east-1"}, nil
+	}
+	var mu sync.Mutex
+	scanned := map[string]bool{}
+	queryInstancesFetcher = func(ctx context.Context, tmpConfigPath string, target roleTarget, profileName, region string, runningOnly bool) ([]instanceCandidate, error) {
+		mu.Lock()
+		defer mu.Unlock()
+		scanned[fmt.Sprintf("%s/%s/%t", target.AccountID, region, runningOnly)] = true
+		return nil, nil
+	}
+	stopped := true
+	overrides, err := parseAccountOverrides(userConfigAccounts{{Match: "dev", userConfigAccount: userConfigAccount{Regions: []string{"ap-south-1"}, IncludeStopped: &stopped}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	opts := newTestCacheOptions(t, "fresh")
+	opts.accountOverrides = overrides
+
+	summary := warmCache(context.Background(), opts, profileConfig{}, "token", true, io.Discard)
+	if !summary.OK || summary.Regions != 3 || summary.InstanceScopes != 3 {
+		t.Fatalf("unexpected summary %+v", summary)
+	}
+	for _, want := range []string{"111/eu-west-1/true", "111/us-east-1/true", "222/ap-south-1/false"} {
+		if !scanned[want] {
+			t.Fatalf("expected %s to be warmed, got %v", want, scanned)
+		}
+	}
+}
+
+func TestForEachParallelRespectsWorkerLimit(t *testing.T) {
+	var running, peak, calls atomic.Int32
+	forEachParallel(context.Background(), 3, 20, func(i int) {
+		n := running.Add(1)
+		for {
+			p := peak.Load()
+			if n <= p || peak.CompareAndSwap(p, n) {
+				break
+			}
+		}
+		time.Sleep(2 * time.Millisecond)
+		running.Add(-1)
+		calls.Add(1)
+	})
+	if calls.Load() != 20 || peak.Load() > 3 {
+		t.Fatalf("calls=%d peak=%d", calls.Load(), peak.Load())
+	}
+}
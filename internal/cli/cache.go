@@ -0,0 +1,107 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"swamp/internal/app"
+)
+
+func newCacheCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "cache",
+		Short: "Inspect and maintain the local discovery cache",
+	}
+	cmd.AddCommand(newCacheListCmd(), newCacheStatsCmd(), newCachePruneCmd(), newCacheInvalidateCmd())
+	return cmd
+}
+
+// cacheProfileFilter limits cache commands to one profile only when
+// --profile was given; otherwise they cover every profile in the cache.
+func cacheProfileFilter(cmd *cobra.Command, opts app.Options) string {
+	if cmd.Flags().Changed("profile") {
+		return opts.Profile
+	}
+	return ""
+}
+
+func newCacheListCmd() *cobra.Command {
+	var opts app.Options
+	var kind string
+
+	cmd := &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List cache entries with their age, expiry and size",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finishLocalOptions(cmd, &opts)
+			return app.CacheList(opts, cacheProfileFilter(cmd, opts), strings.TrimSpace(kind))
+		},
+	}
+	addLocalFlags(cmd, &opts)
+	cmd.Flags().StringVarP(&kind, "kind", "k", "", "Only show one kind: accounts|roles|regions|instances")
+	return cmd
+}
+
+func newCacheStatsCmd() *cobra.Command {
+	var opts app.Options
+
+	cmd := &cobra.Command{
+		Use:   "stats",
+		Short: "Show cache size and hit/miss counts per kind",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finishLocalOptions(cmd, &opts)
+			return app.CacheStats(opts, cacheProfileFilter(cmd, opts))
+		},
+	}
+	addLocalFlags(cmd, &opts)
+	return cmd
+}
+
+func newCachePruneCmd() *cobra.Command {
+	var opts app.Options
+	var dryRun, undecryptable bool
+
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Delete expired entries and orphaned cache files",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finishLocalOptions(cmd, &opts)
+			return app.CachePrune(opts, dryRun, undecryptable)
+		},
+	}
+	addLocalFlags(cmd, &opts)
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Show what would be removed without deleting anything")
+	cmd.Flags().BoolVar(&undecryptable, "undecryptable", false, "Also delete files that cannot be decrypted with the current key")
+	return cmd
+}
+
+func newCacheInvalidateCmd() *cobra.Command {
+	var opts app.Options
+	var filter app.CacheInvalidateFilter
+	var dryRun bool
+
+	cmd := &cobra.Command{
+		Use:   "invalidate",
+		Short: "Drop cached entries for an account, role or region",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finishLocalOptions(cmd, &opts)
+			filter.Profile = cacheProfileFilter(cmd, opts)
+			filter.AccountID = strings.TrimSpace(filter.AccountID)
+			filter.Role = strings.TrimSpace(filter.Role)
+			filter.Region = strings.TrimSpace(filter.Region)
+			return app.CacheInvalidate(opts, filter, dryRun)
+		},
+	}
+	addLocalFlags(cmd, &opts)
+	cmd.Flags().StringVarP(&filter.AccountID, "account", "a", "", "Account ID")
+	cmd.Flags().StringVarP(&filter.Role, "role", "r", "", "Role name")
+	cmd.Flags().StringVarP(&filter.Region, "region", "R", "", "Region")
+	cmd.Flags().BoolVarP(&dryRun, "dry-run", "n", false, "Show what would be removed without deleting anything")
+	return cmd
+}
@@ -0,0 +1,89 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"swamp/internal/app"
+)
+
+// registerCompletions adds dynamic completion for whichever of the profile,
+// account, role, region(s) and preset flags cmd has. Completions only read
+// local files and the discovery cache; see app.CompleteAccounts.
+func registerCompletions(cmd *cobra.Command) {
+	register := func(name string, fn func(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective)) {
+		if cmd.Flags().Lookup(name) == nil {
+			return
+		}
+		_ = cmd.RegisterFlagCompletionFunc(name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			return fn(cmd, toComplete)
+		})
+	}
+	noFiles := cobra.ShellCompDirectiveNoFileComp
+
+	register("profile", func(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return app.CompleteProfiles(toComplete), noFiles
+	})
+	register("preset", func(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return app.CompletePresets(completionOptions(cmd), toComplete), noFiles
+	})
+	register("account", func(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return app.CompleteAccounts(completionOptions(cmd), toComplete), noFiles
+	})
+	register("role", func(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective) {
+		account, _ := cmd.Flags().GetString("account")
+		return app.CompleteRoles(completionOptions(cmd), account, toComplete), noFiles
+	})
+	register("regions", func(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective) {
+		// No space after a region so another one can follow a comma.
+		return app.CompleteRegions(completionOptions(cmd), true, toComplete), noFiles | cobra.ShellCompDirectiveNoSpace
+	})
+	register("region", func(cmd *cobra.Command, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return app.CompleteRegions(completionOptions(cmd), false, toComplete), noFiles
+	})
+}
+
+// completeRootArgs completes `swamp @favorite` and `swamp PRESET`.
+func completeRootArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	if len(args) > 0 {
+		return nil, cobra.ShellCompDirectiveNoFileComp
+	}
+	opts := completionOptions(cmd)
+	out := app.CompleteFavorites(opts, "@", toComplete)
+	if !strings.HasPrefix(toComplete, "@") {
+		out = append(app.CompletePresets(opts, toComplete), out...)
+	}
+	return out, cobra.ShellCompDirectiveNoFileComp
+}
+
+func completeFavoriteArgs(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+	return app.CompleteFavorites(completionOptions(cmd), "", toComplete), cobra.ShellCompDirectiveNoFileComp
+}
+
+// completionOptions reads the flags that locate config and cache straight
+// from cmd, since completion runs without RunE.
+func completionOptions(cmd *cobra.Command) app.Options {
+	var opts app.Options
+	opts.Profile, _ = cmd.Flags().GetString("profile")
+	opts.ConfigPath, _ = cmd.Flags().GetString("config")
+	opts.CacheDir, _ = cmd.Flags().GetString("cache-dir")
+	opts.Preset, _ = cmd.Flags().GetString("preset")
+	opts.Profile = strings.TrimSpace(opts.Profile)
+	opts.ConfigPath = strings.TrimSpace(opts.ConfigPath)
+	opts.Preset = strings.TrimSpace(opts.Preset)
+	opts.FlagSet = map[string]bool{
+		"profile":   cmd.Flags().Changed("profile"),
+		"config":    cmd.Flags().Changed("config"),
+		"cache-dir": cmd.Flags().Changed("cache-dir"),
+		"preset":    cmd.Flags().Changed("preset"),
+	}
+	return opts
+}
+
+func walkCommands(cmd *cobra.Command, fn func(*cobra.Command)) {
+	fn(cmd)
+	for _, sub := range cmd.Commands() {
+		walkCommands(sub, fn)
+	}
+}
@@ -0,0 +1,96 @@
+package cli
+
+import (
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"swamp/internal/app"
+)
+
+func newConfigCmd() *cobra.Command {
+	var opts app.Options
+
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Read, change and validate the config file",
+	}
+	cmd.PersistentFlags().StringVarP(&opts.ConfigPath, "config", "c", "", "Path to swamp config file (default: ~/.config/swamp/config.yaml)")
+	cmd.AddCommand(
+		newConfigGetCmd(&opts),
+		newConfigSetCmd(&opts),
+		newConfigEditCmd(&opts),
+		newConfigValidateCmd(&opts),
+		newConfigSchemaCmd(),
+	)
+	return cmd
+}
+
+func newConfigGetCmd(opts *app.Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "get <key>",
+		Short: "Print one key from the config file, e.g. discovery.workers",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.ConfigPath = strings.TrimSpace(opts.ConfigPath)
+			return app.ConfigGet(os.Stdout, *opts, strings.TrimSpace(args[0]))
+		},
+	}
+}
+
+func newConfigSetCmd(opts *app.Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "set <key> <value>",
+		Short: "Change one key in the config file, keeping comments and key order",
+		Long: "Change one key in the config file, keeping comments and key order.\n" +
+			"Lists such as discovery.regions are given comma-separated; an empty value clears them.",
+		Example: "  swamp config set discovery.workers 24\n" +
+			"  swamp config set ux.skip_region_select true\n" +
+			"  swamp config set discovery.regions eu-west-1,eu-central-1",
+		Args: cobra.ExactArgs(2),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.ConfigPath = strings.TrimSpace(opts.ConfigPath)
+			return app.ConfigSet(os.Stdout, *opts, strings.TrimSpace(args[0]), args[1])
+		},
+	}
+}
+
+func newConfigEditCmd(opts *app.Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "edit",
+		Short: "Open the config file in $VISUAL/$EDITOR and save it only once it validates",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.ConfigPath = strings.TrimSpace(opts.ConfigPath)
+			return app.ConfigEdit(os.Stdin, os.Stdout, *opts)
+		},
+	}
+}
+
+func newConfigValidateCmd(opts *app.Options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "validate [file...]",
+		Short: "Check config files against the schema (default: the active config file)",
+		Long: "Check config files for unknown keys, wrong types, bad durations, unknown regions\n" +
+			"and out-of-range values. Problems are printed as file:line:column and the command\n" +
+			"exits non-zero when any are found, so it can run from a pre-commit hook.",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 && strings.TrimSpace(opts.ConfigPath) != "" {
+				args = []string{strings.TrimSpace(opts.ConfigPath)}
+			}
+			return app.ValidateConfig(os.Stdout, args)
+		},
+	}
+}
+
+func newConfigSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the config file JSON Schema",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.PrintConfigSchema(os.Stdout)
+		},
+	}
+}
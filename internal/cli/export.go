@@ -0,0 +1,35 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"swamp/internal/app"
+)
+
+func newExportProfilesCmd() *cobra.Command {
+	var opts app.Options
+	var in app.ExportInput
+
+	cmd := &cobra.Command{
+		Use:   "export-profiles",
+		Short: "Write every discovered account/role pair as a named AWS config profile",
+		Long: "Discover the accounts and roles the SSO profile can use and write one `[profile NAME]`\n" +
+			"section per pair into a managed block of ~/.aws/config (or --file). Rerunning replaces\n" +
+			"the block; profiles outside it are never changed.\n\n" +
+			"--name is a Go template over .Profile, .AccountID, .AccountName and .RoleName.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finishRunOptions(cmd, &opts)
+			return app.ExportProfiles(cmd.Context(), opts, in)
+		},
+	}
+
+	addScopeFlags(cmd, &opts)
+	addCacheFlags(cmd, &opts)
+	cmd.Flags().StringVarP(&in.Path, "file", "f", "", "File to write the profiles to (default: ~/.aws/config)")
+	cmd.Flags().StringVarP(&in.NameTemplate, "name", "n", "{{.AccountName}}-{{.RoleName}}", "Profile name template")
+	cmd.Flags().BoolVar(&in.DryRun, "dry-run", false, "Print the managed block instead of writing it")
+	cmd.Flags().BoolVar(&in.Remove, "remove", false, "Remove the managed block for this profile")
+
+	return cmd
+}
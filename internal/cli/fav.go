@@ -0,0 +1,83 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"swamp/internal/app"
+)
+
+func newFavCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fav",
+		Short: "Manage favorite targets (connect with `swamp @alias`)",
+	}
+	cmd.AddCommand(newFavAddCmd(), newFavListCmd(), newFavRemoveCmd())
+	return cmd
+}
+
+func newFavAddCmd() *cobra.Command {
+	var opts app.Options
+	var in app.FavoriteInput
+
+	cmd := &cobra.Command{
+		Use:   "add <alias>",
+		Short: "Save the last connected target, or an explicit scope, as a favorite",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finishLocalOptions(cmd, &opts)
+			in.Account = strings.TrimSpace(in.Account)
+			in.Role = strings.TrimSpace(in.Role)
+			in.Region = strings.TrimSpace(in.Region)
+			in.Instance = strings.TrimSpace(in.Instance)
+			in.Tag = strings.TrimSpace(in.Tag)
+			return app.AddFavorite(opts, args[0], in)
+		},
+	}
+
+	addLocalFlags(cmd, &opts)
+	cmd.Flags().StringVarP(&in.Account, "account", "a", "", "Account ID")
+	cmd.Flags().StringVarP(&in.Role, "role", "r", "", "Role name")
+	cmd.Flags().StringVarP(&in.Region, "region", "R", "", "Region (required with --instance)")
+	cmd.Flags().StringVarP(&in.Instance, "instance", "i", "", "Instance ID")
+	cmd.Flags().StringVarP(&in.Tag, "tag", "t", "", "Tag selector Key=Value resolved when connecting")
+	cmd.Flags().BoolVarP(&in.Force, "force", "f", false, "Replace an existing favorite with the same alias")
+
+	return cmd
+}
+
+func newFavListCmd() *cobra.Command {
+	var opts app.Options
+
+	cmd := &cobra.Command{
+		Use:     "ls",
+		Aliases: []string{"list"},
+		Short:   "List saved favorites",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finishLocalOptions(cmd, &opts)
+			return app.ListFavorites(opts)
+		},
+	}
+	addLocalFlags(cmd, &opts)
+	return cmd
+}
+
+func newFavRemoveCmd() *cobra.Command {
+	var opts app.Options
+
+	cmd := &cobra.Command{
+		Use:               "rm <alias>...",
+		Aliases:           []string{"remove"},
+		Short:             "Remove saved favorites",
+		Args:              cobra.MinimumNArgs(1),
+		ValidArgsFunction: completeFavoriteArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finishLocalOptions(cmd, &opts)
+			return app.RemoveFavorites(opts, args)
+		},
+	}
+	addLocalFlags(cmd, &opts)
+	return cmd
+}
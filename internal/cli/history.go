@@ -0,0 +1,26 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"swamp/internal/app"
+)
+
+func newHistoryCmd() *cobra.Command {
+	var opts app.Options
+
+	cmd := &cobra.Command{
+		Use:   "history",
+		Short: "Pick a past connection and reconnect to it",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finishRunOptions(cmd, &opts)
+			opts.History = true
+			return app.Run(cmd.Context(), opts)
+		},
+	}
+
+	addRunFlags(cmd, &opts)
+
+	return cmd
+}
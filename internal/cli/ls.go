@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"swamp/internal/app"
+)
+
+func newLsCmd() *cobra.Command {
+	var opts app.Options
+	var output, sortBy string
+
+	cmd := &cobra.Command{
+		Use:   "ls",
+		Short: "List instances across accounts, roles and regions without a picker",
+		Long: "Run the same discovery as the interactive flow and print every matching instance.\n" +
+			"All discovered regions are scanned, as with --skip-region-select. Progress goes to stderr.\n\n" +
+			"Formats: json, yaml, csv, table (default) or template=GO_TEMPLATE, for example\n" +
+			"  swamp ls -p dev -o 'template={{.InstanceID}} {{.Name}} {{index .Tags \"Team\"}}'",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finishRunOptions(cmd, &opts)
+			return app.List(cmd.Context(), opts, output, sortBy)
+		},
+	}
+
+	addScopeFlags(cmd, &opts)
+	addScanFlags(cmd, &opts)
+	addCacheFlags(cmd, &opts)
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "Output format: json, yaml, csv, table or template=GO_TEMPLATE")
+	cmd.Flags().StringVar(&sortBy, "sort", "", "Comma-separated sort keys (account, account_id, role, region, id, name, ip, state, platform, type, launch, tag:KEY); prefix with - to reverse")
+
+	return cmd
+}
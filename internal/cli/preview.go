@@ -0,0 +1,30 @@
+package cli
+
+import (
+	"strings"
+
+	"github.com/spf13/cobra"
+
+	"swamp/internal/app"
+)
+
+func newPreviewCmd() *cobra.Command {
+	var opts app.Options
+
+	cmd := &cobra.Command{
+		Use:    "__preview <key>",
+		Short:  "Render picker preview details from the local cache",
+		Hidden: true,
+		Args:   cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			opts.Profile = strings.TrimSpace(opts.Profile)
+			opts.CacheDir = strings.TrimSpace(opts.CacheDir)
+			return app.Preview(opts, args[0])
+		},
+	}
+
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "", "AWS SSO profile the cache entries belong to")
+	cmd.Flags().StringVar(&opts.CacheDir, "cache-dir", app.DefaultCacheDirForCLI(), "Directory for local cache files")
+
+	return cmd
+}
@@ -0,0 +1,38 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"swamp/internal/app"
+)
+
+func newScanCmd() *cobra.Command {
+	var fresh, toggleStopped bool
+
+	cmd := &cobra.Command{
+		Use:    "__scan <spec>",
+		Short:  "Rerun the instance scan for an open fzf picker",
+		Hidden: true,
+		Args:   cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.ReloadScan(cmd.Context(), args[0], fresh, toggleStopped)
+		},
+	}
+
+	cmd.Flags().BoolVar(&fresh, "fresh", false, "Bypass cached instance lists")
+	cmd.Flags().BoolVar(&toggleStopped, "toggle-stopped", false, "Flip whether stopped instances are included")
+
+	return cmd
+}
+
+func newCopyIDCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:    "__copy-id <key>",
+		Short:  "Copy the instance ID from a picker line to the clipboard",
+		Hidden: true,
+		Args:   cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return app.CopyInstanceID(args[0])
+		},
+	}
+}
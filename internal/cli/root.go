@@ -1,7 +1,13 @@
 package cli
 
 import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"strconv"
 	"strings"
+	"syscall"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -10,11 +16,13 @@ import (
 )
 
 func Execute() error {
-	return newRootCmd().Execute()
+	return ExecuteWithVersion("")
 }
 
 func ExecuteWithVersion(version string) error {
-	return newRootCmdWithVersion(version).Execute()
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	return newRootCmdWithVersion(version).ExecuteContext(ctx)
 }
 
 func newRootCmd() *cobra.Command {
@@ -25,59 +33,96 @@ func newRootCmdWithVersion(version string) *cobra.Command {
 	var opts app.Options
 
 	cmd := &cobra.Command{
-		Use:           "swamp",
-		Short:         "Discover EC2 instances across SSO scope and connect via SSM",
-		Version:       version,
-		SilenceUsage:  true,
-		SilenceErrors: true,
+		Use:               "swamp [@favorite | preset]",
+		Short:             "Discover EC2 instances across SSO scope and connect via SSM",
+		Version:           version,
+		SilenceUsage:      true,
+		SilenceErrors:     true,
+		Args:              cobra.MaximumNArgs(1),
+		ValidArgsFunction: completeRootArgs,
 		RunE: func(cmd *cobra.Command, args []string) error {
-			opts.Profile = strings.TrimSpace(opts.Profile)
-			opts.AccountFilter = strings.TrimSpace(opts.AccountFilter)
-			opts.RoleFilter = strings.TrimSpace(opts.RoleFilter)
-			opts.RegionsArg = strings.TrimSpace(opts.RegionsArg)
-			opts.ConfigPath = strings.TrimSpace(opts.ConfigPath)
-			opts.FlagSet = map[string]bool{
-				"profile":                cmd.Flags().Changed("profile"),
-				"workers":                cmd.Flags().Changed("workers"),
-				"account":                cmd.Flags().Changed("account"),
-				"role":                   cmd.Flags().Changed("role"),
-				"regions":                cmd.Flags().Changed("regions"),
-				"all-regions":            cmd.Flags().Changed("all-regions"),
-				"skip-region-select":     cmd.Flags().Changed("skip-region-select"),
-				"include-stopped":        cmd.Flags().Changed("include-stopped"),
-				"cache":                  cmd.Flags().Changed("cache"),
-				"cache-dir":              cmd.Flags().Changed("cache-dir"),
-				"cache-mode":             cmd.Flags().Changed("cache-mode"),
-				"cache-clear":            cmd.Flags().Changed("cache-clear"),
-				"cache-ttl-accounts":     cmd.Flags().Changed("cache-ttl-accounts"),
-				"cache-ttl-roles":        cmd.Flags().Changed("cache-ttl-roles"),
-				"cache-ttl-regions":      cmd.Flags().Changed("cache-ttl-regions"),
-				"cache-ttl-instances":    cmd.Flags().Changed("cache-ttl-instances"),
-				"resume":                 cmd.Flags().Changed("resume"),
-				"last":                   cmd.Flags().Changed("last"),
-				"no-auto-select":         cmd.Flags().Changed("no-auto-select"),
-				"config":                 cmd.Flags().Changed("config"),
-				"write-config-example":   cmd.Flags().Changed("write-config-example"),
-				"print-effective-config": cmd.Flags().Changed("print-effective-config"),
+			if err := applyRootArgs(cmd, &opts, args); err != nil {
+				return err
 			}
-			return app.Run(opts)
+			finishRunOptions(cmd, &opts)
+			return app.Run(cmd.Context(), opts)
 		},
 	}
 
+	addRunFlags(cmd, &opts)
+
+	cmd.AddCommand(newFavCmd(), newHistoryCmd(), newCacheCmd(), newWarmCmd(), newLsCmd(), newExportProfilesCmd(), newConfigCmd())
+	cmd.AddCommand(newPreviewCmd(), newScanCmd(), newCopyIDCmd())
+	walkCommands(cmd, registerCompletions)
+
+	return cmd
+}
+
+func applyRootArgs(cmd *cobra.Command, opts *app.Options, args []string) error {
+	// `--last 2` leaves the 2 as an argument since -l also works alone.
+	if len(args) == 1 && cmd.Flags().Changed("last") && opts.LastN == 1 {
+		if n, err := strconv.Atoi(args[0]); err == nil {
+			opts.LastN = n
+			return nil
+		}
+	}
+	if len(args) == 1 {
+		if strings.HasPrefix(args[0], "@") {
+			opts.Favorite = strings.TrimPrefix(args[0], "@")
+			return nil
+		}
+		if cmd.Flags().Changed("preset") {
+			return fmt.Errorf("unexpected argument %q: --preset is already set to %q", args[0], opts.Preset)
+		}
+		opts.Preset = args[0]
+	}
+	return nil
+}
+
+// addRunFlags registers the flags of the interactive command that ends up
+// in app.Run.
+func addRunFlags(cmd *cobra.Command, opts *app.Options) {
+	addScopeFlags(cmd, opts)
+	cmd.Flags().Lookup("preset").Usage += " (same as `swamp NAME`)"
+	addScanFlags(cmd, opts)
+	cmd.Flags().BoolVar(&opts.SkipRegionSelect, "skip-region-select", false, "Skip region picker and show instances from all discovered regions")
+	cmd.Flags().BoolVarP(&opts.Resume, "resume", "u", false, "Resume with the last successful account/role/region scope")
+	cmd.Flags().IntVarP(&opts.LastN, "last", "l", 0, "Reconnect directly to the Nth most recent instance (-l alone means the last one)")
+	cmd.Flags().Lookup("last").NoOptDefVal = "1"
+	cmd.Flags().BoolVar(&opts.NoAutoSelect, "no-auto-select", false, "Disable auto-selection when only one option is available")
+	cmd.Flags().StringVar(&opts.Picker, "picker", "auto", "Picker UI: auto (fzf when installed), fzf, builtin")
+	cmd.Flags().StringVar(&opts.Document, "document", "", "SSM document for interactive sessions (default: the Session Manager shell)")
+	cmd.Flags().BoolVar(&opts.WriteConfigExample, "write-config-example", false, "Write an example config file and exit")
+	cmd.Flags().BoolVar(&opts.PrintEffectiveConfig, "print-effective-config", false, "Print effective runtime settings and exit")
+	addCacheFlags(cmd, opts)
+	cmd.Flags().BoolVar(&opts.CacheClear, "cache-clear", false, "Clear cache directory before discovery")
+}
+
+// addScopeFlags registers the profile, config and account/role scope flags
+// of every command that runs discovery: the root command, warm, ls and
+// export-profiles.
+func addScopeFlags(cmd *cobra.Command, opts *app.Options) {
 	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "", "AWS SSO profile name to bootstrap discovery (required)")
+	cmd.Flags().StringVarP(&opts.ConfigPath, "config", "c", "", "Path to swamp config file (default: ~/.config/swamp/config.yaml)")
+	cmd.Flags().StringVar(&opts.Preset, "preset", "", "Apply a named preset from the config file")
 	cmd.Flags().IntVarP(&opts.Workers, "workers", "w", 12, "Number of concurrent workers for account/role/region scanning")
 	cmd.Flags().StringVarP(&opts.AccountFilter, "account", "a", "", "Filter to a specific account ID or account-name substring")
 	cmd.Flags().StringVarP(&opts.RoleFilter, "role", "r", "", "Filter to a specific role name")
+	cmd.Flags().StringVar(&opts.Filter, "filter", "", `Filter expression, e.g. 'account!="*-sandbox" role in (Admin,PowerUser) tag:Team=payments'`)
+}
+
+// addScanFlags registers the region and instance flags of the commands that
+// scan instances.
+func addScanFlags(cmd *cobra.Command, opts *app.Options) {
 	cmd.Flags().StringVarP(&opts.RegionsArg, "regions", "R", "", "Comma-separated regions to scan (default: discover all enabled regions)")
 	cmd.Flags().BoolVarP(&opts.AllRegions, "all-regions", "A", false, "Include all regions, even those not enabled in the account")
-	cmd.Flags().BoolVar(&opts.SkipRegionSelect, "skip-region-select", false, "Skip region picker and show instances from all discovered regions")
 	cmd.Flags().BoolVarP(&opts.IncludeStopped, "include-stopped", "s", false, "Include non-running instances in selection")
-	cmd.Flags().BoolVarP(&opts.Resume, "resume", "u", false, "Resume with the last successful account/role/region scope")
-	cmd.Flags().BoolVarP(&opts.Last, "last", "l", false, "Reconnect directly to the last successful instance")
-	cmd.Flags().BoolVar(&opts.NoAutoSelect, "no-auto-select", false, "Disable auto-selection when only one option is available")
-	cmd.Flags().StringVarP(&opts.ConfigPath, "config", "c", "", "Path to swamp config file (default: ~/.config/swamp/config.yaml)")
-	cmd.Flags().BoolVar(&opts.WriteConfigExample, "write-config-example", false, "Write an example config file and exit")
-	cmd.Flags().BoolVar(&opts.PrintEffectiveConfig, "print-effective-config", false, "Print effective runtime settings and exit")
+	cmd.Flags().BoolVar(&opts.SSMStatus, "ssm-status", false, "Look up the SSM agent ping status of scanned instances (one extra AWS call per account/role/region)")
+}
+
+// addCacheFlags registers the cache and timeout flags of every command that
+// runs discovery.
+func addCacheFlags(cmd *cobra.Command, opts *app.Options) {
 	cmd.Flags().BoolVar(&opts.CacheEnabled, "cache", true, "Enable local discovery cache")
 	cmd.Flags().StringVar(&opts.CacheDir, "cache-dir", app.DefaultCacheDirForCLI(), "Directory for local cache files")
 	cmd.Flags().DurationVar(&opts.CacheTTLAccounts, "cache-ttl-accounts", 6*time.Hour, "TTL for SSO account discovery cache")
@@ -85,7 +130,72 @@ func newRootCmdWithVersion(version string) *cobra.Command {
 	cmd.Flags().DurationVar(&opts.CacheTTLRegions, "cache-ttl-regions", 24*time.Hour, "TTL for region discovery cache")
 	cmd.Flags().DurationVar(&opts.CacheTTLInstances, "cache-ttl-instances", 60*time.Second, "TTL for instance discovery cache")
 	cmd.Flags().StringVar(&opts.CacheMode, "cache-mode", "balanced", "Cache mode: balanced, fresh, speed")
-	cmd.Flags().BoolVar(&opts.CacheClear, "cache-clear", false, "Clear cache directory before discovery")
+	cmd.Flags().BoolVar(&opts.CacheEncrypt, "cache-encrypt", false, "Encrypt cache and history files at rest (key: $SWAMP_CACHE_KEY, cache.key_command or ~/.config/swamp/cache.key)")
+	cmd.Flags().DurationVar(&opts.Timeout, "timeout", 0, "Total time budget for AWS discovery, excluding time spent in pickers (0 disables)")
+	cmd.Flags().DurationVar(&opts.CallTimeout, "call-timeout", 60*time.Second, "Timeout for each individual AWS CLI call (0 disables)")
+}
 
-	return cmd
+// finishRunOptions trims string flags and records which flags were set
+// explicitly so config values only fill in the rest.
+func finishRunOptions(cmd *cobra.Command, opts *app.Options) {
+	opts.Profile = strings.TrimSpace(opts.Profile)
+	opts.AccountFilter = strings.TrimSpace(opts.AccountFilter)
+	opts.RoleFilter = strings.TrimSpace(opts.RoleFilter)
+	opts.RegionsArg = strings.TrimSpace(opts.RegionsArg)
+	opts.ConfigPath = strings.TrimSpace(opts.ConfigPath)
+	opts.Picker = strings.TrimSpace(opts.Picker)
+	opts.Preset = strings.TrimSpace(opts.Preset)
+	opts.Document = strings.TrimSpace(opts.Document)
+	opts.Filter = strings.TrimSpace(opts.Filter)
+	opts.FlagSet = map[string]bool{
+		"profile":                cmd.Flags().Changed("profile"),
+		"workers":                cmd.Flags().Changed("workers"),
+		"account":                cmd.Flags().Changed("account"),
+		"role":                   cmd.Flags().Changed("role"),
+		"regions":                cmd.Flags().Changed("regions"),
+		"all-regions":            cmd.Flags().Changed("all-regions"),
+		"skip-region-select":     cmd.Flags().Changed("skip-region-select"),
+		"include-stopped":        cmd.Flags().Changed("include-stopped"),
+		"ssm-status":             cmd.Flags().Changed("ssm-status"),
+		"cache":                  cmd.Flags().Changed("cache"),
+		"cache-dir":              cmd.Flags().Changed("cache-dir"),
+		"cache-mode":             cmd.Flags().Changed("cache-mode"),
+		"cache-clear":            cmd.Flags().Changed("cache-clear"),
+		"cache-encrypt":          cmd.Flags().Changed("cache-encrypt"),
+		"cache-ttl-accounts":     cmd.Flags().Changed("cache-ttl-accounts"),
+		"cache-ttl-roles":        cmd.Flags().Changed("cache-ttl-roles"),
+		"cache-ttl-regions":      cmd.Flags().Changed("cache-ttl-regions"),
+		"cache-ttl-instances":    cmd.Flags().Changed("cache-ttl-instances"),
+		"timeout":                cmd.Flags().Changed("timeout"),
+		"call-timeout":           cmd.Flags().Changed("call-timeout"),
+		"resume":                 cmd.Flags().Changed("resume"),
+		"last":                   cmd.Flags().Changed("last"),
+		"no-auto-select":         cmd.Flags().Changed("no-auto-select"),
+		"picker":                 cmd.Flags().Changed("picker"),
+		"preset":                 cmd.Flags().Changed("preset"),
+		"document":               cmd.Flags().Changed("document"),
+		"filter":                 cmd.Flags().Changed("filter"),
+		"config":                 cmd.Flags().Changed("config"),
+		"write-config-example":   cmd.Flags().Changed("write-config-example"),
+		"print-effective-config": cmd.Flags().Changed("print-effective-config"),
+	}
+	opts.Last = opts.LastN > 0
+}
+
+// addLocalFlags registers the flags commands that only touch local state
+// need to find the same config file, profile and cache dir as a normal run.
+func addLocalFlags(cmd *cobra.Command, opts *app.Options) {
+	cmd.Flags().StringVarP(&opts.Profile, "profile", "p", "", "AWS SSO profile name")
+	cmd.Flags().StringVarP(&opts.ConfigPath, "config", "c", "", "Path to swamp config file (default: ~/.config/swamp/config.yaml)")
+	cmd.Flags().StringVar(&opts.CacheDir, "cache-dir", app.DefaultCacheDirForCLI(), "Directory for local cache files")
+}
+
+func finishLocalOptions(cmd *cobra.Command, opts *app.Options) {
+	opts.Profile = strings.TrimSpace(opts.Profile)
+	opts.ConfigPath = strings.TrimSpace(opts.ConfigPath)
+	opts.FlagSet = map[string]bool{
+		"profile":   cmd.Flags().Changed("profile"),
+		"config":    cmd.Flags().Changed("config"),
+		"cache-dir": cmd.Flags().Changed("cache-dir"),
+	}
 }
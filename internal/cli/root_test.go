@@ -1,6 +1,13 @@
 package cli
 
-import "testing"
+import (
+	"strings"
+	"testing"
+
+	"github.com/spf13/cobra"
+
+	"swamp/internal/app"
+)
 
 func TestRootCommandCacheDefaultTTLs(t *testing.T) {
 	cmd := newRootCmd()
@@ -17,6 +24,19 @@ func TestRootCommandCacheDefaultTTLs(t *testing.T) {
 	}
 }
 
+func TestDiscoveryCommandsShareFlagDefaults(t *testing.T) {
+	root := newRootCmd()
+	shared := []string{"profile", "config", "preset", "workers", "account", "role", "filter", "cache", "cache-dir", "cache-ttl-accounts", "cache-ttl-roles", "cache-ttl-regions", "cache-ttl-instances", "cache-encrypt", "timeout", "call-timeout"}
+	for _, cmd := range []*cobra.Command{newWarmCmd(), newLsCmd(), newExportProfilesCmd()} {
+		for _, name := range shared {
+			want, got := root.Flags().Lookup(name), cmd.Flags().Lookup(name)
+			if got == nil || got.DefValue != want.DefValue || got.Shorthand != want.Shorthand {
+				t.Fatalf("%s --%s: expected the root command's flag, got %+v", cmd.Name(), name, got)
+			}
+		}
+	}
+}
+
 func TestRootCommandNewShortFlags(t *testing.T) {
 	cmd := newRootCmd()
 	last := cmd.Flags().ShorthandLookup("l")
@@ -39,3 +59,104 @@ func TestRootCommandSkipRegionSelectFlagExists(t *testing.T) {
 		t.Fatalf("expected default false, got %s", flag.DefValue)
 	}
 }
+
+func TestRootCommandHasHiddenPickerCommands(t *testing.T) {
+	cmd := newRootCmd()
+	for _, name := range []string{"__preview", "__scan", "__copy-id"} {
+		sub, _, err := cmd.Find([]string{name})
+		if err != nil || sub == nil || sub.Name() != name {
+			t.Fatalf("expected %s subcommand, got %v (err=%v)", name, sub, err)
+		}
+		if !sub.Hidden {
+			t.Fatalf("expected %s to be hidden", name)
+		}
+	}
+}
+
+func TestRootArgsSelectFavoriteOrPreset(t *testing.T) {
+	var opts app.Options
+	root := &cobra.Command{Use: "swamp"}
+	addRunFlags(root, &opts)
+	if err := applyRootArgs(root, &opts, []string{"@db"}); err != nil || opts.Favorite != "db" || opts.Preset != "" {
+		t.Fatalf("expected favorite, got %+v (err=%v)", opts, err)
+	}
+
+	opts = app.Options{}
+	if err := applyRootArgs(root, &opts, []string{"prod-eu"}); err != nil || opts.Preset != "prod-eu" || opts.Favorite != "" {
+		t.Fatalf("expected preset, got %+v (err=%v)", opts, err)
+	}
+
+	if err := root.ParseFlags([]string{"--preset", "dev"}); err != nil {
+		t.Fatal(err)
+	}
+	err := applyRootArgs(root, &opts, []string{"prod-eu"})
+	if err == nil || !strings.Contains(err.Error(), "--preset") {
+		t.Fatalf("expected conflicting preset error, got %v", err)
+	}
+}
+
+func TestRootCommandLastAcceptsOptionalCount(t *testing.T) {
+	tests := []struct {
+		args []string
+		want int
+	}{
+		{args: []string{"-l"}, want: 1},
+		{args: []string{"--last=3"}, want: 3},
+		{args: []string{"--last", "2"}, want: 2},
+	}
+	for _, tt := range tests {
+		var opts app.Options
+		root := &cobra.Command{Use: "swamp"}
+		addRunFlags(root, &opts)
+		if err := root.ParseFlags(tt.args); err != nil {
+			t.Fatalf("%v: parse flags: %v", tt.args, err)
+		}
+		if err := applyRootArgs(root, &opts, root.Flags().Args()); err != nil {
+			t.Fatalf("%v: applyRootArgs: %v", tt.args, err)
+		}
+		if opts.LastN != tt.want {
+			t.Fatalf("%v: expected LastN=%d, got %d", tt.args, tt.want, opts.LastN)
+		}
+	}
+}
+
+func TestCacheCommandHasMaintenanceSubcommands(t *testing.T) {
+	cmd := newRootCmd()
+	for _, name := range []string{"ls", "stats", "prune", "invalidate"} {
+		sub, _, err := cmd.Find([]string{"cache", name})
+		if err != nil || sub == nil || sub.Name() != name {
+			t.Fatalf("expected cache %s subcommand, got %v (err=%v)", name, sub, err)
+		}
+	}
+}
+
+func TestConfigCommandHasSubcommands(t *testing.T) {
+	cmd := newRootCmd()
+	for _, name := range []string{"get", "set", "edit", "validate", "schema"} {
+		sub, _, err := cmd.Find([]string{"config", name})
+		if err != nil || sub == nil || sub.Name() != name {
+			t.Fatalf("expected config %s subcommand, got %v (err=%v)", name, sub, err)
+		}
+	}
+}
+
+func TestCommandsRegisterDynamicCompletion(t *testing.T) {
+	cmd := newRootCmd()
+	if cmd.ValidArgsFunction == nil {
+		t.Fatal("expected @favorite and preset completion for the root argument")
+	}
+	for _, path := range [][]string{{}, {"ls"}, {"warm"}, {"fav", "add"}, {"cache", "invalidate"}} {
+		sub, _, err := cmd.Find(path)
+		if err != nil {
+			t.Fatalf("%v: %v", path, err)
+		}
+		for _, name := range []string{"profile", "account", "role", "regions", "region", "preset"} {
+			if sub.Flags().Lookup(name) == nil {
+				continue
+			}
+			if _, ok := sub.GetFlagCompletionFunc(name); !ok {
+				t.Fatalf("expected completion for %v --%s", path, name)
+			}
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package cli
+
+import (
+	"github.com/spf13/cobra"
+
+	"swamp/internal/app"
+)
+
+func newWarmCmd() *cobra.Command {
+	var opts app.Options
+	var withInstances, quiet bool
+
+	cmd := &cobra.Command{
+		Use:   "warm",
+		Short: "Prefetch accounts, roles, regions and optionally instances into the cache",
+		Long: "Refresh the discovery cache without any prompts, for example from cron or launchd.\n" +
+			"Requires a valid SSO session; warm never starts `aws sso login`.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			finishRunOptions(cmd, &opts)
+			return app.Warm(cmd.Context(), opts, withInstances, quiet)
+		},
+	}
+
+	addScopeFlags(cmd, &opts)
+	addScanFlags(cmd, &opts)
+	addCacheFlags(cmd, &opts)
+	// warm always fetches fresh entries.
+	_ = cmd.Flags().MarkHidden("cache-mode")
+	cmd.Flags().BoolVarP(&withInstances, "instances", "i", false, "Also warm instance lists for every account/role/region")
+	cmd.Flags().BoolVarP(&quiet, "quiet", "q", false, "Print only a one-line JSON summary")
+
+	return cmd
+}